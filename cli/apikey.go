@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"messenger-module/usecases"
+)
+
+func runAPIKey(uc *usecases.Set, args []string) error {
+	action, rest, err := subcommand("apikey", args)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch action {
+	case "rotate":
+		if len(rest) != 1 {
+			return errors.New("usage: apikey rotate ID")
+		}
+		old, err := uc.APIKeys.Get(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		fresh, err := uc.APIKeys.Rotate(ctx, old.UserID, old.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("revoked %s (%s)\n", old.ID, old.Prefix)
+		fmt.Printf("new key %s: %s\n", fresh.ID, fresh.Secret)
+		fmt.Println("store the api key now, it cannot be shown again")
+	default:
+		return fmt.Errorf("unknown apikey action %q: expected rotate", action)
+	}
+	return nil
+}
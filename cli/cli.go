@@ -0,0 +1,101 @@
+// Package cli implements the operator subcommands of the messenger binary.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"messenger-module/confs"
+	"messenger-module/db"
+	"messenger-module/handlers"
+	"messenger-module/repositories"
+	"messenger-module/usecases"
+)
+
+const usage = `usage: messenger-module <command> [arguments]
+
+commands:
+  serve                                         start the HTTP API (default)
+  migrate up|down|status                        manage the database schema
+  user create --name NAME                       create a user on the Free plan with an admin key
+  user list                                     list users
+  user deactivate ID                            deactivate a user
+  plan create --name NAME [--price-cents N]     create a plan
+  integration create --name NAME --type TYPE --plan PLAN_ID
+                                                create an integration
+  message send --user ID --integration ID --to DEST --content TEXT [--subject S]
+                                                send a message
+  message status ID                             show a message and its status history
+  apikey rotate ID                              replace an API key and revoke the old one
+`
+
+// Run dispatches args (without the program name) to a subcommand.
+func Run(cfg *confs.Config, database db.Database, logger *slog.Logger, args []string) error {
+	if len(args) == 0 {
+		return runServe(cfg, database, logger)
+	}
+
+	uc := usecases.NewSet(repositories.NewDBRepository(database), handlers.NewMessageHandlerFactory(cfg), cfg, logger)
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "serve":
+		return runServe(cfg, database, logger)
+	case "migrate":
+		return runMigrate(database, rest)
+	case "user":
+		return runUser(uc, rest)
+	case "plan":
+		return runPlan(uc, rest)
+	case "integration":
+		return runIntegration(uc, rest)
+	case "message":
+		return runMessage(uc, rest)
+	case "apikey":
+		return runAPIKey(uc, rest)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+	}
+}
+
+// subcommand splits "create --flag x" into the action name and its flag set
+// arguments.
+func subcommand(group string, args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("%s: missing action\n\n%s", group, usage)
+	}
+	return args[0], args[1:], nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// required reports the first empty flag value by name.
+func required(values map[string]string) error {
+	var missing []string
+	for name, v := range values {
+		if strings.TrimSpace(v) == "" {
+			missing = append(missing, "--"+name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing required flag(s): %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
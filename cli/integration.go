@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+)
+
+func runIntegration(uc *usecases.Set, args []string) error {
+	action, rest, err := subcommand("integration", args)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "create":
+		fs := newFlagSet("integration create")
+		name := fs.String("name", "", "provider name: twilio, sendgrid or ntfy")
+		typ := fs.String("type", "", "channel type: phone, email or ntfy")
+		plan := fs.String("plan", "", "id of the plan that unlocks this integration")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if err := required(map[string]string{"name": *name, "type": *typ, "plan": *plan}); err != nil {
+			return err
+		}
+		integration, err := uc.Integrations.Create(context.Background(), entities.Integration{Name: *name, Type: *typ, PlanID: *plan})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created integration %s (%s/%s)\n", integration.ID, integration.Name, integration.Type)
+	default:
+		return fmt.Errorf("unknown integration action %q: expected create", action)
+	}
+	return nil
+}
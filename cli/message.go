@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+)
+
+func runMessage(uc *usecases.Set, args []string) error {
+	action, rest, err := subcommand("message", args)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch action {
+	case "send":
+		fs := newFlagSet("message send")
+		user := fs.String("user", "", "id of the sending user")
+		integration := fs.String("integration", "", "integration id")
+		to := fs.String("to", "", "destination: email, E.164 phone number or ntfy topic")
+		content := fs.String("content", "", "message body")
+		subject := fs.String("subject", "", "subject, for email")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if err := required(map[string]string{"user": *user, "integration": *integration, "to": *to, "content": *content}); err != nil {
+			return err
+		}
+		msg, err := uc.Messages.Create(ctx, entities.Message{
+			UserID:        *user,
+			IntegrationID: *integration,
+			Destination:   *to,
+			Content:       *content,
+			Subject:       *subject,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("sent message %s via %s (external id %s)\n", msg.ID, msg.Type, msg.ExternalID)
+	case "status":
+		if len(rest) != 1 {
+			return errors.New("usage: message status ID")
+		}
+		msg, err := uc.Messages.Get(ctx, rest[0])
+		if err != nil {
+			return err
+		}
+		statuses, err := uc.MessageStatuses.ListByMessage(ctx, msg.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("message %s (%s to %s, created %s)\n", msg.ID, msg.Type, msg.Destination, msg.CreatedAt)
+		tw := newTable(os.Stdout)
+		fmt.Fprintln(tw, "STATUS\tRECORDED\tEXTERNAL ID")
+		for _, st := range statuses {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", st.Status, st.CreatedAt, st.ExternalID)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			fmt.Println("no status updates yet")
+		}
+	default:
+		return fmt.Errorf("unknown message action %q: expected send or status", action)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+)
+
+func runPlan(uc *usecases.Set, args []string) error {
+	action, rest, err := subcommand("plan", args)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "create":
+		fs := newFlagSet("plan create")
+		name := fs.String("name", "", "plan name, e.g. Free or Pro")
+		price := fs.Int("price-cents", 0, "monthly price in cents")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if err := required(map[string]string{"name": *name}); err != nil {
+			return err
+		}
+		plan, err := uc.Plans.Create(context.Background(), entities.Plan{Name: *name, PriceCents: *price})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("plan %s (%s, %d cents)\n", plan.ID, plan.Name, plan.PriceCents)
+	default:
+		return fmt.Errorf("unknown plan action %q: expected create", action)
+	}
+	return nil
+}
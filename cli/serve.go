@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"messenger-module/confs"
+	"messenger-module/db"
+	"messenger-module/server"
+)
+
+func runServe(cfg *confs.Config, database db.Database, logger *slog.Logger) error {
+	// Refuse to serve against an outdated schema
+	migrator, err := db.NewMigrator(database)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind by %d migration(s); run `%s migrate up` first", len(pending), os.Args[0])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return server.NewServer(cfg, logger).Run(ctx, database)
+}
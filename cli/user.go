@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+)
+
+func runUser(uc *usecases.Set, args []string) error {
+	action, rest, err := subcommand("user", args)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch action {
+	case "create":
+		fs := newFlagSet("user create")
+		name := fs.String("name", "", "user name")
+		admin := fs.Bool("admin", false, "issue an admin key")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if err := required(map[string]string{"name": *name}); err != nil {
+			return err
+		}
+		register := uc.Registration.Register
+		if *admin {
+			register = uc.Registration.RegisterAdmin
+		}
+		user, err := register(ctx, entities.User{Name: *name})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created user %s (%s)\n", user.ID, user.Name)
+		fmt.Printf("api key: %s\n", user.APIKey)
+		fmt.Println("store the api key now, it cannot be shown again")
+	case "list":
+		fs := newFlagSet("user list")
+		includeDeleted := fs.Bool("include-deleted", false, "include soft-deleted users")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		users, err := uc.Users.List(ctx, entities.ListOptions{IncludeDeleted: *includeDeleted})
+		if err != nil {
+			return err
+		}
+		tw := newTable(os.Stdout)
+		fmt.Fprintln(tw, "ID\tNAME\tACTIVE\tCREATED\tDELETED")
+		for _, u := range users {
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\n", u.ID, u.Name, u.Active, u.CreatedAt, u.DeletedAt)
+		}
+		return tw.Flush()
+	case "deactivate":
+		if len(rest) != 1 {
+			return errors.New("usage: user deactivate ID")
+		}
+		user, err := uc.Users.Update(ctx, rest[0], entities.User{Active: false})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deactivated user %s (%s)\n", user.ID, user.Name)
+	default:
+		return fmt.Errorf("unknown user action %q: expected create, list or deactivate", action)
+	}
+	return nil
+}
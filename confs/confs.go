@@ -1,15 +1,453 @@
 package confs
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
-func LoadConfig() error {
-	err := godotenv.Load(".env")
+// Config is the typed application configuration. Values are layered:
+// defaults, then the optional CONFIG_FILE (YAML or TOML), then the
+// environment (including an optional .env file).
+type Config struct {
+	Env            string         `yaml:"env" toml:"env"`
+	Port           string         `yaml:"port" toml:"port"`
+	WebhookBaseURL string         `yaml:"webhook_base_url" toml:"webhook_base_url"`
+	Database       DatabaseConfig `yaml:"database" toml:"database"`
+	SendGrid       SendGridConfig `yaml:"sendgrid" toml:"sendgrid"`
+	SMTP           SMTPConfig     `yaml:"smtp" toml:"smtp"`
+	Twilio         TwilioConfig   `yaml:"twilio" toml:"twilio"`
+	Ntfy           NtfyConfig     `yaml:"ntfy" toml:"ntfy"`
+	Webhook        WebhookConfig  `yaml:"webhook" toml:"webhook"`
+	Slack          ChatConfig     `yaml:"slack" toml:"slack"`
+	Teams          ChatConfig     `yaml:"teams" toml:"teams"`
+	Telegram       TelegramConfig `yaml:"telegram" toml:"telegram"`
+	WebPush        WebPushConfig  `yaml:"webpush" toml:"webpush"`
+	Media          MediaConfig    `yaml:"media" toml:"media"`
+	Callbacks      CallbackConfig `yaml:"callbacks" toml:"callbacks"`
+	// SoftDeleteRetentionDays is how long soft-deleted rows are kept before purging.
+	SoftDeleteRetentionDays int            `yaml:"soft_delete_retention_days" toml:"soft_delete_retention_days"`
+	Shutdown                ShutdownConfig `yaml:"shutdown" toml:"shutdown"`
+	Log                     LogConfig      `yaml:"log" toml:"log"`
+	Tracing                 TracingConfig  `yaml:"tracing" toml:"tracing"`
+}
+
+// TracingConfig selects the OpenTelemetry span exporter. The OTLP exporter
+// itself is configured through the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	// Exporter is "none" (spans are dropped) or "otlp" (OTLP over HTTP).
+	Exporter    string `yaml:"exporter" toml:"exporter"`
+	ServiceName string `yaml:"service_name" toml:"service_name"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn or error.
+	Level string `yaml:"level" toml:"level"`
+}
+
+// SlogLevel parses Level.
+func (c LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.Level))
+	return level, err
+}
+
+type ShutdownConfig struct {
+	// ReadinessDelay is how long the server keeps serving after reporting
+	// not-ready, so load balancers stop routing to it first.
+	ReadinessDelay Duration `yaml:"readiness_delay" toml:"readiness_delay"`
+	// DrainTimeout bounds how long in-flight requests may take to finish.
+	DrainTimeout Duration `yaml:"drain_timeout" toml:"drain_timeout"`
+}
+
+type DatabaseConfig struct {
+	URL      string `yaml:"url" toml:"url"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+}
+
+type SendGridConfig struct {
+	APIKey    string `yaml:"api_key" toml:"api_key"`
+	FromName  string `yaml:"from_name" toml:"from_name"`
+	FromEmail string `yaml:"from_email" toml:"from_email"`
+	// Timeout bounds each call to the SendGrid API.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// SMTPConfig configures email delivery through any SMTP server.
+type SMTPConfig struct {
+	Host string `yaml:"host" toml:"host"`
+	Port string `yaml:"port" toml:"port"`
+	// TLS is "starttls" (upgrade a plain connection), "implicit" (TLS from
+	// the first byte, usually port 465) or "none" for local test servers.
+	TLS      string `yaml:"tls" toml:"tls"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	// Auth is "plain" or "login"; empty picks whichever the server offers.
+	Auth      string `yaml:"auth" toml:"auth"`
+	FromName  string `yaml:"from_name" toml:"from_name"`
+	FromEmail string `yaml:"from_email" toml:"from_email"`
+	// PoolSize is how many idle connections are kept for reuse.
+	PoolSize int `yaml:"pool_size" toml:"pool_size"`
+	// Timeout bounds each send, including dialling and the handshake.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+type TwilioConfig struct {
+	AccountSID  string `yaml:"account_sid" toml:"account_sid"`
+	AuthToken   string `yaml:"auth_token" toml:"auth_token"`
+	PhoneNumber string `yaml:"phone_number" toml:"phone_number"`
+	// VirtualNumber replaces every destination when Env is "development".
+	VirtualNumber string `yaml:"virtual_number" toml:"virtual_number"`
+	// Timeout bounds each call to the Twilio API.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+	// SmartEncoding replaces Unicode look-alikes in SMS bodies with GSM-7
+	// characters by default; messages can still opt in individually.
+	SmartEncoding bool `yaml:"smart_encoding" toml:"smart_encoding"`
+	// SegmentPrice is the cost of one SMS segment, used for estimates.
+	SegmentPrice float64 `yaml:"segment_price" toml:"segment_price"`
+	// Currency is the currency SegmentPrice is in.
+	Currency string `yaml:"currency" toml:"currency"`
+}
+
+// CallbackConfig controls status callbacks POSTed to customer endpoints.
+type CallbackConfig struct {
+	// Timeout bounds each delivery attempt.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+	// MaxAttempts is how many times a delivery is tried before it is marked failed.
+	MaxAttempts int `yaml:"max_attempts" toml:"max_attempts"`
+}
+
+type NtfyConfig struct {
+	// BaseURL is the ntfy server used by integrations that do not set their own.
+	BaseURL string `yaml:"base_url" toml:"base_url"`
+	// Token is the default access token, sent as a bearer token.
+	Token string `yaml:"token" toml:"token"`
+	// Timeout bounds each publish to an ntfy server.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// WebhookConfig controls the outbound webhook provider.
+type WebhookConfig struct {
+	// Timeout bounds each POST to a customer endpoint.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// TelegramConfig controls the Telegram provider. Bot tokens are set per
+// integration.
+type TelegramConfig struct {
+	// BaseURL is the Bot API server used by integrations that do not set their own.
+	BaseURL string `yaml:"base_url" toml:"base_url"`
+	// Timeout bounds each call to the Bot API.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// WebPushConfig controls the Web Push provider. Its VAPID keys are
+// generated and stored by the service on first use.
+type WebPushConfig struct {
+	// Subject is the VAPID contact, a mailto: or https: URL. The provider
+	// is only available when it is set.
+	Subject string `yaml:"subject" toml:"subject"`
+	// TTL is how long push services keep undelivered notifications, unless
+	// the message sets its own.
+	TTL Duration `yaml:"ttl" toml:"ttl"`
+	// Timeout bounds each POST to a push service.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// MediaConfig controls media uploaded for MMS, which carriers fetch from
+// signed URLs under WebhookBaseURL.
+type MediaConfig struct {
+	// SigningKey signs media URLs. Uploads are disabled while it is empty.
+	SigningKey string `yaml:"signing_key" toml:"signing_key"`
+	// URLTTL is how long a signed media URL stays valid.
+	URLTTL Duration `yaml:"url_ttl" toml:"url_ttl"`
+}
+
+// ChatConfig controls a chat provider posting to incoming-webhook URLs
+// (Slack, Microsoft Teams).
+type ChatConfig struct {
+	// Timeout bounds each POST to the webhook URL.
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+	// MaxRetries is how many times a rate-limited (429) post is retried.
+	MaxRetries int `yaml:"max_retries" toml:"max_retries"`
+	// MaxRetryWait is the longest Retry-After that is waited out; a longer
+	// one fails the send straight away.
+	MaxRetryWait Duration `yaml:"max_retry_wait" toml:"max_retry_wait"`
+}
+
+// Configured reports whether SendGrid credentials were provided.
+func (c SendGridConfig) Configured() bool { return c.APIKey != "" }
+
+// Configured reports whether an SMTP server was provided.
+func (c SMTPConfig) Configured() bool { return c.Host != "" }
+
+// Configured reports whether a VAPID contact was provided.
+func (c WebPushConfig) Configured() bool { return c.Subject != "" }
+
+// Configured reports whether Twilio credentials were provided.
+func (c TwilioConfig) Configured() bool { return c.AccountSID != "" || c.AuthToken != "" }
+
+// SoftDeleteRetention returns SoftDeleteRetentionDays as a duration.
+func (c *Config) SoftDeleteRetention() time.Duration {
+	return time.Duration(c.SoftDeleteRetentionDays) * 24 * time.Hour
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config
+// files. TOML has no duration type, so a plain time.Duration field would only
+// accept a count of nanoseconds there.
+type Duration time.Duration
+
+// UnmarshalText parses a duration such as "30s" or "1h30m".
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(strings.TrimSpace(string(text)))
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		return fmt.Errorf("must be a duration such as 30s: %w", err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText writes the duration in the form UnmarshalText reads.
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// Load builds and validates the configuration. A missing .env or config file
+// is not an error; containers usually inject plain environment variables.
+func Load() (*Config, error) {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	cfg := defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	// Values that fail to parse are reported with everything Validate finds.
+	var problems []string
+	for _, err := range []error{applyEnv(cfg), cfg.Validate()} {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			problems = append(problems, verr.Problems...)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func defaults() *Config {
+	return &Config{
+		Env:                     "production",
+		Port:                    "8080",
+		Database:                DatabaseConfig{SSLMode: "disable"},
+		SoftDeleteRetentionDays: 30,
+		Shutdown: ShutdownConfig{
+			ReadinessDelay: Duration(5 * time.Second),
+			DrainTimeout:   Duration(30 * time.Second),
+		},
+		SendGrid: SendGridConfig{Timeout: Duration(10 * time.Second)},
+		SMTP: SMTPConfig{
+			Port:     "587",
+			TLS:      "starttls",
+			PoolSize: 2,
+			Timeout:  Duration(30 * time.Second),
+		},
+		Twilio:   TwilioConfig{Timeout: Duration(10 * time.Second), SegmentPrice: 0.0083, Currency: "USD"},
+		Ntfy:     NtfyConfig{BaseURL: "https://ntfy.sh", Timeout: Duration(10 * time.Second)},
+		Webhook:  WebhookConfig{Timeout: Duration(10 * time.Second)},
+		Slack:    ChatConfig{Timeout: Duration(10 * time.Second), MaxRetries: 2, MaxRetryWait: Duration(30 * time.Second)},
+		Teams:    ChatConfig{Timeout: Duration(10 * time.Second), MaxRetries: 2, MaxRetryWait: Duration(30 * time.Second)},
+		Telegram: TelegramConfig{BaseURL: "https://api.telegram.org", Timeout: Duration(10 * time.Second)},
+		WebPush:  WebPushConfig{TTL: Duration(24 * time.Hour), Timeout: Duration(10 * time.Second)},
+		Media:    MediaConfig{URLTTL: Duration(24 * time.Hour)},
+		Callbacks: CallbackConfig{
+			Timeout:     Duration(10 * time.Second),
+			MaxAttempts: 8,
+		},
+		Log:     LogConfig{Level: "info"},
+		Tracing: TracingConfig{Exporter: "none", ServiceName: "messenger-module"},
+	}
+}
+
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, cfg)
+	case ".toml":
+		err = toml.Unmarshal(raw, cfg)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with any environment variables that are set. Values
+// that do not parse are skipped and all reported in a *ValidationError.
+func applyEnv(cfg *Config) error {
+	var problems []string
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	setString(&cfg.Env, "APP_ENV")
+	setString(&cfg.Port, "PORT")
+	setString(&cfg.WebhookBaseURL, "WEBHOOK_BASE_URL")
+	setString(&cfg.Log.Level, "LOG_LEVEL")
+	setString(&cfg.Tracing.Exporter, "OTEL_TRACES_EXPORTER")
+	setString(&cfg.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+
+	setString(&cfg.Database.URL, "DB_URL")
+	setString(&cfg.Database.Host, "DB_HOST")
+	setString(&cfg.Database.Port, "DB_PORT")
+	setString(&cfg.Database.User, "DB_USER")
+	setString(&cfg.Database.Password, "DB_PASSWORD")
+	setString(&cfg.Database.Name, "DB_NAME")
+	setString(&cfg.Database.SSLMode, "DB_SSLMODE")
+
+	setString(&cfg.SendGrid.APIKey, "SENDGRID_API_KEY")
+	setString(&cfg.SendGrid.FromName, "SENDGRID_FROM_NAME")
+	setString(&cfg.SendGrid.FromEmail, "SENDGRID_FROM_EMAIL")
+
+	setString(&cfg.SMTP.Host, "SMTP_HOST")
+	setString(&cfg.SMTP.Port, "SMTP_PORT")
+	setString(&cfg.SMTP.TLS, "SMTP_TLS")
+	setString(&cfg.SMTP.Username, "SMTP_USERNAME")
+	setString(&cfg.SMTP.Password, "SMTP_PASSWORD")
+	setString(&cfg.SMTP.Auth, "SMTP_AUTH")
+	setString(&cfg.SMTP.FromName, "SMTP_FROM_NAME")
+	setString(&cfg.SMTP.FromEmail, "SMTP_FROM_EMAIL")
+	check(setInt(&cfg.SMTP.PoolSize, "SMTP_POOL_SIZE"))
+
+	setString(&cfg.Ntfy.BaseURL, "NTFY_BASE_URL")
+	setString(&cfg.Ntfy.Token, "NTFY_TOKEN")
+	setString(&cfg.Telegram.BaseURL, "TELEGRAM_BASE_URL")
+	setString(&cfg.WebPush.Subject, "WEBPUSH_SUBJECT")
+	setString(&cfg.Media.SigningKey, "MEDIA_SIGNING_KEY")
+
+	setString(&cfg.Twilio.AccountSID, "TWILIO_ACCOUNT_SID")
+	setString(&cfg.Twilio.AuthToken, "TWILIO_AUTH_TOKEN")
+	setString(&cfg.Twilio.PhoneNumber, "TWILIO_PHONE_NUMBER")
+	setString(&cfg.Twilio.VirtualNumber, "TWILIO_VIRTUAL_NUMBER")
+	setString(&cfg.Twilio.Currency, "TWILIO_CURRENCY")
+	check(setBool(&cfg.Twilio.SmartEncoding, "TWILIO_SMART_ENCODING"))
+	check(setFloat(&cfg.Twilio.SegmentPrice, "TWILIO_SEGMENT_PRICE"))
+	for _, t := range []struct {
+		dst *Duration
+		key string
+	}{
+		{&cfg.SendGrid.Timeout, "SENDGRID_TIMEOUT"},
+		{&cfg.SMTP.Timeout, "SMTP_TIMEOUT"},
+		{&cfg.Twilio.Timeout, "TWILIO_TIMEOUT"},
+		{&cfg.Ntfy.Timeout, "NTFY_TIMEOUT"},
+		{&cfg.Webhook.Timeout, "WEBHOOK_TIMEOUT"},
+		{&cfg.Slack.Timeout, "SLACK_TIMEOUT"},
+		{&cfg.Slack.MaxRetryWait, "SLACK_MAX_RETRY_WAIT"},
+		{&cfg.Teams.Timeout, "TEAMS_TIMEOUT"},
+		{&cfg.Teams.MaxRetryWait, "TEAMS_MAX_RETRY_WAIT"},
+		{&cfg.Telegram.Timeout, "TELEGRAM_TIMEOUT"},
+		{&cfg.WebPush.TTL, "WEBPUSH_TTL"},
+		{&cfg.WebPush.Timeout, "WEBPUSH_TIMEOUT"},
+		{&cfg.Media.URLTTL, "MEDIA_URL_TTL"},
+		{&cfg.Callbacks.Timeout, "CALLBACK_TIMEOUT"},
+	} {
+		check(setDuration(t.dst, t.key))
+	}
+	check(setInt(&cfg.Slack.MaxRetries, "SLACK_MAX_RETRIES"))
+	check(setInt(&cfg.Teams.MaxRetries, "TEAMS_MAX_RETRIES"))
+	check(setInt(&cfg.Callbacks.MaxAttempts, "CALLBACK_MAX_ATTEMPTS"))
+
+	check(setInt(&cfg.SoftDeleteRetentionDays, "SOFT_DELETE_RETENTION_DAYS"))
+	check(setDuration(&cfg.Shutdown.ReadinessDelay, "SHUTDOWN_READINESS_DELAY"))
+	check(setDuration(&cfg.Shutdown.DrainTimeout, "SHUTDOWN_DRAIN_TIMEOUT"))
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func setString(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = strings.TrimSpace(v)
+	}
+}
+
+func setInt(dst *int, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func setBool(dst *bool, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%s must be true or false: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func setFloat(dst *float64, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number: %w", key, err)
+	}
+	*dst = f
+	return nil
+}
+
+func setDuration(dst *Duration, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return nil
+	}
+	if err := dst.UnmarshalText([]byte(v)); err != nil {
+		return fmt.Errorf("%s %w", key, err)
 	}
 	return nil
 }
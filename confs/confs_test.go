@@ -0,0 +1,122 @@
+package confs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFile(t *testing.T) {
+	for _, path := range []string{"testdata/config.toml", "testdata/config.yaml"} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			cfg := defaults()
+			if err := loadFile(path, cfg); err != nil {
+				t.Fatal(err)
+			}
+			checks := []struct {
+				name string
+				got  Duration
+				want time.Duration
+			}{
+				{"shutdown.readiness_delay", cfg.Shutdown.ReadinessDelay, 2 * time.Second},
+				{"shutdown.drain_timeout", cfg.Shutdown.DrainTimeout, time.Minute},
+				{"twilio.timeout", cfg.Twilio.Timeout, 15 * time.Second},
+				{"slack.max_retry_wait", cfg.Slack.MaxRetryWait, 90 * time.Second},
+				{"media.url_ttl", cfg.Media.URLTTL, 12 * time.Hour},
+				// Not in the file, so the default stays.
+				{"sendgrid.timeout", cfg.SendGrid.Timeout, 10 * time.Second},
+			}
+			for _, c := range checks {
+				if time.Duration(c.got) != c.want {
+					t.Errorf("%s = %s, want %s", c.name, c.got, c.want)
+				}
+			}
+			if cfg.Port != "9090" || cfg.SoftDeleteRetentionDays != 7 || !cfg.Twilio.SmartEncoding || cfg.Slack.MaxRetries != 3 {
+				t.Errorf("cfg = %+v", cfg)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoadFileBadDuration(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"config.toml", "[twilio]\ntimeout = \"soon\"\n"},
+		{"config.yaml", "twilio:\n  timeout: soon\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		err := loadFile(path, defaults())
+		if err == nil || !strings.Contains(err.Error(), "must be a duration") {
+			t.Errorf("%s: err = %v, want a duration error", tt.name, err)
+		}
+	}
+}
+
+func TestApplyEnvReportsEveryBadValue(t *testing.T) {
+	env := map[string]string{
+		"SMTP_POOL_SIZE":             "two",
+		"TWILIO_SMART_ENCODING":      "maybe",
+		"TWILIO_SEGMENT_PRICE":       "cheap",
+		"SLACK_TIMEOUT":              "10",
+		"SHUTDOWN_DRAIN_TIMEOUT":     "forever",
+		"SOFT_DELETE_RETENTION_DAYS": "30d",
+		// Good values are still applied.
+		"PORT":           "9000",
+		"TWILIO_TIMEOUT": "20s",
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	cfg := defaults()
+	err := applyEnv(cfg)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a *ValidationError", err)
+	}
+	var keys []string
+	for _, p := range verr.Problems {
+		keys = append(keys, strings.Fields(p)[0])
+	}
+	want := []string{"SMTP_POOL_SIZE", "TWILIO_SMART_ENCODING", "TWILIO_SEGMENT_PRICE", "SLACK_TIMEOUT", "SOFT_DELETE_RETENTION_DAYS", "SHUTDOWN_DRAIN_TIMEOUT"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("problems for %v, want %v", keys, want)
+	}
+	if cfg.Port != "9000" || time.Duration(cfg.Twilio.Timeout) != 20*time.Second {
+		t.Errorf("good values not applied: port %q, twilio timeout %s", cfg.Port, cfg.Twilio.Timeout)
+	}
+	if time.Duration(cfg.Slack.Timeout) != 10*time.Second {
+		t.Errorf("bad SLACK_TIMEOUT replaced the default: %s", cfg.Slack.Timeout)
+	}
+}
+
+func TestLoadReportsEnvAndValidationProblemsTogether(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_URL", "postgres://localhost/messenger")
+	t.Setenv("SMTP_POOL_SIZE", "two")
+	t.Setenv("LOG_LEVEL", "loud")
+
+	_, err := Load()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a *ValidationError", err)
+	}
+	msg := verr.Error()
+	for _, key := range []string{"SMTP_POOL_SIZE", "LOG_LEVEL"} {
+		if !strings.Contains(msg, key) {
+			t.Errorf("%q does not mention %s", msg, key)
+		}
+	}
+}
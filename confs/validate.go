@@ -0,0 +1,167 @@
+package confs
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ValidationError lists every problem found in a Config so operators can fix
+// them in one go.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the whole configuration and returns a *ValidationError
+// aggregating all problems, or nil.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if p, err := strconv.Atoi(c.Port); err != nil || p <= 0 || p > 65535 {
+		add("PORT must be a TCP port number, got %q", c.Port)
+	}
+	if c.WebhookBaseURL != "" {
+		if u, err := url.Parse(c.WebhookBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			add("WEBHOOK_BASE_URL must be an absolute URL, got %q", c.WebhookBaseURL)
+		}
+	}
+	if _, err := c.Log.SlogLevel(); err != nil {
+		add("LOG_LEVEL must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+	if c.Tracing.Exporter != "none" && c.Tracing.Exporter != "otlp" {
+		add("OTEL_TRACES_EXPORTER must be none or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.SoftDeleteRetentionDays <= 0 {
+		add("SOFT_DELETE_RETENTION_DAYS must be positive, got %d", c.SoftDeleteRetentionDays)
+	}
+	if c.Shutdown.ReadinessDelay < 0 {
+		add("SHUTDOWN_READINESS_DELAY must not be negative, got %s", c.Shutdown.ReadinessDelay)
+	}
+	if c.Shutdown.DrainTimeout <= 0 {
+		add("SHUTDOWN_DRAIN_TIMEOUT must be positive, got %s", c.Shutdown.DrainTimeout)
+	}
+
+	db := c.Database
+	if db.URL == "" {
+		var missing []string
+		for _, f := range []struct{ key, val string }{
+			{"DB_HOST", db.Host}, {"DB_PORT", db.Port}, {"DB_USER", db.User}, {"DB_NAME", db.Name},
+		} {
+			if f.val == "" {
+				missing = append(missing, f.key)
+			}
+		}
+		if len(missing) > 0 {
+			add("database: set DB_URL or %s", strings.Join(missing, ", "))
+		}
+	}
+
+	for _, t := range []struct {
+		key string
+		d   Duration
+	}{
+		{"SENDGRID_TIMEOUT", c.SendGrid.Timeout},
+		{"SMTP_TIMEOUT", c.SMTP.Timeout},
+		{"TWILIO_TIMEOUT", c.Twilio.Timeout},
+		{"NTFY_TIMEOUT", c.Ntfy.Timeout},
+		{"WEBHOOK_TIMEOUT", c.Webhook.Timeout},
+		{"SLACK_TIMEOUT", c.Slack.Timeout},
+		{"TEAMS_TIMEOUT", c.Teams.Timeout},
+		{"TELEGRAM_TIMEOUT", c.Telegram.Timeout},
+		{"WEBPUSH_TIMEOUT", c.WebPush.Timeout},
+		{"CALLBACK_TIMEOUT", c.Callbacks.Timeout},
+	} {
+		if t.d <= 0 {
+			add("%s must be positive, got %s", t.key, t.d)
+		}
+	}
+	if c.Callbacks.MaxAttempts <= 0 {
+		add("CALLBACK_MAX_ATTEMPTS must be positive, got %d", c.Callbacks.MaxAttempts)
+	}
+	for _, chat := range []struct {
+		prefix string
+		cfg    ChatConfig
+	}{{"SLACK", c.Slack}, {"TEAMS", c.Teams}} {
+		if chat.cfg.MaxRetries < 0 {
+			add("%s_MAX_RETRIES must not be negative, got %d", chat.prefix, chat.cfg.MaxRetries)
+		}
+		if chat.cfg.MaxRetryWait < 0 {
+			add("%s_MAX_RETRY_WAIT must not be negative, got %s", chat.prefix, chat.cfg.MaxRetryWait)
+		}
+	}
+
+	if c.SendGrid.Configured() && c.SendGrid.FromEmail == "" {
+		add("SENDGRID_FROM_EMAIL is required when SENDGRID_API_KEY is set")
+	}
+
+	if u, err := url.Parse(c.Ntfy.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("NTFY_BASE_URL must be an absolute http(s) URL, got %q", c.Ntfy.BaseURL)
+	}
+	if u, err := url.Parse(c.Telegram.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("TELEGRAM_BASE_URL must be an absolute http(s) URL, got %q", c.Telegram.BaseURL)
+	}
+
+	if c.WebPush.Configured() && !strings.HasPrefix(c.WebPush.Subject, "mailto:") && !strings.HasPrefix(c.WebPush.Subject, "https://") {
+		add("WEBPUSH_SUBJECT must be a mailto: or https: URL, got %q", c.WebPush.Subject)
+	}
+	if c.WebPush.TTL < 0 {
+		add("WEBPUSH_TTL must not be negative, got %s", c.WebPush.TTL)
+	}
+
+	if c.Media.SigningKey != "" && len(c.Media.SigningKey) < 32 {
+		add("MEDIA_SIGNING_KEY must be at least 32 characters")
+	}
+	if c.Media.URLTTL <= 0 {
+		add("MEDIA_URL_TTL must be positive, got %s", c.Media.URLTTL)
+	}
+
+	if c.SMTP.Configured() {
+		if p, err := strconv.Atoi(c.SMTP.Port); err != nil || p <= 0 || p > 65535 {
+			add("SMTP_PORT must be a TCP port number, got %q", c.SMTP.Port)
+		}
+		switch c.SMTP.TLS {
+		case "starttls", "implicit", "none":
+		default:
+			add("SMTP_TLS must be starttls, implicit or none, got %q", c.SMTP.TLS)
+		}
+		switch c.SMTP.Auth {
+		case "", "plain", "login":
+		default:
+			add("SMTP_AUTH must be plain or login, got %q", c.SMTP.Auth)
+		}
+		if c.SMTP.FromEmail == "" {
+			add("SMTP_FROM_EMAIL is required when SMTP_HOST is set")
+		}
+		if c.SMTP.PoolSize < 0 {
+			add("SMTP_POOL_SIZE must not be negative, got %d", c.SMTP.PoolSize)
+		}
+	}
+
+	if c.Twilio.Configured() {
+		if c.Twilio.AccountSID == "" {
+			add("TWILIO_ACCOUNT_SID is required when Twilio is configured")
+		}
+		if c.Twilio.AuthToken == "" {
+			add("TWILIO_AUTH_TOKEN is required when Twilio is configured")
+		}
+		if c.Twilio.PhoneNumber == "" {
+			add("TWILIO_PHONE_NUMBER is required when Twilio is configured")
+		}
+	}
+	if c.Twilio.SegmentPrice < 0 {
+		add("TWILIO_SEGMENT_PRICE must not be negative, got %g", c.Twilio.SegmentPrice)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
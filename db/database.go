@@ -11,3 +11,11 @@ type GormDatabase struct {
 func (g *GormDatabase) GetDB() *gorm.DB {
 	return g.DB
 }
+
+func (g *GormDatabase) Close() error {
+	sqlDB, err := g.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -6,4 +6,6 @@ import (
 
 type Database interface {
 	GetDB() *gorm.DB
+	// Close releases the underlying connection pool.
+	Close() error
 }
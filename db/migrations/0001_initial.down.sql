@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS message_status_models;
+DROP TABLE IF EXISTS message_models;
+DROP TABLE IF EXISTS integration_models;
+DROP TABLE IF EXISTS user_plan_models;
+DROP TABLE IF EXISTS plan_models;
+DROP TABLE IF EXISTS user_models;
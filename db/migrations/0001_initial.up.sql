@@ -0,0 +1,78 @@
+-- Baseline schema as previously created by GORM AutoMigrate.
+CREATE TABLE IF NOT EXISTS user_models (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    deleted_at timestamptz,
+    name       text NOT NULL,
+    api_key    text NOT NULL UNIQUE,
+    active     boolean NOT NULL DEFAULT true
+);
+
+CREATE TABLE IF NOT EXISTS plan_models (
+    id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    deleted_at  timestamptz,
+    name        text NOT NULL UNIQUE,
+    price_cents bigint NOT NULL,
+    external_id text
+);
+
+CREATE TABLE IF NOT EXISTS user_plan_models (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    deleted_at timestamptz,
+    user_id    text NOT NULL,
+    plan_id    text NOT NULL,
+    active     boolean NOT NULL DEFAULT true
+);
+CREATE INDEX IF NOT EXISTS idx_user_plan_models_user_id ON user_plan_models (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_plan_models_plan_id ON user_plan_models (plan_id);
+
+CREATE TABLE IF NOT EXISTS integration_models (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    deleted_at timestamptz,
+    name       text NOT NULL,
+    type       text NOT NULL,
+    api_key    text NOT NULL,
+    plan_id    text
+);
+CREATE INDEX IF NOT EXISTS idx_integration_models_plan_id ON integration_models (plan_id);
+
+CREATE TABLE IF NOT EXISTS message_models (
+    id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    updated_at     timestamptz NOT NULL DEFAULT now(),
+    deleted_at     timestamptz,
+    user_id        text NOT NULL,
+    integration_id text NOT NULL,
+    type           text NOT NULL,
+    subject        text,
+    content        text NOT NULL,
+    destination    text NOT NULL,
+    external_id    text
+);
+CREATE INDEX IF NOT EXISTS idx_message_models_user_id ON message_models (user_id);
+CREATE INDEX IF NOT EXISTS idx_message_models_integration_id ON message_models (integration_id);
+
+CREATE TABLE IF NOT EXISTS message_status_models (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    external_id      text,
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now(),
+    deleted_at       timestamptz,
+    message_id       text NOT NULL,
+    status           text NOT NULL,
+    gateway_response text,
+    date_sent        timestamptz,
+    date_opened      timestamptz,
+    date_error       timestamptz,
+    date_canceled    timestamptz,
+    date_deferred    timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_message_status_models_external_id ON message_status_models (external_id);
+CREATE INDEX IF NOT EXISTS idx_message_status_models_message_id ON message_status_models (message_id);
@@ -0,0 +1,7 @@
+-- Plaintext keys cannot be recovered from their hashes, so users get fresh ones.
+ALTER TABLE user_models ADD COLUMN IF NOT EXISTS api_key text;
+UPDATE user_models SET api_key = gen_random_uuid()::text WHERE api_key IS NULL;
+ALTER TABLE user_models ALTER COLUMN api_key SET NOT NULL;
+ALTER TABLE user_models ADD CONSTRAINT user_models_api_key_key UNIQUE (api_key);
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now(),
+    deleted_at   timestamptz,
+    user_id      text NOT NULL,
+    name         text NOT NULL,
+    prefix       text NOT NULL,
+    key_hash     text NOT NULL,
+    scopes       text NOT NULL,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+
+-- Move plaintext user keys into hashed admin keys.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'user_models' AND column_name = 'api_key') THEN
+        INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+        SELECT id::text, 'legacy', left(api_key, 8), encode(sha256(convert_to(api_key, 'UTF8')), 'hex'), 'admin'
+        FROM user_models
+        WHERE api_key <> ''
+        ON CONFLICT (key_hash) DO NOTHING;
+
+        ALTER TABLE user_models DROP COLUMN api_key;
+    END IF;
+END $$;
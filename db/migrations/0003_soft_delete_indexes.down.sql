@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_user_models_deleted_at;
+DROP INDEX IF EXISTS idx_plan_models_deleted_at;
+DROP INDEX IF EXISTS idx_user_plan_models_deleted_at;
+DROP INDEX IF EXISTS idx_integration_models_deleted_at;
+DROP INDEX IF EXISTS idx_message_models_deleted_at;
+DROP INDEX IF EXISTS idx_message_status_models_deleted_at;
+DROP INDEX IF EXISTS idx_api_keys_deleted_at;
@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS idx_user_models_deleted_at ON user_models (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_plan_models_deleted_at ON plan_models (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_user_plan_models_deleted_at ON user_plan_models (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_integration_models_deleted_at ON integration_models (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_message_models_deleted_at ON message_models (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_message_status_models_deleted_at ON message_status_models (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_api_keys_deleted_at ON api_keys (deleted_at);
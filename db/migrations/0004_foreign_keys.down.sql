@@ -0,0 +1,18 @@
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS fk_api_keys_user;
+ALTER TABLE message_status_models DROP CONSTRAINT IF EXISTS fk_message_status_models_message;
+ALTER TABLE message_models DROP CONSTRAINT IF EXISTS fk_message_models_integration;
+ALTER TABLE message_models DROP CONSTRAINT IF EXISTS fk_message_models_user;
+ALTER TABLE integration_models DROP CONSTRAINT IF EXISTS fk_integration_models_plan;
+ALTER TABLE user_plan_models DROP CONSTRAINT IF EXISTS fk_user_plan_models_plan;
+ALTER TABLE user_plan_models DROP CONSTRAINT IF EXISTS fk_user_plan_models_user;
+
+ALTER TABLE api_keys ALTER COLUMN user_id TYPE text;
+ALTER TABLE message_status_models ALTER COLUMN message_id TYPE text;
+ALTER TABLE message_models ALTER COLUMN integration_id TYPE text;
+ALTER TABLE message_models ALTER COLUMN user_id TYPE text;
+ALTER TABLE integration_models ALTER COLUMN plan_id TYPE text;
+ALTER TABLE user_plan_models ALTER COLUMN plan_id TYPE text;
+ALTER TABLE user_plan_models ALTER COLUMN user_id TYPE text;
+
+-- The orphaned_* tables written by the up migration are left in place: they
+-- may hold the only copy of those rows.
@@ -0,0 +1,74 @@
+-- Rows whose parent no longer exists cannot satisfy the foreign keys below.
+-- They are moved to orphaned_* tables rather than deleted, so an operator can
+-- review them, and drop the tables once done.
+CREATE TABLE IF NOT EXISTS orphaned_integration_plans (integration_id text, plan_id text);
+INSERT INTO orphaned_integration_plans
+SELECT id::text, plan_id::text FROM integration_models
+WHERE plan_id::text = '' OR plan_id::text NOT IN (SELECT id::text FROM plan_models);
+UPDATE integration_models SET plan_id = NULL
+WHERE plan_id::text = '' OR plan_id::text NOT IN (SELECT id::text FROM plan_models);
+
+CREATE TABLE IF NOT EXISTS orphaned_user_plan_models (LIKE user_plan_models);
+WITH moved AS (
+    DELETE FROM user_plan_models
+    WHERE plan_id::text NOT IN (SELECT id::text FROM plan_models)
+       OR user_id::text NOT IN (SELECT id::text FROM user_models)
+    RETURNING *
+)
+INSERT INTO orphaned_user_plan_models SELECT * FROM moved;
+
+CREATE TABLE IF NOT EXISTS orphaned_message_models (LIKE message_models);
+WITH moved AS (
+    DELETE FROM message_models
+    WHERE user_id::text NOT IN (SELECT id::text FROM user_models)
+       OR integration_id::text NOT IN (SELECT id::text FROM integration_models)
+    RETURNING *
+)
+INSERT INTO orphaned_message_models SELECT * FROM moved;
+
+-- Includes the statuses of messages moved above.
+CREATE TABLE IF NOT EXISTS orphaned_message_status_models (LIKE message_status_models);
+WITH moved AS (
+    DELETE FROM message_status_models
+    WHERE message_id::text NOT IN (SELECT id::text FROM message_models)
+    RETURNING *
+)
+INSERT INTO orphaned_message_status_models SELECT * FROM moved;
+
+CREATE TABLE IF NOT EXISTS orphaned_api_keys (LIKE api_keys);
+WITH moved AS (
+    DELETE FROM api_keys WHERE user_id::text NOT IN (SELECT id::text FROM user_models)
+    RETURNING *
+)
+INSERT INTO orphaned_api_keys SELECT * FROM moved;
+
+-- Reference columns must match the uuid primary keys.
+ALTER TABLE user_plan_models ALTER COLUMN user_id TYPE uuid USING user_id::uuid;
+ALTER TABLE user_plan_models ALTER COLUMN plan_id TYPE uuid USING plan_id::uuid;
+ALTER TABLE integration_models ALTER COLUMN plan_id TYPE uuid USING plan_id::uuid;
+ALTER TABLE message_models ALTER COLUMN user_id TYPE uuid USING user_id::uuid;
+ALTER TABLE message_models ALTER COLUMN integration_id TYPE uuid USING integration_id::uuid;
+ALTER TABLE message_status_models ALTER COLUMN message_id TYPE uuid USING message_id::uuid;
+ALTER TABLE api_keys ALTER COLUMN user_id TYPE uuid USING user_id::uuid;
+
+ALTER TABLE user_plan_models DROP CONSTRAINT IF EXISTS fk_user_plan_models_user;
+ALTER TABLE user_plan_models ADD CONSTRAINT fk_user_plan_models_user
+    FOREIGN KEY (user_id) REFERENCES user_models (id) ON DELETE CASCADE;
+ALTER TABLE user_plan_models DROP CONSTRAINT IF EXISTS fk_user_plan_models_plan;
+ALTER TABLE user_plan_models ADD CONSTRAINT fk_user_plan_models_plan
+    FOREIGN KEY (plan_id) REFERENCES plan_models (id) ON DELETE RESTRICT;
+ALTER TABLE integration_models DROP CONSTRAINT IF EXISTS fk_integration_models_plan;
+ALTER TABLE integration_models ADD CONSTRAINT fk_integration_models_plan
+    FOREIGN KEY (plan_id) REFERENCES plan_models (id) ON DELETE RESTRICT;
+ALTER TABLE message_models DROP CONSTRAINT IF EXISTS fk_message_models_user;
+ALTER TABLE message_models ADD CONSTRAINT fk_message_models_user
+    FOREIGN KEY (user_id) REFERENCES user_models (id) ON DELETE CASCADE;
+ALTER TABLE message_models DROP CONSTRAINT IF EXISTS fk_message_models_integration;
+ALTER TABLE message_models ADD CONSTRAINT fk_message_models_integration
+    FOREIGN KEY (integration_id) REFERENCES integration_models (id) ON DELETE CASCADE;
+ALTER TABLE message_status_models DROP CONSTRAINT IF EXISTS fk_message_status_models_message;
+ALTER TABLE message_status_models ADD CONSTRAINT fk_message_status_models_message
+    FOREIGN KEY (message_id) REFERENCES message_models (id) ON DELETE CASCADE;
+ALTER TABLE api_keys DROP CONSTRAINT IF EXISTS fk_api_keys_user;
+ALTER TABLE api_keys ADD CONSTRAINT fk_api_keys_user
+    FOREIGN KEY (user_id) REFERENCES user_models (id) ON DELETE CASCADE;
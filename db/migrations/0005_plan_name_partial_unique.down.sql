@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_plan_models_name_live;
+ALTER TABLE plan_models ADD CONSTRAINT plan_models_name_key UNIQUE (name);
@@ -0,0 +1,5 @@
+-- Plan names only need to be unique among live plans so a soft-deleted plan
+-- does not block re-creating one with the same name.
+ALTER TABLE plan_models DROP CONSTRAINT IF EXISTS plan_models_name_key;
+ALTER TABLE plan_models DROP CONSTRAINT IF EXISTS uni_plan_models_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plan_models_name_live ON plan_models (lower(name)) WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_message_models_trace_id;
+ALTER TABLE message_models DROP COLUMN IF EXISTS trace_id;
@@ -0,0 +1,3 @@
+-- Trace ID of the request that created the message, for correlating with traces.
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS trace_id text;
+CREATE INDEX IF NOT EXISTS idx_message_models_trace_id ON message_models (trace_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+ALTER TABLE message_models DROP COLUMN IF EXISTS callback_url;
+ALTER TABLE user_models DROP COLUMN IF EXISTS callback_secret;
+ALTER TABLE user_models DROP COLUMN IF EXISTS callback_url;
@@ -0,0 +1,30 @@
+-- Customer status callbacks: a per-user endpoint and signing secret, an
+-- optional per-message override, and a log of every delivery.
+ALTER TABLE user_models ADD COLUMN IF NOT EXISTS callback_url text;
+ALTER TABLE user_models ADD COLUMN IF NOT EXISTS callback_secret text;
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS callback_url text;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at        timestamptz NOT NULL DEFAULT now(),
+    updated_at        timestamptz NOT NULL DEFAULT now(),
+    deleted_at        timestamptz,
+    user_id           uuid NOT NULL REFERENCES user_models (id) ON DELETE CASCADE,
+    message_id        uuid NOT NULL REFERENCES message_models (id) ON DELETE CASCADE,
+    message_status_id uuid REFERENCES message_status_models (id) ON DELETE CASCADE,
+    redelivery_of     uuid REFERENCES webhook_deliveries (id) ON DELETE SET NULL,
+    url               text NOT NULL,
+    event             text NOT NULL,
+    payload           text NOT NULL,
+    state             text NOT NULL DEFAULT 'pending',
+    attempts          integer NOT NULL DEFAULT 0,
+    next_attempt_at   timestamptz,
+    last_attempt_at   timestamptz,
+    response_code     integer,
+    response_body     text,
+    last_error        text
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_deleted_at ON webhook_deliveries (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_user_id ON webhook_deliveries (user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_message_id ON webhook_deliveries (message_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE state = 'pending';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_message_models_external_id;
+DROP INDEX IF EXISTS idx_message_models_conversation_id;
+ALTER TABLE message_models DROP COLUMN IF EXISTS conversation_id;
+ALTER TABLE message_models DROP COLUMN IF EXISTS from_number;
+ALTER TABLE message_models DROP COLUMN IF EXISTS direction;
+DROP TABLE IF EXISTS conversations;
+DROP INDEX IF EXISTS idx_integration_models_phone_number;
+ALTER TABLE integration_models DROP COLUMN IF EXISTS phone_number;
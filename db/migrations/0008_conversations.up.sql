@@ -0,0 +1,24 @@
+-- Two-way SMS: integrations own a number, messages carry a direction and
+-- sender, and SMS is threaded into conversations per (our, their) number.
+ALTER TABLE integration_models ADD COLUMN IF NOT EXISTS phone_number text;
+CREATE INDEX IF NOT EXISTS idx_integration_models_phone_number ON integration_models (phone_number);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    updated_at      timestamptz NOT NULL DEFAULT now(),
+    user_id         uuid NOT NULL REFERENCES user_models (id) ON DELETE CASCADE,
+    integration_id  uuid NOT NULL REFERENCES integration_models (id) ON DELETE CASCADE,
+    our_number      text NOT NULL,
+    their_number    text NOT NULL,
+    last_message_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_numbers ON conversations (user_id, our_number, their_number);
+CREATE INDEX IF NOT EXISTS idx_conversations_integration_id ON conversations (integration_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_lookup ON conversations (our_number, their_number, last_message_at DESC);
+
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS direction text NOT NULL DEFAULT 'outbound';
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS from_number text;
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS conversation_id uuid REFERENCES conversations (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_message_models_conversation_id ON message_models (conversation_id);
+CREATE INDEX IF NOT EXISTS idx_message_models_external_id ON message_models (external_id);
@@ -0,0 +1 @@
+ALTER TABLE message_models DROP COLUMN IF EXISTS html_content;
@@ -0,0 +1,2 @@
+-- HTML part of email messages, alongside the plain-text content.
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS html_content text;
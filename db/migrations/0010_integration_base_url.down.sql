@@ -0,0 +1 @@
+ALTER TABLE integration_models DROP COLUMN IF EXISTS base_url;
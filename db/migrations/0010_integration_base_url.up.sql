@@ -0,0 +1,3 @@
+-- Per-integration provider server, e.g. a self-hosted ntfy. The existing
+-- api_key column holds the integration's access token.
+ALTER TABLE integration_models ADD COLUMN IF NOT EXISTS base_url text;
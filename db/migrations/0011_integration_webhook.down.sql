@@ -0,0 +1,2 @@
+ALTER TABLE integration_models DROP COLUMN IF EXISTS headers;
+ALTER TABLE integration_models DROP COLUMN IF EXISTS payload_template;
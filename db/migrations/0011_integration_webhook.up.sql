@@ -0,0 +1,4 @@
+-- Webhook provider settings: a JSON payload template and custom headers
+-- (a JSON object). The signing secret lives in api_key.
+ALTER TABLE integration_models ADD COLUMN IF NOT EXISTS payload_template text;
+ALTER TABLE integration_models ADD COLUMN IF NOT EXISTS headers text;
@@ -0,0 +1,22 @@
+-- Web Push: the service's VAPID key pair and browser subscriptions per end
+-- user. Subscriptions the push service reports gone are expired, not deleted.
+CREATE TABLE IF NOT EXISTS vapid_keys (
+    id          text PRIMARY KEY,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    public_key  text NOT NULL,
+    private_key text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    user_id    uuid NOT NULL REFERENCES user_models (id) ON DELETE CASCADE,
+    subscriber text NOT NULL,
+    endpoint   text NOT NULL,
+    p256dh     text NOT NULL,
+    auth       text NOT NULL,
+    expired_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_push_subscriptions_endpoint ON push_subscriptions (endpoint);
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_subscriber ON push_subscriptions (user_id, subscriber);
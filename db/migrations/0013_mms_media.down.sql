@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS media;
+ALTER TABLE message_models DROP COLUMN IF EXISTS media_urls;
@@ -0,0 +1,14 @@
+-- MMS: messages keep their media URLs (a JSON array), and uploaded media is
+-- stored here and served to carriers from signed URLs.
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS media_urls text;
+
+CREATE TABLE IF NOT EXISTS media (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    user_id      uuid NOT NULL REFERENCES user_models (id) ON DELETE CASCADE,
+    filename     text,
+    content_type text NOT NULL,
+    size         bigint NOT NULL,
+    data         bytea NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE message_models DROP COLUMN IF EXISTS segments;
+ALTER TABLE message_models DROP COLUMN IF EXISTS encoding;
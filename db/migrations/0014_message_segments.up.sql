@@ -0,0 +1,4 @@
+-- SMS messages record the encoding they were sent in and how many segments
+-- they took, which is what carriers bill for.
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS encoding text;
+ALTER TABLE message_models ADD COLUMN IF NOT EXISTS segments integer NOT NULL DEFAULT 0;
@@ -2,71 +2,175 @@ package db
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserModel struct {
-	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	CreatedAt time.Time `gorm:"not null;default:now()"`
-	UpdatedAt time.Time `gorm:"not null;default:now()"`
-	DeletedAt *time.Time
-	Name      string `gorm:"not null"`
-	APIKey    string `gorm:"not null;unique"`
-	Active    bool   `gorm:"not null;default:true"`
+	ID        string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt time.Time      `gorm:"not null;default:now()"`
+	UpdatedAt time.Time      `gorm:"not null;default:now()"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string         `gorm:"not null"`
+	Active    bool           `gorm:"not null;default:true"`
+	// CallbackURL receives status callbacks signed with CallbackSecret.
+	CallbackURL    string
+	CallbackSecret string
+}
+
+type APIKeyModel struct {
+	ID         string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt  time.Time      `gorm:"not null;default:now()"`
+	UpdatedAt  time.Time      `gorm:"not null;default:now()"`
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	UserID     string         `gorm:"not null;index;type:uuid"`
+	User       *UserModel     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Name       string         `gorm:"not null"`
+	Prefix     string         `gorm:"not null;index"`
+	KeyHash    string         `gorm:"not null;uniqueIndex"`
+	Scopes     string         `gorm:"not null"` // comma-separated
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
 }
 
+func (APIKeyModel) TableName() string { return "api_keys" }
+
 type PlanModel struct {
-	ID         string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	CreatedAt  time.Time `gorm:"not null;default:now()"`
-	UpdatedAt  time.Time `gorm:"not null;default:now()"`
-	DeletedAt  *time.Time
-	Name       string `gorm:"not null;unique"`
-	PriceCents int    `gorm:"not null"`
+	ID         string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt  time.Time      `gorm:"not null;default:now()"`
+	UpdatedAt  time.Time      `gorm:"not null;default:now()"`
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	Name       string         `gorm:"not null"` // unique among live plans, see migrations/0005
+	PriceCents int            `gorm:"not null"`
 	ExternalID string
 }
 
 type UserPlanModel struct {
-	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	CreatedAt time.Time `gorm:"not null;default:now()"`
-	UpdatedAt time.Time `gorm:"not null;default:now()"`
-	DeletedAt *time.Time
-	UserID    string `gorm:"not null;index"`
-	PlanID    string `gorm:"not null;index"`
-	Active    bool   `gorm:"not null;default:true"`
+	ID        string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt time.Time      `gorm:"not null;default:now()"`
+	UpdatedAt time.Time      `gorm:"not null;default:now()"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	UserID    string         `gorm:"not null;index;type:uuid"`
+	User      *UserModel     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	PlanID    string         `gorm:"not null;index;type:uuid"`
+	Plan      *PlanModel     `gorm:"foreignKey:PlanID;constraint:OnDelete:RESTRICT"`
+	Active    bool           `gorm:"not null;default:true"`
 }
 
 type IntegrationModel struct {
-	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	CreatedAt time.Time `gorm:"not null;default:now()"`
-	UpdatedAt time.Time `gorm:"not null;default:now()"`
-	DeletedAt *time.Time
-	Name      string `gorm:"not null"`
-	Type      string `gorm:"not null"`
-	APIKey    string `gorm:"not null"`
-	PlanID    string `gorm:"index"`
+	ID        string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt time.Time      `gorm:"not null;default:now()"`
+	UpdatedAt time.Time      `gorm:"not null;default:now()"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string         `gorm:"not null"`
+	Type      string         `gorm:"not null"`
+	APIKey    string         `gorm:"not null"`
+	PlanID    *string        `gorm:"index;type:uuid"`
+	Plan      *PlanModel     `gorm:"foreignKey:PlanID;constraint:OnDelete:RESTRICT"`
+	// PhoneNumber is the number SMS integrations send from and receive on.
+	PhoneNumber string `gorm:"index"`
+	BaseURL     string
+	// PayloadTemplate and Headers (a JSON object) configure webhook requests.
+	PayloadTemplate string
+	Headers         string
 }
 
 type MessageModel struct {
-	ID            string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	CreatedAt     time.Time `gorm:"not null;default:now()"`
-	UpdatedAt     time.Time `gorm:"not null;default:now()"`
-	DeletedAt     *time.Time
-	UserID        string `gorm:"not null;index"`
-	IntegrationID string `gorm:"not null;index"`
-	Type          string `gorm:"not null"`
-	Subject       string
-	Content       string `gorm:"not null"`
-	Destination   string `gorm:"not null"`
-	ExternalID    string
+	ID             string            `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt      time.Time         `gorm:"not null;default:now()"`
+	UpdatedAt      time.Time         `gorm:"not null;default:now()"`
+	DeletedAt      gorm.DeletedAt    `gorm:"index"`
+	UserID         string            `gorm:"not null;index;type:uuid"`
+	User           *UserModel        `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	IntegrationID  string            `gorm:"not null;index;type:uuid"`
+	Integration    *IntegrationModel `gorm:"foreignKey:IntegrationID;constraint:OnDelete:CASCADE"`
+	Type           string            `gorm:"not null"`
+	Subject        string
+	Content        string `gorm:"not null"`
+	HTMLContent    string
+	Destination    string `gorm:"not null"`
+	ExternalID     string
+	TraceID        string `gorm:"index"`
+	CallbackURL    string
+	Direction      string `gorm:"not null;default:outbound"`
+	FromNumber     string
+	ConversationID *string            `gorm:"index;type:uuid"`
+	Conversation   *ConversationModel `gorm:"foreignKey:ConversationID;constraint:OnDelete:SET NULL"`
+	// MediaURLs is a JSON array of the message's media.
+	MediaURLs string
+	// Encoding and Segments describe how an SMS went over the air.
+	Encoding string
+	Segments int `gorm:"not null;default:0"`
 }
 
+// ConversationModel threads messages between one of our numbers and one
+// remote number for a user.
+type ConversationModel struct {
+	ID            string            `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt     time.Time         `gorm:"not null;default:now()"`
+	UpdatedAt     time.Time         `gorm:"not null;default:now()"`
+	UserID        string            `gorm:"not null;type:uuid;uniqueIndex:idx_conversations_numbers,priority:1"`
+	User          *UserModel        `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	IntegrationID string            `gorm:"not null;index;type:uuid"`
+	Integration   *IntegrationModel `gorm:"foreignKey:IntegrationID;constraint:OnDelete:CASCADE"`
+	OurNumber     string            `gorm:"not null;uniqueIndex:idx_conversations_numbers,priority:2"`
+	TheirNumber   string            `gorm:"not null;uniqueIndex:idx_conversations_numbers,priority:3"`
+	LastMessageAt time.Time         `gorm:"not null;default:now()"`
+}
+
+func (ConversationModel) TableName() string { return "conversations" }
+
+// MediaModel is a file uploaded for MMS and served from a signed URL.
+type MediaModel struct {
+	ID          string     `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt   time.Time  `gorm:"not null;default:now()"`
+	UserID      string     `gorm:"not null;index;type:uuid"`
+	User        *UserModel `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Filename    string
+	ContentType string `gorm:"not null"`
+	Size        int64  `gorm:"not null"`
+	Data        []byte `gorm:"not null"`
+}
+
+func (MediaModel) TableName() string { return "media" }
+
+// VAPIDKeyModel holds the Web Push key pair, generated on first use. There
+// is one row, with ID "default".
+type VAPIDKeyModel struct {
+	ID         string    `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"not null;default:now()"`
+	PublicKey  string    `gorm:"not null"`
+	PrivateKey string    `gorm:"not null"`
+}
+
+func (VAPIDKeyModel) TableName() string { return "vapid_keys" }
+
+// PushSubscriptionModel is a browser push subscription for a user's end user.
+type PushSubscriptionModel struct {
+	ID         string     `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt  time.Time  `gorm:"not null;default:now()"`
+	UpdatedAt  time.Time  `gorm:"not null;default:now()"`
+	UserID     string     `gorm:"not null;type:uuid;index:idx_push_subscriptions_subscriber,priority:1"`
+	User       *UserModel `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Subscriber string     `gorm:"not null;index:idx_push_subscriptions_subscriber,priority:2"`
+	Endpoint   string     `gorm:"not null;uniqueIndex"`
+	P256dh     string     `gorm:"not null"`
+	Auth       string     `gorm:"not null"`
+	ExpiredAt  *time.Time
+}
+
+func (PushSubscriptionModel) TableName() string { return "push_subscriptions" }
+
 type MessageStatusModel struct {
-	ID              string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	ExternalID      string    `gorm:"index"`
-	CreatedAt       time.Time `gorm:"not null;default:now()"`
-	UpdatedAt       time.Time `gorm:"not null;default:now()"`
-	DeletedAt       *time.Time
-	MessageID       string `gorm:"not null;index"`
-	Status          string `gorm:"not null"`
+	ID              string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ExternalID      string         `gorm:"index"`
+	CreatedAt       time.Time      `gorm:"not null;default:now()"`
+	UpdatedAt       time.Time      `gorm:"not null;default:now()"`
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	MessageID       string         `gorm:"not null;index;type:uuid"`
+	Message         *MessageModel  `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE"`
+	Status          string         `gorm:"not null"`
 	GatewayResponse string
 	DateSent        *time.Time
 	DateOpened      *time.Time
@@ -74,3 +178,31 @@ type MessageStatusModel struct {
 	DateCanceled    *time.Time
 	DateDeferred    *time.Time
 }
+
+// WebhookDeliveryModel is one status callback POST to a customer endpoint,
+// with its retry state.
+type WebhookDeliveryModel struct {
+	ID              string              `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedAt       time.Time           `gorm:"not null;default:now()"`
+	UpdatedAt       time.Time           `gorm:"not null;default:now()"`
+	DeletedAt       gorm.DeletedAt      `gorm:"index"`
+	UserID          string              `gorm:"not null;index;type:uuid"`
+	User            *UserModel          `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	MessageID       string              `gorm:"not null;index;type:uuid"`
+	Message         *MessageModel       `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE"`
+	MessageStatusID *string             `gorm:"type:uuid"`
+	MessageStatus   *MessageStatusModel `gorm:"foreignKey:MessageStatusID;constraint:OnDelete:CASCADE"`
+	RedeliveryOf    *string             `gorm:"type:uuid"`
+	URL             string              `gorm:"not null"`
+	Event           string              `gorm:"not null"`
+	Payload         string              `gorm:"not null"`
+	State           string              `gorm:"not null;default:pending"`
+	Attempts        int                 `gorm:"not null;default:0"`
+	NextAttemptAt   *time.Time
+	LastAttemptAt   *time.Time
+	ResponseCode    *int
+	ResponseBody    string
+	LastError       string
+}
+
+func (WebhookDeliveryModel) TableName() string { return "webhook_deliveries" }
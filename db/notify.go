@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"messenger-module/confs"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StatusEventsChannel carries message status events between instances.
+const StatusEventsChannel = "message_status_events"
+
+// Notify sends payload on a Postgres NOTIFY channel. Payloads must stay
+// under Postgres' 8000 byte limit.
+func Notify(ctx context.Context, database Database, channel, payload string) error {
+	return database.GetDB().WithContext(ctx).Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+// Listener holds a dedicated connection LISTENing on one channel; pooled
+// connections cannot be used since notifications arrive per session.
+type Listener struct {
+	dsn     string
+	channel string
+	logger  *slog.Logger
+}
+
+func NewListener(cfg confs.DatabaseConfig, channel string, logger *slog.Logger) *Listener {
+	return &Listener{dsn: buildDSN(cfg), channel: channel, logger: logger}
+}
+
+// Run passes every notification payload to fn until ctx is cancelled,
+// reconnecting with backoff when the connection drops.
+func (l *Listener) Run(ctx context.Context, fn func(payload string)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := l.listen(ctx, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		l.logger.Warn("notification listener disconnected", "channel", l.channel, "error", err, "retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, fn func(payload string)) error {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen %s: %w", l.channel, err)
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
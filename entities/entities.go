@@ -7,6 +7,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// ListOptions tweaks list queries. Soft-deleted rows are hidden unless
+// IncludeDeleted is set.
+type ListOptions struct {
+	IncludeDeleted bool
+}
+
 type User struct {
 	ID        string `json:"id"`
 	CreatedAt string `json:"created_at"`
@@ -14,8 +20,44 @@ type User struct {
 	DeletedAt string `json:"deleted_at,omitempty"`
 	Name      string `json:"name"`
 	Email     string `json:"email"`
-	APIKey    string `json:"api_key"`
+	APIKey    string `json:"api_key,omitempty"` // only set on the create response
 	Active    bool   `json:"active"`
+	// CallbackURL receives a signed POST for every message status change.
+	CallbackURL    string `json:"callback_url,omitempty"`
+	CallbackSecret string `json:"-"`
+}
+
+// API key scopes. Admin implies every other scope.
+const (
+	APIKeyScopeSend  = "send"
+	APIKeyScopeRead  = "read"
+	APIKeyScopeAdmin = "admin"
+)
+
+type APIKey struct {
+	ID         string   `json:"id"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	DeletedAt  string   `json:"deleted_at,omitempty"`
+	UserID     string   `json:"user_id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	KeyHash    string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at,omitempty"`
+	LastUsedAt *string  `json:"last_used_at,omitempty"`
+	RevokedAt  *string  `json:"revoked_at,omitempty"`
+	Secret     string   `json:"secret,omitempty"` // plaintext key, only returned once on creation
+}
+
+// HasScope reports whether the key grants scope. Admin keys grant everything.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == APIKeyScopeAdmin {
+			return true
+		}
+	}
+	return false
 }
 
 type Plan struct {
@@ -47,21 +89,200 @@ type Integration struct {
 	Name      string `json:"name"` // Twilio, SendGrid, Ntfy
 	Type      string `json:"type"` // email, phone, ntfy
 	PlanID    string `json:"plan_id"`
+	// PhoneNumber is the E.164 number SMS is sent from and received on;
+	// empty means the configured default number.
+	PhoneNumber string `json:"phone_number,omitempty"`
+	// BaseURL points the provider at a self-hosted server (ntfy, telegram),
+	// or is the endpoint every message of a webhook integration goes to.
+	BaseURL string `json:"base_url,omitempty"`
+	// Token authenticates against the provider, or signs webhook payloads.
+	// It is write-only and never returned by the API.
+	Token string `json:"token,omitempty"`
+	// PayloadTemplate and Headers shape webhook provider requests. Header
+	// values often carry credentials, so like Token they are write-only:
+	// responses list only HeaderNames.
+	PayloadTemplate string            `json:"payload_template,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	HeaderNames     []string          `json:"header_names,omitempty"`
 }
 
 type Message struct {
-	ID            string        `json:"id"`
-	CreatedAt     string        `json:"created_at"`
-	UpdatedAt     string        `json:"updated_at"`
-	DeletedAt     string        `json:"deleted_at,omitempty"`
-	IntegrationID string        `json:"integration_id"`
-	UserID        string        `json:"user_id"`
-	Type          string        `json:"type"` // email, sms
-	Subject       string        `json:"subject"`
-	Content       string        `json:"content"`
-	Destination   string        `json:"destination"`
-	ExternalID    string        `json:"external_id,omitempty"`
-	Status        MessageStatus `json:"status"`
+	ID             string        `json:"id"`
+	CreatedAt      string        `json:"created_at"`
+	UpdatedAt      string        `json:"updated_at"`
+	DeletedAt      string        `json:"deleted_at,omitempty"`
+	IntegrationID  string        `json:"integration_id"`
+	UserID         string        `json:"user_id"`
+	Type           string        `json:"type"` // email, sms, whatsapp, ...
+	Subject        string        `json:"subject"`
+	Content        string        `json:"content"`
+	HTMLContent    string        `json:"html_content,omitempty"` // email only; Content is the plain-text part
+	Destination    string        `json:"destination"`
+	ExternalID     string        `json:"external_id,omitempty"`
+	TraceID        string        `json:"trace_id,omitempty"`
+	CallbackURL    string        `json:"callback_url,omitempty"` // overrides the user's callback URL
+	Direction      string        `json:"direction"`              // outbound, inbound
+	From           string        `json:"from,omitempty"`         // sender number for SMS
+	ConversationID string        `json:"conversation_id,omitempty"`
+	MediaURLs      []string      `json:"media_urls,omitempty"` // MMS and WhatsApp media
+	Encoding       string        `json:"encoding,omitempty"`   // SMS only: GSM-7 or UCS-2
+	Segments       int           `json:"segments,omitempty"`   // SMS only: billed segments
+	Status         MessageStatus `json:"status"`
+
+	// SmartEncoding replaces Unicode look-alikes (curly quotes, dashes, ...)
+	// with GSM-7 characters before an SMS is sent. Not stored.
+	SmartEncoding bool `json:"smart_encoding,omitempty"`
+
+	// Email options, passed to the provider but not stored.
+	CC          []string          `json:"cc,omitempty"`
+	BCC         []string          `json:"bcc,omitempty"`
+	ReplyTo     string            `json:"reply_to,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Attachments []Attachment      `json:"attachments,omitempty"`
+
+	// Ntfy options, passed to the provider but not stored.
+	Ntfy *NtfyOptions `json:"ntfy,omitempty"`
+
+	// Telegram options, passed to the provider but not stored.
+	Telegram *TelegramOptions `json:"telegram,omitempty"`
+
+	// WhatsApp options, passed to the provider but not stored.
+	WhatsApp *WhatsAppOptions `json:"whatsapp,omitempty"`
+
+	// Web Push options, passed to the provider but not stored.
+	WebPush *WebPushOptions `json:"webpush,omitempty"`
+}
+
+// SMSEstimate is how an SMS would be sent and roughly what it would cost.
+type SMSEstimate struct {
+	Content       string  `json:"content"` // after smart encoding, if requested
+	Encoding      string  `json:"encoding"`
+	Segments      int     `json:"segments"`
+	Characters    int     `json:"characters"`
+	EstimatedCost float64 `json:"estimated_cost"`
+	Currency      string  `json:"currency"`
+}
+
+// WebPushOptions shape a Web Push notification and how long the push
+// service keeps trying to deliver it.
+type WebPushOptions struct {
+	URL     string `json:"url,omitempty"` // opened when the notification is clicked
+	Icon    string `json:"icon,omitempty"`
+	Tag     string `json:"tag,omitempty"`     // replaces an earlier notification with the same tag
+	TTL     int    `json:"ttl,omitempty"`     // seconds; 0 uses WEBPUSH_TTL
+	Urgency string `json:"urgency,omitempty"` // very-low, low, normal or high
+}
+
+// WhatsAppOptions send a pre-approved Twilio content template instead of
+// free-form content, which WhatsApp only allows within 24 hours of the
+// recipient's last message.
+type WhatsAppOptions struct {
+	ContentSID       string            `json:"content_sid,omitempty"`       // HX... template SID
+	ContentVariables map[string]string `json:"content_variables,omitempty"` // placeholder ("1", "2", ...) to value
+}
+
+// TelegramOptions control how a Telegram message is formatted and delivered.
+type TelegramOptions struct {
+	ParseMode             string `json:"parse_mode,omitempty"` // MarkdownV2, HTML, or empty for plain text
+	DisableNotification   bool   `json:"disable_notification,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+}
+
+// NtfyOptions are the ntfy publish features beyond title and body.
+type NtfyOptions struct {
+	Priority int          `json:"priority,omitempty"` // 1 (min) to 5 (urgent); 0 is the server default
+	Tags     []string     `json:"tags,omitempty"`     // tags and emoji shortcodes
+	Click    string       `json:"click,omitempty"`    // URL opened when the notification is tapped
+	Attach   string       `json:"attach,omitempty"`   // URL of a file to attach
+	Filename string       `json:"filename,omitempty"` // name shown for Attach
+	Icon     string       `json:"icon,omitempty"`
+	Actions  []NtfyAction `json:"actions,omitempty"`
+}
+
+// NtfyAction is a notification button.
+type NtfyAction struct {
+	Action  string            `json:"action"` // view, http or broadcast
+	Label   string            `json:"label"`
+	URL     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Intent  string            `json:"intent,omitempty"`
+	Extras  map[string]string `json:"extras,omitempty"`
+	Clear   bool              `json:"clear,omitempty"`
+}
+
+// Attachment is a file sent with an email.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"` // sniffed from the content when empty
+	Content     string `json:"content"`                // base64
+	Disposition string `json:"disposition,omitempty"`  // attachment (default) or inline
+	ContentID   string `json:"content_id,omitempty"`   // referenced as cid: by inline images
+}
+
+// Message directions.
+const (
+	DirectionOutbound = "outbound"
+	DirectionInbound  = "inbound"
+)
+
+// Media is a file uploaded for MMS. Carriers fetch it from URL, which is
+// signed and valid until ExpiresAt.
+type Media struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	UserID      string `json:"user_id"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	Data        []byte `json:"-"`
+}
+
+// PushSubscription is a browser's Web Push subscription, as produced by
+// PushManager.subscribe, registered for one of a user's end users.
+type PushSubscription struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	UserID    string `json:"user_id"`
+	// Subscriber is the user's own identifier for the end user; messages
+	// are addressed to it and reach all of its subscriptions.
+	Subscriber string `json:"subscriber"`
+	Endpoint   string `json:"endpoint"`
+	// Keys are write-only and never returned by the API.
+	Keys *PushKeys `json:"keys,omitempty"`
+	// ExpiredAt is set once the push service reports the subscription gone.
+	ExpiredAt string `json:"expired_at,omitempty"`
+}
+
+// PushKeys are a subscription's encryption keys, base64url encoded.
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// VAPIDKeys is the service's application server key pair. PublicKey is the
+// base64url uncompressed P-256 point browsers subscribe with; PrivateKey is
+// base64 PKCS #8.
+type VAPIDKeys struct {
+	PublicKey  string
+	PrivateKey string
+}
+
+// Conversation is the thread of SMS exchanged between one of our numbers and
+// one remote number.
+type Conversation struct {
+	ID            string `json:"id"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+	UserID        string `json:"user_id"`
+	IntegrationID string `json:"integration_id"`
+	OurNumber     string `json:"our_number"`
+	TheirNumber   string `json:"their_number"`
+	LastMessageAt string `json:"last_message_at"`
 }
 
 type MessageStatus struct {
@@ -80,13 +301,46 @@ type MessageStatus struct {
 	DateDeferred    *string `json:"date_deferred,omitempty"`
 }
 
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one status callback sent, or to be sent, to a customer
+// endpoint.
+type WebhookDelivery struct {
+	ID              string  `json:"id"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at"`
+	UserID          string  `json:"user_id"`
+	MessageID       string  `json:"message_id"`
+	MessageStatusID string  `json:"message_status_id,omitempty"`
+	RedeliveryOf    string  `json:"redelivery_of,omitempty"`
+	URL             string  `json:"url"`
+	Event           string  `json:"event"`
+	Payload         string  `json:"payload"`
+	State           string  `json:"state"`
+	Attempts        int     `json:"attempts"`
+	NextAttemptAt   *string `json:"next_attempt_at,omitempty"`
+	LastAttemptAt   *string `json:"last_attempt_at,omitempty"`
+	ResponseCode    *int    `json:"response_code,omitempty"`
+	ResponseBody    string  `json:"response_body,omitempty"`
+	LastError       string  `json:"last_error,omitempty"`
+}
+
+// CallbackSettings is a user's status callback endpoint and signing secret.
+type CallbackSettings struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
 		u.ID = uuid.New().String()
 	}
 
-	u.APIKey = uuid.New().String()
-
 	u.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
 	u.UpdatedAt = u.CreatedAt
 	return nil
@@ -97,6 +351,20 @@ func (u *User) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = uuid.New().String()
+	}
+	k.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	k.UpdatedAt = k.CreatedAt
+	return nil
+}
+
+func (k *APIKey) BeforeUpdate(tx *gorm.DB) error {
+	k.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	return nil
+}
+
 func (p *Plan) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == "" {
 		p.ID = uuid.New().String()
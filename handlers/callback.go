@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strconv"
+	"time"
+)
+
+// Headers identifying and signing requests to customer endpoints, shared by
+// status callbacks and the webhook provider.
+const (
+	SignatureHeader = "X-Messenger-Signature"
+	EventHeader     = "X-Messenger-Event"
+	DeliveryHeader  = "X-Messenger-Delivery"
+)
+
+// maxCallbackResponse caps how much of a customer's response body is kept.
+const maxCallbackResponse = 4 << 10
+
+// CallbackSender POSTs JSON to customer endpoints. Only public addresses are
+// reachable; see publicTransport.
+type CallbackSender struct {
+	client  *nethttp.Client
+	timeout time.Duration
+}
+
+func NewCallbackSender(timeout time.Duration) *CallbackSender {
+	return &CallbackSender{client: newPublicHTTPClient(timeout), timeout: timeout}
+}
+
+// Post sends body to url with headers and returns the response status and
+// the start of the response body. Redirects are not followed.
+func (s *CallbackSender) Post(ctx context.Context, url string, headers map[string]string, body []byte) (int, string, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "messenger-module-callbacks")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("callback request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxCallbackResponse))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, string(snippet), nil
+}
+
+// SignPayload returns the signature header value for body sent at ts:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers should
+// recompute it with their secret and reject stale timestamps.
+func SignPayload(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"messenger-module/confs"
+)
+
+// Hosts incoming-webhook URLs may point at. A leading dot matches any
+// subdomain.
+var (
+	slackWebhookHosts = []string{"hooks.slack.com", "hooks.slack-gov.com"}
+	teamsWebhookHosts = []string{
+		".webhook.office.com",                // classic connectors
+		"outlook.office.com",                 // legacy connectors
+		"outlook.office365.com",              // legacy connectors
+		".logic.azure.com",                   // Workflows
+		".environment.api.powerplatform.com", // Workflows
+	}
+)
+
+// chatPoster POSTs JSON to chat incoming-webhook URLs. A 429 response is
+// retried after its Retry-After, as long as the wait is within bounds.
+type chatPoster struct {
+	provider     string
+	hosts        []string
+	client       *nethttp.Client
+	timeout      time.Duration
+	maxRetries   int
+	maxRetryWait time.Duration
+}
+
+func newChatPoster(provider string, hosts []string, cfg confs.ChatConfig) chatPoster {
+	return chatPoster{
+		provider: provider,
+		hosts:    hosts,
+		// Webhook URLs are secrets, and redirects are never followed.
+		client:       newPublicHTTPClient(time.Duration(cfg.Timeout)),
+		timeout:      time.Duration(cfg.Timeout),
+		maxRetries:   cfg.MaxRetries,
+		maxRetryWait: time.Duration(cfg.MaxRetryWait),
+	}
+}
+
+// checkURL requires an https URL on one of the provider's webhook hosts.
+func (p chatPoster) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute https URL", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range p.hosts {
+		if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a %s webhook host", host, p.provider)
+}
+
+// post sends body to url and returns the final response status and body.
+func (p chatPoster) post(ctx context.Context, url string, body []byte) (int, string, error) {
+	for attempt := 0; ; attempt++ {
+		status, respBody, retryAfter, err := p.postOnce(ctx, url, body)
+		if err != nil || status != nethttp.StatusTooManyRequests {
+			return status, respBody, err
+		}
+		if attempt >= p.maxRetries || retryAfter > p.maxRetryWait {
+			return status, respBody, fmt.Errorf("%s rate limited: retry after %s", p.provider, retryAfter)
+		}
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status, respBody, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p chatPoster) postOnce(ctx context.Context, url string, body []byte) (int, string, time.Duration, error) {
+	ctx, cancel := withTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("failed to post to %s: %w", p.provider, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if err != nil {
+		return 0, "", 0, fmt.Errorf("failed to read %s response: %w", p.provider, err)
+	}
+	return resp.StatusCode, strings.TrimSpace(string(raw)), retryAfter(resp.Header.Get("Retry-After")), nil
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. A missing or unparseable value means one second.
+func retryAfter(v string) time.Duration {
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := nethttp.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return time.Second
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with an ellipsis.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+// chatServer answers each POST with the next of responses, repeating the
+// last one, and counts the requests it saw.
+type chatServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	lastBody atomic.Value
+}
+
+type chatResponse struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+func newChatServer(t *testing.T, responses ...chatResponse) *chatServer {
+	t.Helper()
+	s := &chatServer{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(s.requests.Add(1)) - 1
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.lastBody.Store(body)
+		resp := responses[min(n, len(responses)-1)]
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// poster returns a chatPoster that trusts the test server and accepts its
+// 127.0.0.1 address as a webhook host.
+func (s *chatServer) poster(provider string, maxRetries int, maxRetryWait time.Duration) chatPoster {
+	return chatPoster{
+		provider:     provider,
+		hosts:        []string{"127.0.0.1"},
+		client:       s.Client(),
+		timeout:      5 * time.Second,
+		maxRetries:   maxRetries,
+		maxRetryWait: maxRetryWait,
+	}
+}
+
+func chatMessage(destination string) entities.Message {
+	return entities.Message{Destination: destination, Subject: "Deploy", Content: "Release <v2> is out & live"}
+}
+
+func TestSlackSend(t *testing.T) {
+	srv := newChatServer(t, chatResponse{status: http.StatusOK, body: "ok"})
+	h := &SlackHandler{poster: srv.poster("slack", 0, 0)}
+
+	id, err := h.SendMessage(context.Background(), chatMessage(srv.URL+"/services/T/B/X"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" {
+		t.Error("no message ID returned")
+	}
+	var got slackPayload
+	if err := json.Unmarshal(srv.lastBody.Load().([]byte), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "Deploy" || len(got.Blocks) != 2 || got.Blocks[0].Type != "header" {
+		t.Fatalf("payload = %+v", got)
+	}
+	if text := got.Blocks[1].Text.Text; text != "Release &lt;v2&gt; is out &amp; live" {
+		t.Errorf("section text = %q, want Slack control characters escaped", text)
+	}
+}
+
+func TestSlackRateLimited(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []chatResponse
+		maxRetries   int
+		maxRetryWait time.Duration
+		wantErr      string
+		wantRequests int32
+	}{
+		{
+			name:         "retried after Retry-After",
+			responses:    []chatResponse{{status: 429, retryAfter: "0"}, {status: 429, retryAfter: "0"}, {status: 200, body: "ok"}},
+			maxRetries:   2,
+			maxRetryWait: time.Second,
+			wantRequests: 3,
+		},
+		{
+			name:         "gives up after max retries",
+			responses:    []chatResponse{{status: 429, retryAfter: "0"}},
+			maxRetries:   2,
+			maxRetryWait: time.Second,
+			wantErr:      "slack rate limited",
+			wantRequests: 3,
+		},
+		{
+			name:         "wait longer than allowed",
+			responses:    []chatResponse{{status: 429, retryAfter: "120"}, {status: 200, body: "ok"}},
+			maxRetries:   3,
+			maxRetryWait: 30 * time.Second,
+			wantErr:      "retry after 2m0s",
+			wantRequests: 1,
+		},
+		{
+			name:         "no retries configured",
+			responses:    []chatResponse{{status: 429, retryAfter: "0"}, {status: 200, body: "ok"}},
+			wantErr:      "slack rate limited",
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newChatServer(t, tt.responses...)
+			h := &SlackHandler{poster: srv.poster("slack", tt.maxRetries, tt.maxRetryWait)}
+
+			_, err := h.SendMessage(context.Background(), chatMessage(srv.URL))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if got := srv.requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestSlackRateLimitWaitHonoursContext(t *testing.T) {
+	srv := newChatServer(t, chatResponse{status: 429, retryAfter: "10"})
+	h := &SlackHandler{poster: srv.poster("slack", 1, time.Minute)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := h.SendMessage(ctx, chatMessage(srv.URL))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s for Retry-After despite the deadline", elapsed)
+	}
+}
+
+func TestSlackError(t *testing.T) {
+	srv := newChatServer(t, chatResponse{status: http.StatusNotFound, body: "channel_not_found"})
+	h := &SlackHandler{poster: srv.poster("slack", 2, time.Second)}
+
+	_, err := h.SendMessage(context.Background(), chatMessage(srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "status=404") || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Fatalf("err = %v, want the status and Slack's error code", err)
+	}
+	if got := srv.requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want no retry for a non-429 error", got)
+	}
+}
+
+func TestTeamsResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    chatResponse
+		wantErr string
+	}{
+		{"accepted", chatResponse{status: http.StatusOK, body: "1"}, ""},
+		{"workflow accepted", chatResponse{status: http.StatusAccepted}, ""},
+		{"bad request", chatResponse{status: http.StatusBadRequest, body: "Summary or Text is required."}, "status=400"},
+		{"gone", chatResponse{status: http.StatusGone, body: "Connector has been removed"}, "status=410"},
+		{"server error", chatResponse{status: http.StatusInternalServerError}, "status=500"},
+		{
+			"failure reported as 200",
+			chatResponse{status: http.StatusOK, body: "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 429 with ContextId x"},
+			"returned HTTP error 429",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newChatServer(t, tt.resp)
+			h := &TeamsHandler{poster: srv.poster("teams", 0, 0)}
+
+			_, err := h.SendMessage(context.Background(), chatMessage(srv.URL))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTeamsPayload(t *testing.T) {
+	srv := newChatServer(t, chatResponse{status: http.StatusOK, body: "1"})
+	h := &TeamsHandler{poster: srv.poster("teams", 0, 0)}
+
+	if _, err := h.SendMessage(context.Background(), chatMessage(srv.URL)); err != nil {
+		t.Fatal(err)
+	}
+	var got teamsPayload
+	if err := json.Unmarshal(srv.lastBody.Load().([]byte), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "message" || len(got.Attachments) != 1 {
+		t.Fatalf("payload = %+v", got)
+	}
+	card := got.Attachments[0].Content
+	if card.Type != "AdaptiveCard" || len(card.Body) != 2 || card.Body[0].Text != "Deploy" || card.Body[1].Text != "Release <v2> is out & live" {
+		t.Errorf("card = %+v", card)
+	}
+}
+
+func TestTeamsPayloadTooLarge(t *testing.T) {
+	srv := newChatServer(t, chatResponse{status: http.StatusOK, body: "1"})
+	h := &TeamsHandler{poster: srv.poster("teams", 0, 0)}
+
+	msg := chatMessage(srv.URL)
+	msg.Content = strings.Repeat("x", teamsMaxPayload)
+	if _, err := h.SendMessage(context.Background(), msg); err == nil || !strings.Contains(err.Error(), "Teams allows") {
+		t.Fatalf("err = %v, want a size error", err)
+	}
+	if got := srv.requests.Load(); got != 0 {
+		t.Errorf("requests = %d, want none for an oversized card", got)
+	}
+}
+
+func TestChatWebhookHosts(t *testing.T) {
+	slack := newChatPoster("slack", slackWebhookHosts, confs.ChatConfig{})
+	teams := newChatPoster("teams", teamsWebhookHosts, confs.ChatConfig{})
+	tests := []struct {
+		poster chatPoster
+		url    string
+		ok     bool
+	}{
+		{slack, "https://hooks.slack.com/services/T/B/X", true},
+		{slack, "https://HOOKS.SLACK.COM/services/T/B/X", true},
+		{slack, "http://hooks.slack.com/services/T/B/X", false},
+		{slack, "https://hooks.slack.com.evil.example/services", false},
+		{slack, "https://evilhooks.slack.com/services", false},
+		{slack, "https://127.0.0.1/services", false},
+		{teams, "https://contoso.webhook.office.com/webhookb2/x", true},
+		{teams, "https://prod-01.westus.logic.azure.com/workflows/x", true},
+		{teams, "https://outlook.office.com/webhook/x", true},
+		{teams, "https://webhook.office.com/webhookb2/x", false},
+		{teams, "https://contoso.webhook.office.com.evil.example/x", false},
+		{teams, "https://hooks.slack.com/services/T/B/X", false},
+	}
+	for _, tt := range tests {
+		err := tt.poster.checkURL(tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s checkURL(%q) = %v, want ok=%v", tt.poster.provider, tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"0", 0},
+		{"7", 7 * time.Second},
+		{" 3 ", 3 * time.Second},
+		{"", time.Second},
+		{"soon", time.Second},
+		{"-5", time.Second},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+	future := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := retryAfter(future); got < 80*time.Second || got > 90*time.Second {
+		t.Errorf("retryAfter(%q) = %s, want about 90s", future, got)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"messenger-module/entities"
+)
+
+// Attachment limits, in decoded bytes. SendGrid rejects messages over 30 MB
+// in total, and base64 adds a third on the wire.
+const (
+	maxAttachments          = 10
+	maxAttachmentBytes      = 10 << 20
+	maxTotalAttachmentBytes = 20 << 20
+)
+
+// maxCopyRecipients caps cc and bcc together, so one message cannot be used
+// to mail a list.
+const maxCopyRecipients = 50
+
+// reservedHeaders are built from the message itself or owned by the
+// provider, so Message.Headers may not set them. Sender, Return-Path and the
+// Resent- family would let a message claim another sender or route bounces
+// elsewhere.
+var reservedHeaders = map[string]bool{
+	"From": true, "To": true, "Cc": true, "Bcc": true, "Reply-To": true,
+	"Sender": true, "Return-Path": true, "Errors-To": true,
+	"Subject": true, "Date": true, "Message-Id": true, "Mime-Version": true,
+	"Content-Type": true, "Content-Transfer-Encoding": true, "Content-Disposition": true,
+	"Received": true, "Dkim-Signature": true, "X-Sg-Id": true, "X-Sg-Eid": true, "X-Message-Id": true,
+}
+
+var headerName = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// emailExtras are the parsed optional parts of an email message.
+type emailExtras struct {
+	cc          []*mail.Address
+	bcc         []*mail.Address
+	replyTo     *mail.Address
+	headers     map[string]string
+	attachments []emailAttachment
+}
+
+type emailAttachment struct {
+	filename    string
+	contentType string
+	disposition string
+	contentID   string
+	data        []byte
+}
+
+// parseEmailExtras validates and decodes the cc/bcc, reply-to, custom header
+// and attachment fields of input. Attachments without a usable content type
+// get one sniffed from their content.
+func parseEmailExtras(input entities.Message) (emailExtras, error) {
+	var ex emailExtras
+	var err error
+	if ex.cc, err = parseAddressList("cc", input.CC); err != nil {
+		return ex, err
+	}
+	if ex.bcc, err = parseAddressList("bcc", input.BCC); err != nil {
+		return ex, err
+	}
+	if len(ex.cc)+len(ex.bcc) > maxCopyRecipients {
+		return ex, fmt.Errorf("at most %d cc and bcc addresses are allowed", maxCopyRecipients)
+	}
+	if input.ReplyTo != "" {
+		if ex.replyTo, err = mail.ParseAddress(input.ReplyTo); err != nil {
+			return ex, fmt.Errorf("invalid reply_to address: %w", err)
+		}
+	}
+
+	if len(input.Headers) > 0 {
+		ex.headers = make(map[string]string, len(input.Headers))
+	}
+	for name, value := range input.Headers {
+		if !headerName.MatchString(name) {
+			return ex, fmt.Errorf("invalid header name %q", name)
+		}
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if reservedHeaders[canonical] || strings.HasPrefix(canonical, "Resent-") {
+			return ex, fmt.Errorf("header %s cannot be set directly", canonical)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return ex, fmt.Errorf("header %s must not contain line breaks", canonical)
+		}
+		ex.headers[canonical] = value
+	}
+
+	if len(input.Attachments) > maxAttachments {
+		return ex, fmt.Errorf("at most %d attachments are allowed", maxAttachments)
+	}
+	total := 0
+	for i, a := range input.Attachments {
+		att, err := parseAttachment(a)
+		if err != nil {
+			return ex, fmt.Errorf("attachment %d: %w", i, err)
+		}
+		total += len(att.data)
+		if total > maxTotalAttachmentBytes {
+			return ex, fmt.Errorf("attachments exceed %d MB in total", maxTotalAttachmentBytes>>20)
+		}
+		ex.attachments = append(ex.attachments, att)
+	}
+	return ex, nil
+}
+
+func parseAddressList(field string, list []string) ([]*mail.Address, error) {
+	out := make([]*mail.Address, 0, len(list))
+	for _, s := range list {
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s address: %w", field, err)
+		}
+		out = append(out, addr)
+	}
+	return out, nil
+}
+
+func parseAttachment(a entities.Attachment) (emailAttachment, error) {
+	if a.Filename == "" {
+		return emailAttachment{}, errors.New("filename is required")
+	}
+	if strings.ContainsAny(a.Filename, "\r\n/\\") {
+		return emailAttachment{}, errors.New("filename must not contain line breaks or path separators")
+	}
+	if base64.StdEncoding.DecodedLen(len(a.Content)) > maxAttachmentBytes+2 {
+		return emailAttachment{}, fmt.Errorf("%s exceeds %d MB", a.Filename, maxAttachmentBytes>>20)
+	}
+	data, err := base64.StdEncoding.DecodeString(a.Content)
+	if err != nil {
+		return emailAttachment{}, fmt.Errorf("%s: content must be base64: %w", a.Filename, err)
+	}
+	if len(data) == 0 {
+		return emailAttachment{}, fmt.Errorf("%s is empty", a.Filename)
+	}
+	if len(data) > maxAttachmentBytes {
+		return emailAttachment{}, fmt.Errorf("%s exceeds %d MB", a.Filename, maxAttachmentBytes>>20)
+	}
+
+	contentType := a.ContentType
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return emailAttachment{}, fmt.Errorf("%s: invalid content_type %q", a.Filename, contentType)
+	}
+
+	disposition := a.Disposition
+	switch disposition {
+	case "":
+		disposition = "attachment"
+	case "attachment":
+	case "inline":
+		if a.ContentID == "" {
+			return emailAttachment{}, fmt.Errorf("%s: inline attachments need a content_id", a.Filename)
+		}
+	default:
+		return emailAttachment{}, fmt.Errorf("%s: disposition must be attachment or inline", a.Filename)
+	}
+	if strings.ContainsAny(a.ContentID, "\r\n<>") {
+		return emailAttachment{}, fmt.Errorf("%s: invalid content_id", a.Filename)
+	}
+
+	return emailAttachment{
+		filename:    a.Filename,
+		contentType: contentType,
+		disposition: disposition,
+		contentID:   a.ContentID,
+		data:        data,
+	}, nil
+}
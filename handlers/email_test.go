@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"messenger-module/entities"
+)
+
+func TestParseEmailExtrasHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		ok   bool
+	}{
+		{"custom", "X-Campaign", true},
+		{"list unsubscribe", "List-Unsubscribe", true},
+		{"bcc", "bcc", false},
+		{"from", "From", false},
+		{"sender", "Sender", false},
+		{"return path", "return-path", false},
+		{"resent from", "Resent-From", false},
+		{"resent to", "resent-to", false},
+		{"errors to", "Errors-To", false},
+		{"provider message id", "X-Message-ID", false},
+		{"content type", "Content-Type", false},
+		{"invalid name", "X Campaign", false},
+	}
+	for _, tt := range tests {
+		_, err := parseEmailExtras(entities.Message{Headers: map[string]string{tt.key: "value"}})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: header %q: err = %v, want ok=%v", tt.name, tt.key, err, tt.ok)
+		}
+	}
+
+	if _, err := parseEmailExtras(entities.Message{Headers: map[string]string{"X-Campaign": "a\r\nBcc: evil@example.com"}}); err == nil {
+		t.Error("accepted a header value with a line break")
+	}
+}
+
+func TestParseEmailExtrasRecipientLimit(t *testing.T) {
+	addresses := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("r%d@example.com", i)
+		}
+		return out
+	}
+	tests := []struct {
+		cc, bcc int
+		ok      bool
+	}{
+		{0, 0, true},
+		{maxCopyRecipients, 0, true},
+		{maxCopyRecipients / 2, maxCopyRecipients / 2, true},
+		{maxCopyRecipients / 2, maxCopyRecipients/2 + 1, false},
+		{0, maxCopyRecipients + 1, false},
+	}
+	for _, tt := range tests {
+		_, err := parseEmailExtras(entities.Message{CC: addresses(tt.cc), BCC: addresses(tt.bcc)})
+		if (err == nil) != tt.ok {
+			t.Errorf("cc=%d bcc=%d: err = %v, want ok=%v", tt.cc, tt.bcc, err, tt.ok)
+		}
+	}
+}
@@ -1,33 +1,86 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
+	"messenger-module/confs"
 	"messenger-module/entities"
+	"messenger-module/metrics"
+	"messenger-module/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MessageHandlerFactory struct {
 	sendgridHandler *SendGridHandler
+	smtpHandler     *SMTPHandler
 	twillioHandler  *TwillioHandler
 	ntfyHandler     *NtfyHandler
+	webhookHandler  *WebhookHandler
+	slackHandler    *SlackHandler
+	teamsHandler    *TeamsHandler
+	telegramHandler *TelegramHandler
+	webpushHandler  *WebPushHandler
+	stats           *providerStats
+
+	// SMS encoding default and pricing, from the Twilio config.
+	smartEncoding bool
+	segmentPrice  float64
+	currency      string
 }
 
-func NewMessageHandlerFactory() *MessageHandlerFactory {
-	factory := &MessageHandlerFactory{}
+// registeredProviders are the integration names this build knows how to send through.
+var registeredProviders = []string{"sendgrid", "smtp", "twilio", "whatsapp", "ntfy", "webhook", "slack", "teams", "telegram", "webpush"}
+
+func NewMessageHandlerFactory(cfg *confs.Config) *MessageHandlerFactory {
+	factory := &MessageHandlerFactory{
+		stats:         newProviderStats(),
+		smartEncoding: cfg.Twilio.SmartEncoding,
+		segmentPrice:  cfg.Twilio.SegmentPrice,
+		currency:      cfg.Twilio.Currency,
+	}
 
-	if sg, err := NewSendGridHandler(); err == nil {
+	if sg, err := NewSendGridHandler(cfg.SendGrid); err == nil {
 		factory.sendgridHandler = sg
 	}
 
-	factory.twillioHandler = NewTwillioHandler()
+	if cfg.SMTP.Configured() {
+		if smtpHandler, err := NewSMTPHandler(cfg.SMTP); err == nil {
+			factory.smtpHandler = smtpHandler
+		}
+	}
+
+	if cfg.Twilio.Configured() {
+		factory.twillioHandler = NewTwillioHandler(cfg.Twilio, cfg.Env, cfg.WebhookBaseURL)
+	}
 
-	factory.ntfyHandler = NewNtfyHandler()
+	factory.ntfyHandler = NewNtfyHandler(cfg.Ntfy)
+	factory.webhookHandler = NewWebhookHandler(cfg.Webhook)
+	factory.slackHandler = NewSlackHandler(cfg.Slack)
+	factory.teamsHandler = NewTeamsHandler(cfg.Teams)
+	factory.telegramHandler = NewTelegramHandler(cfg.Telegram)
+
+	if cfg.WebPush.Configured() {
+		factory.webpushHandler = NewWebPushHandler(cfg.WebPush)
+	}
 
 	return factory
 }
 
+// SetPushSubscriptions gives the webpush provider its keys and subscriptions,
+// which live in storage the factory does not otherwise see.
+func (f *MessageHandlerFactory) SetPushSubscriptions(store PushSubscriptionStore) {
+	if f.webpushHandler != nil {
+		f.webpushHandler.store = store
+	}
+}
+
 func (f *MessageHandlerFactory) GetHandler(integration entities.Integration) (MessageHandler, error) {
 	switch strings.ToLower(integration.Name) {
 	case "sendgrid":
@@ -35,29 +88,97 @@ func (f *MessageHandlerFactory) GetHandler(integration entities.Integration) (Me
 			return nil, errors.New("sendgrid handler not configured - missing API key")
 		}
 		return f.sendgridHandler, nil
+	case "smtp":
+		if f.smtpHandler == nil {
+			return nil, errors.New("smtp handler not configured - missing SMTP_HOST")
+		}
+		return f.smtpHandler, nil
 	case "twilio":
 		if f.twillioHandler == nil {
 			return nil, errors.New("twilio handler not configured")
 		}
 		return f.twillioHandler, nil
+	case "whatsapp":
+		if f.twillioHandler == nil {
+			return nil, errors.New("whatsapp handler not configured - Twilio is not set up")
+		}
+		return f.twillioHandler.whatsApp(), nil
 	case "ntfy":
 		if f.ntfyHandler == nil {
 			return nil, errors.New("ntfy handler not configured")
 		}
-		return f.ntfyHandler, nil
+		return f.ntfyHandler.withIntegration(integration), nil
+	case "webhook":
+		if f.webhookHandler == nil {
+			return nil, errors.New("webhook handler not configured")
+		}
+		return f.webhookHandler.withIntegration(integration)
+	case "slack":
+		if f.slackHandler == nil {
+			return nil, errors.New("slack handler not configured")
+		}
+		return f.slackHandler, nil
+	case "teams":
+		if f.teamsHandler == nil {
+			return nil, errors.New("teams handler not configured")
+		}
+		return f.teamsHandler, nil
+	case "telegram":
+		if f.telegramHandler == nil {
+			return nil, errors.New("telegram handler not configured")
+		}
+		return f.telegramHandler.withIntegration(integration)
+	case "webpush":
+		if f.webpushHandler == nil {
+			return nil, errors.New("webpush handler not configured - missing WEBPUSH_SUBJECT")
+		}
+		return f.webpushHandler, nil
 	default:
 		return nil, fmt.Errorf("unknown integration name: %s", integration.Name)
 	}
 }
 
-func (f *MessageHandlerFactory) SendMessage(integration entities.Integration, plan entities.Plan, message entities.Message) (entities.Message, string, error) {
+func (f *MessageHandlerFactory) SendMessage(ctx context.Context, integration entities.Integration, plan entities.Plan, message entities.Message) (entities.Message, string, error) {
 	switch strings.ToLower(integration.Name) {
-	case "sendgrid":
+	case "sendgrid", "smtp":
 		message.Type = "email"
-	case "twilio":
+	case "twilio", "whatsapp":
 		message.Type = "sms"
+		if strings.EqualFold(integration.Name, "whatsapp") {
+			message.Type = "whatsapp"
+		}
+		if message.From == "" {
+			message.From = integration.PhoneNumber
+		}
+		if message.From == "" && f.twillioHandler != nil {
+			message.From = f.twillioHandler.from
+		}
 	case "ntfy":
 		message.Type = "ntfy"
+	case "webhook":
+		message.Type = "webhook"
+	case "slack":
+		message.Type = "slack"
+	case "teams":
+		message.Type = "teams"
+	case "telegram":
+		message.Type = "telegram"
+	case "webpush":
+		message.Type = "webpush"
+	}
+
+	if len(message.MediaURLs) > 0 && message.Type != "sms" && message.Type != "whatsapp" {
+		return message, "", errors.New("media_urls are only supported for SMS and WhatsApp")
+	}
+
+	// Text SMS are billed per segment; MMS are billed per message.
+	if message.Type == "sms" && len(message.MediaURLs) == 0 {
+		if message.SmartEncoding || f.smartEncoding {
+			message.Content = SmartEncode(message.Content)
+		}
+		seg := CountSegments(message.Content)
+		message.Encoding = seg.Encoding
+		message.Segments = seg.Segments
 	}
 
 	if strings.EqualFold(plan.Name, "free") && message.Type != "ntfy" {
@@ -69,18 +190,114 @@ func (f *MessageHandlerFactory) SendMessage(integration entities.Integration, pl
 		return message, "", err
 	}
 
-	externalID, err := handler.SendMessage(message)
+	provider := strings.ToLower(integration.Name)
+	outbound := metrics.QueueDepth.WithLabelValues("outbound")
+	outbound.Inc()
+	ctx, span := tracing.Start(ctx, "MessageHandler.SendMessage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("provider", provider), attribute.String("message.type", message.Type)))
+	start := time.Now()
+	externalID, err := handler.SendMessage(ctx, message)
+	elapsed := time.Since(start).Seconds()
+	outbound.Dec()
+	if err == nil {
+		span.SetAttributes(attribute.String("message.external_id", externalID))
+	}
+	tracing.End(span, err)
+
+	f.stats.record(provider, err)
+	if err != nil {
+		metrics.MessagesFailed.WithLabelValues(provider, message.Type).Inc()
+		metrics.ProviderLatency.WithLabelValues(provider, "error").Observe(elapsed)
+	} else {
+		metrics.MessagesSent.WithLabelValues(provider, message.Type).Inc()
+		metrics.ProviderLatency.WithLabelValues(provider, "success").Observe(elapsed)
+	}
 	return message, externalID, err
 }
 
+// EstimateSMS reports how content would be sent as SMS and what it would
+// cost, without sending it.
+func (f *MessageHandlerFactory) EstimateSMS(content string, smartEncoding bool) entities.SMSEstimate {
+	if smartEncoding || f.smartEncoding {
+		content = SmartEncode(content)
+	}
+	seg := CountSegments(content)
+	return entities.SMSEstimate{
+		Content:       content,
+		Encoding:      seg.Encoding,
+		Segments:      seg.Segments,
+		Characters:    seg.Characters,
+		EstimatedCost: math.Round(float64(seg.Segments)*f.segmentPrice*1e6) / 1e6,
+		Currency:      f.currency,
+	}
+}
+
+// IntegrationProviders take everything they send with (URLs, tokens) from
+// an integration rather than from the server config.
+var IntegrationProviders = []string{"webhook", "slack", "teams", "telegram"}
+
+func isIntegrationProvider(name string) bool {
+	for _, p := range IntegrationProviders {
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IntegrationReady reports whether in carries what its provider needs to
+// send: a base URL for webhook, a bot token for telegram. Slack and Teams
+// take the webhook URL from each message, so any integration will do.
+func IntegrationReady(in entities.Integration) bool {
+	switch strings.ToLower(in.Name) {
+	case "webhook":
+		return in.BaseURL != ""
+	case "telegram":
+		return in.Token != ""
+	default:
+		return isIntegrationProvider(in.Name)
+	}
+}
+
+// Providers reports every registered provider, whether it is configured,
+// and its recent send success/error rates. An integration provider counts
+// as configured only when ready holds its name, i.e. at least one of its
+// integrations passes IntegrationReady.
+func (f *MessageHandlerFactory) Providers(ready map[string]bool) []ProviderStatus {
+	out := make([]ProviderStatus, 0, len(registeredProviders))
+	for _, name := range registeredProviders {
+		configured := f.IsHandlerAvailable(name)
+		if isIntegrationProvider(name) {
+			configured = configured && ready[name]
+		}
+		st := ProviderStatus{Name: name, Registered: true, Configured: configured}
+		f.stats.fill(&st)
+		out = append(out, st)
+	}
+	return out
+}
+
 func (f *MessageHandlerFactory) IsHandlerAvailable(integrationName string) bool {
 	switch strings.ToLower(integrationName) {
 	case "sendgrid":
 		return f.sendgridHandler != nil
-	case "twilio":
+	case "smtp":
+		return f.smtpHandler != nil
+	case "twilio", "whatsapp":
 		return f.twillioHandler != nil
 	case "ntfy":
 		return f.ntfyHandler != nil
+	case "webhook":
+		return f.webhookHandler != nil
+	case "slack":
+		return f.slackHandler != nil
+	case "teams":
+		return f.teamsHandler != nil
+	case "telegram":
+		return f.telegramHandler != nil
+	case "webpush":
+		return f.webpushHandler != nil
 	default:
 		return false
 	}
@@ -92,12 +309,30 @@ func (f *MessageHandlerFactory) ListAvailableHandlers() []string {
 	if f.sendgridHandler != nil {
 		available = append(available, "sendgrid")
 	}
+	if f.smtpHandler != nil {
+		available = append(available, "smtp")
+	}
 	if f.twillioHandler != nil {
-		available = append(available, "twilio")
+		available = append(available, "twilio", "whatsapp")
 	}
 	if f.ntfyHandler != nil {
 		available = append(available, "ntfy")
 	}
+	if f.webhookHandler != nil {
+		available = append(available, "webhook")
+	}
+	if f.slackHandler != nil {
+		available = append(available, "slack")
+	}
+	if f.teamsHandler != nil {
+		available = append(available, "teams")
+	}
+	if f.telegramHandler != nil {
+		available = append(available, "telegram")
+	}
+	if f.webpushHandler != nil {
+		available = append(available, "webpush")
+	}
 
 	return available
 }
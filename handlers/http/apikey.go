@@ -0,0 +1,75 @@
+package httphdl
+
+import (
+	"errors"
+	"net/http"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	uc   *usecases.APIKeyUsecase
+	auth *Authenticator
+}
+
+func NewAPIKeyHandler(uc *usecases.APIKeyUsecase, auth *Authenticator) *APIKeyHandler {
+	return &APIKeyHandler{uc: uc, auth: auth}
+}
+
+// Register mounts the key routes. Every route acts on the calling key's own
+// user, so any valid key may use them.
+func (h *APIKeyHandler) Register(rg *gin.RouterGroup) {
+	rg.Use(h.auth.RequireKey())
+	rg.POST("/", h.create)
+	rg.GET("/", h.list)
+	rg.POST(":id/rotate", h.rotate)
+	rg.DELETE(":id", h.revoke)
+}
+
+func (h *APIKeyHandler) create(c *gin.Context) {
+	var in entities.APIKey
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out, err := h.uc.CreateByKey(c.Request.Context(), currentAPIKey(c), in)
+	if errors.Is(err, usecases.ErrScopeNotGranted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *APIKeyHandler) list(c *gin.Context) {
+	out, err := h.uc.List(c.Request.Context(), currentAPIKey(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *APIKeyHandler) rotate(c *gin.Context) {
+	out, err := h.uc.Rotate(c.Request.Context(), currentAPIKey(c).UserID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *APIKeyHandler) revoke(c *gin.Context) {
+	out, err := h.uc.Revoke(c.Request.Context(), currentAPIKey(c).UserID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,79 @@
+package httphdl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAPIKeyRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newMemoryKeyRepo()
+	repo.users["u1"] = entities.User{ID: "u1", Active: true}
+	repo.users["u2"] = entities.User{ID: "u2", Active: true}
+	uc := usecases.NewAPIKeyUsecase(repo)
+
+	reader := issue(t, uc, "u1", "reader", "read")
+	sender := issue(t, uc, "u1", "sender", "send")
+	admin := issue(t, uc, "u1", "admin", "admin")
+	issue(t, uc, "u2", "other")
+
+	r := gin.New()
+	NewAPIKeyHandler(uc, NewAuthenticator(uc)).Register(r.Group("/api-keys/"))
+
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"list without a key", "", http.MethodGet, "/api-keys/", "", http.StatusUnauthorized},
+		{"list with a read key", reader, http.MethodGet, "/api-keys/", "", http.StatusOK},
+		{"list with a send key", sender, http.MethodGet, "/api-keys/", "", http.StatusOK},
+		{"read key creates read key", reader, http.MethodPost, "/api-keys/", `{"name": "ci", "scopes": ["read"]}`, http.StatusCreated},
+		{"read key cannot default to send", reader, http.MethodPost, "/api-keys/", `{"name": "ci"}`, http.StatusForbidden},
+		{"send key cannot mint admin", sender, http.MethodPost, "/api-keys/", `{"name": "ci", "scopes": ["admin"]}`, http.StatusForbidden},
+		{"admin key mints admin", admin, http.MethodPost, "/api-keys/", `{"name": "ops", "scopes": ["admin"]}`, http.StatusCreated},
+		{"unknown scope", admin, http.MethodPost, "/api-keys/", `{"name": "x", "scopes": ["root"]}`, http.StatusBadRequest},
+		{"rotate another user's key", admin, http.MethodPost, "/api-keys/key-other/rotate", "", http.StatusBadRequest},
+		{"revoke another user's key", admin, http.MethodDelete, "/api-keys/key-other", "", http.StatusNotFound},
+		{"rotate own key", sender, http.MethodPost, "/api-keys/key-reader/rotate", "", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			if tt.method == http.MethodGet && w.Code == http.StatusOK {
+				var keys []entities.APIKey
+				if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil {
+					t.Fatal(err)
+				}
+				for _, k := range keys {
+					if k.UserID != "u1" {
+						t.Errorf("listed key %s of user %s", k.ID, k.UserID)
+					}
+				}
+			}
+		})
+	}
+	if k := repo.keys["key-other"]; k.RevokedAt != nil {
+		t.Error("another user's key was revoked")
+	}
+}
@@ -0,0 +1,59 @@
+package httphdl
+
+import (
+	"net/http"
+	"strings"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyContextKey is where the authenticated entities.APIKey is stored on the gin context.
+const apiKeyContextKey = "api_key"
+
+type Authenticator struct{ uc *usecases.APIKeyUsecase }
+
+func NewAuthenticator(uc *usecases.APIKeyUsecase) *Authenticator { return &Authenticator{uc: uc} }
+
+// Require authenticates the request from "Authorization: Bearer <key>" or
+// "X-API-Key" and rejects keys that do not grant scope. An empty scope
+// accepts any valid key.
+func (a *Authenticator) Require(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader("X-API-Key")
+		if auth := c.GetHeader("Authorization"); secret == "" && strings.HasPrefix(auth, "Bearer ") {
+			secret = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if secret == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "api key required"})
+			return
+		}
+		key, err := a.uc.Authenticate(c.Request.Context(), secret)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if scope != "" && !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks scope: " + scope})
+			return
+		}
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// RequireKey accepts any valid key, whatever its scopes. It is for routes
+// that only touch the caller's own account, such as managing its keys.
+func (a *Authenticator) RequireKey() gin.HandlerFunc { return a.Require("") }
+
+// currentAPIKey returns the key stored by Authenticator.Require.
+func currentAPIKey(c *gin.Context) entities.APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := v.(entities.APIKey); ok {
+			return key
+		}
+	}
+	return entities.APIKey{}
+}
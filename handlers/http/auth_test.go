@@ -0,0 +1,256 @@
+package httphdl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryKeyRepo keeps API keys and users in memory. Methods the tests do
+// not need fall through to the nil embedded interface and panic.
+type memoryKeyRepo struct {
+	usecases.APIKeyUsecaseRepo
+	mu    sync.Mutex
+	keys  map[string]entities.APIKey
+	users map[string]entities.User
+}
+
+func newMemoryKeyRepo() *memoryKeyRepo {
+	return &memoryKeyRepo{keys: map[string]entities.APIKey{}, users: map[string]entities.User{}}
+}
+
+func (r *memoryKeyRepo) CreateAPIKey(_ context.Context, in entities.APIKey) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in.ID = "key-" + in.Name
+	r.keys[in.ID] = in
+	return in, nil
+}
+
+func (r *memoryKeyRepo) GetAPIKeyByHash(_ context.Context, hash string) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.keys {
+		if k.KeyHash == hash {
+			return k, nil
+		}
+	}
+	return entities.APIKey{}, errors.New("not found")
+}
+
+func (r *memoryKeyRepo) GetAPIKey(_ context.Context, id string) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok {
+		return entities.APIKey{}, errors.New("not found")
+	}
+	return k, nil
+}
+
+func (r *memoryKeyRepo) ListAPIKeys(_ context.Context, userID string) ([]entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []entities.APIKey
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryKeyRepo) UpdateAPIKey(_ context.Context, id string, in entities.APIKey) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok {
+		return entities.APIKey{}, errors.New("not found")
+	}
+	if in.LastUsedAt != nil {
+		k.LastUsedAt = in.LastUsedAt
+	}
+	if in.RevokedAt != nil {
+		k.RevokedAt = in.RevokedAt
+	}
+	r.keys[id] = k
+	return k, nil
+}
+
+func (r *memoryKeyRepo) GetUser(_ context.Context, id string) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return entities.User{}, errors.New("not found")
+	}
+	return u, nil
+}
+
+// issue creates a key for userID through the usecase and returns its secret.
+func issue(t *testing.T, uc *usecases.APIKeyUsecase, userID, name string, scopes ...string) string {
+	t.Helper()
+	key, err := uc.Create(context.Background(), entities.APIKey{UserID: userID, Name: name, Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.Secret
+}
+
+func TestHashAPIKey(t *testing.T) {
+	// SHA-256 of "abc" (FIPS 180-2, appendix B.1).
+	if got := usecases.HashAPIKey("abc"); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("HashAPIKey(abc) = %s", got)
+	}
+}
+
+func TestCreateAPIKeyStoresOnlyHash(t *testing.T) {
+	repo := newMemoryKeyRepo()
+	repo.users["u1"] = entities.User{ID: "u1", Active: true}
+	uc := usecases.NewAPIKeyUsecase(repo)
+
+	key, err := uc.Create(context.Background(), entities.APIKey{UserID: "u1", Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key.Secret, "mm_") || len(key.Secret) < 40 {
+		t.Errorf("secret = %q, want a long mm_ token", key.Secret)
+	}
+	stored := repo.keys[key.ID]
+	if stored.Secret != "" || stored.KeyHash != usecases.HashAPIKey(key.Secret) {
+		t.Errorf("stored key = %+v, want only the hash of the secret", stored)
+	}
+	if !strings.HasPrefix(key.Secret, stored.Prefix) || len(stored.Prefix) >= len(key.Secret)/2 {
+		t.Errorf("prefix %q gives away too much of the secret", stored.Prefix)
+	}
+	if strings.Join(stored.Scopes, ",") != "send,read" {
+		t.Errorf("default scopes = %v, want send,read", stored.Scopes)
+	}
+	if _, err := uc.Create(context.Background(), entities.APIKey{UserID: "u1", Name: "x", Scopes: []string{"root"}}); err == nil {
+		t.Error("created a key with an unknown scope")
+	}
+
+	other, err := uc.Create(context.Background(), entities.APIKey{UserID: "u1", Name: "ci2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Secret == key.Secret {
+		t.Error("two keys share a secret")
+	}
+}
+
+func TestAPIKeyHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{[]string{"send", "read"}, "send", true},
+		{[]string{"send", "read"}, "read", true},
+		{[]string{"send", "read"}, "admin", false},
+		{[]string{"read"}, "send", false},
+		{[]string{"admin"}, "send", true},
+		{[]string{"admin"}, "read", true},
+		{nil, "read", false},
+	}
+	for _, tt := range tests {
+		if got := (entities.APIKey{Scopes: tt.scopes}).HasScope(tt.scope); got != tt.want {
+			t.Errorf("%v HasScope(%s) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newMemoryKeyRepo()
+	repo.users["u1"] = entities.User{ID: "u1", Active: true}
+	repo.users["gone"] = entities.User{ID: "gone", Active: false}
+	uc := usecases.NewAPIKeyUsecase(repo)
+
+	sendRead := issue(t, uc, "u1", "default")
+	readOnly := issue(t, uc, "u1", "reader", "read")
+	admin := issue(t, uc, "u1", "admin", "admin")
+	inactive := issue(t, uc, "gone", "inactive")
+	revoked := issue(t, uc, "u1", "revoked")
+	expired := issue(t, uc, "u1", "expired")
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	k := repo.keys["key-revoked"]
+	k.RevokedAt = &past
+	repo.keys["key-revoked"] = k
+	k = repo.keys["key-expired"]
+	k.ExpiresAt = &past
+	repo.keys["key-expired"] = k
+
+	r := gin.New()
+	auth := NewAuthenticator(uc)
+	for _, scope := range []string{entities.APIKeyScopeSend, entities.APIKeyScopeRead, entities.APIKeyScopeAdmin} {
+		r.GET("/"+scope, auth.Require(scope), func(c *gin.Context) {
+			c.String(http.StatusOK, currentAPIKey(c).UserID)
+		})
+	}
+
+	tests := []struct {
+		name   string
+		scope  string
+		header string
+		value  string
+		want   int
+	}{
+		{"no key", "read", "", "", http.StatusUnauthorized},
+		{"unknown key", "read", "X-API-Key", "mm_nope", http.StatusUnauthorized},
+		{"bearer", "read", "Authorization", "Bearer " + sendRead, http.StatusOK},
+		{"x-api-key", "send", "X-API-Key", sendRead, http.StatusOK},
+		{"basic auth is not a key", "read", "Authorization", "Basic " + sendRead, http.StatusUnauthorized},
+		{"whitespace around key", "read", "X-API-Key", " " + sendRead + " ", http.StatusOK},
+		{"missing scope", "send", "X-API-Key", readOnly, http.StatusForbidden},
+		{"not admin", "admin", "X-API-Key", sendRead, http.StatusForbidden},
+		{"admin implies send", "send", "X-API-Key", admin, http.StatusOK},
+		{"admin", "admin", "X-API-Key", admin, http.StatusOK},
+		{"revoked", "read", "X-API-Key", revoked, http.StatusUnauthorized},
+		{"expired", "read", "X-API-Key", expired, http.StatusUnauthorized},
+		{"inactive user", "read", "X-API-Key", inactive, http.StatusUnauthorized},
+		{"hash is not a key", "read", "X-API-Key", usecases.HashAPIKey(sendRead), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.scope, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && w.Body.String() != "u1" {
+				t.Errorf("handler saw user %q, want u1", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthenticateRecordsLastUse(t *testing.T) {
+	repo := newMemoryKeyRepo()
+	repo.users["u1"] = entities.User{ID: "u1", Active: true}
+	uc := usecases.NewAPIKeyUsecase(repo)
+	secret := issue(t, uc, "u1", "ci")
+
+	key, err := uc.Authenticate(context.Background(), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.LastUsedAt == nil || repo.keys[key.ID].LastUsedAt == nil {
+		t.Error("last_used_at was not recorded")
+	}
+}
@@ -0,0 +1,71 @@
+package httphdl
+
+import (
+	"net/http"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallbackHandler manages the caller's status callback endpoint.
+type CallbackHandler struct {
+	uc   *usecases.StatusCallbackUsecase
+	auth *Authenticator
+}
+
+func NewCallbackHandler(uc *usecases.StatusCallbackUsecase, auth *Authenticator) *CallbackHandler {
+	return &CallbackHandler{uc: uc, auth: auth}
+}
+
+func (h *CallbackHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("/", h.auth.Require(entities.APIKeyScopeRead), h.get)
+	rg.PUT("/", h.auth.Require(entities.APIKeyScopeSend), h.set)
+	rg.DELETE("/", h.auth.Require(entities.APIKeyScopeSend), h.clear)
+	rg.POST("/rotate-secret", h.auth.Require(entities.APIKeyScopeSend), h.rotate)
+}
+
+type callbackRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+func (h *CallbackHandler) get(c *gin.Context) {
+	out, err := h.uc.Settings(c.Request.Context(), currentAPIKey(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *CallbackHandler) set(c *gin.Context) {
+	var in callbackRequest
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out, err := h.uc.SetURL(c.Request.Context(), currentAPIKey(c).UserID, in.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *CallbackHandler) clear(c *gin.Context) {
+	if err := h.uc.ClearURL(c.Request.Context(), currentAPIKey(c).UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *CallbackHandler) rotate(c *gin.Context) {
+	out, err := h.uc.RotateSecret(c.Request.Context(), currentAPIKey(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,77 @@
+package httphdl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callbackUserRepo serves one user's callback settings. Methods the tests
+// do not need fall through to the nil embedded interface and panic.
+type callbackUserRepo struct {
+	usecases.StatusCallbackUsecaseRepo
+	user entities.User
+}
+
+func (r *callbackUserRepo) GetUser(context.Context, string) (entities.User, error) {
+	return r.user, nil
+}
+
+func (r *callbackUserRepo) UpdateUserCallback(_ context.Context, _ string, url, secret string) (entities.User, error) {
+	r.user.CallbackURL, r.user.CallbackSecret = url, secret
+	return r.user, nil
+}
+
+func TestCallbackRouteScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := newMemoryKeyRepo()
+	keys.users["u1"] = entities.User{ID: "u1", Active: true}
+	keyUC := usecases.NewAPIKeyUsecase(keys)
+	reader := issue(t, keyUC, "u1", "reader", "read")
+	sender := issue(t, keyUC, "u1", "sender", "send")
+
+	uc := usecases.NewStatusCallbackUsecase(&callbackUserRepo{user: entities.User{ID: "u1"}}, nil, 1, nil)
+	r := gin.New()
+	NewCallbackHandler(uc, NewAuthenticator(keyUC)).Register(r.Group("/callback/"))
+
+	const body = `{"url": "https://93.184.216.34/hook"}`
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"get without a key", "", http.MethodGet, "/callback/", "", http.StatusUnauthorized},
+		{"get with a read key", reader, http.MethodGet, "/callback/", "", http.StatusOK},
+		{"get with a send key", sender, http.MethodGet, "/callback/", "", http.StatusForbidden},
+		{"set with a read key", reader, http.MethodPut, "/callback/", body, http.StatusForbidden},
+		{"set with a send key", sender, http.MethodPut, "/callback/", body, http.StatusOK},
+		{"rotate with a read key", reader, http.MethodPost, "/callback/rotate-secret", "", http.StatusForbidden},
+		{"rotate with a send key", sender, http.MethodPost, "/callback/rotate-secret", "", http.StatusOK},
+		{"clear with a read key", reader, http.MethodDelete, "/callback/", "", http.StatusForbidden},
+		{"clear with a send key", sender, http.MethodDelete, "/callback/", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
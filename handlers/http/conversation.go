@@ -0,0 +1,43 @@
+package httphdl
+
+import (
+	"net/http"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConversationHandler exposes the caller's two-way SMS threads.
+type ConversationHandler struct {
+	uc   *usecases.ConversationUsecase
+	auth *Authenticator
+}
+
+func NewConversationHandler(uc *usecases.ConversationUsecase, auth *Authenticator) *ConversationHandler {
+	return &ConversationHandler{uc: uc, auth: auth}
+}
+
+func (h *ConversationHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("/", h.auth.Require(entities.APIKeyScopeRead), h.list)
+	rg.GET(":id/messages", h.auth.Require(entities.APIKeyScopeRead), h.messages)
+}
+
+func (h *ConversationHandler) list(c *gin.Context) {
+	out, err := h.uc.List(c.Request.Context(), currentAPIKey(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *ConversationHandler) messages(c *gin.Context) {
+	out, err := h.uc.Messages(c.Request.Context(), currentAPIKey(c).UserID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
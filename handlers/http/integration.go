@@ -7,16 +7,25 @@ import (
 	"messenger-module/usecases"
 )
 
-type IntegrationHandler struct{ uc *usecases.IntegrationUsecase }
+type IntegrationHandler struct {
+	uc   *usecases.IntegrationUsecase
+	auth *Authenticator
+}
 
-func NewIntegrationHandler(uc *usecases.IntegrationUsecase) *IntegrationHandler { return &IntegrationHandler{uc: uc} }
+func NewIntegrationHandler(uc *usecases.IntegrationUsecase, auth *Authenticator) *IntegrationHandler {
+	return &IntegrationHandler{uc: uc, auth: auth}
+}
 
+// Register mounts the routes. They manage every tenant's data, so all of
+// them need an admin key.
 func (h *IntegrationHandler) Register(rg *gin.RouterGroup) {
+	rg.Use(h.auth.Require(entities.APIKeyScopeAdmin))
 	rg.POST("/", h.create)
 	rg.GET("/", h.list)
 	rg.GET(":id", h.get)
 	rg.PUT(":id", h.update)
 	rg.DELETE(":id", h.delete)
+	rg.POST(":id/restore", h.restore)
 }
 
 func (h *IntegrationHandler) create(c *gin.Context) {
@@ -27,7 +36,11 @@ func (h *IntegrationHandler) create(c *gin.Context) {
 	c.JSON(http.StatusCreated, out)
 }
 func (h *IntegrationHandler) list(c *gin.Context) {
-	out, err := h.uc.List(c.Request.Context())
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+	out, err := h.uc.List(c.Request.Context(), opts)
 	if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
 	c.JSON(http.StatusOK, out)
 }
@@ -50,3 +63,8 @@ func (h *IntegrationHandler) delete(c *gin.Context) {
 	if err := h.uc.Delete(c.Request.Context(), id); err != nil { c.JSON(http.StatusNotFound, gin.H{"error": err.Error()}); return }
 	c.Status(http.StatusNoContent)
 }
+func (h *IntegrationHandler) restore(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.uc.Restore(c.Request.Context(), id); err != nil { c.JSON(http.StatusNotFound, gin.H{"error": err.Error()}); return }
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package httphdl
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMediaUpload bounds upload requests: the largest MMS file plus room
+// for the multipart framing.
+const maxMediaUpload = 6 << 20
+
+// MediaHandler accepts MMS media uploads and serves them to carriers.
+type MediaHandler struct {
+	uc   *usecases.MediaUsecase
+	auth *Authenticator
+}
+
+func NewMediaHandler(uc *usecases.MediaUsecase, auth *Authenticator) *MediaHandler {
+	return &MediaHandler{uc: uc, auth: auth}
+}
+
+func (h *MediaHandler) Register(rg *gin.RouterGroup) {
+	rg.POST("/", h.auth.Require(entities.APIKeyScopeSend), h.upload)
+	// Carriers fetch media without credentials; the signed URL is the credential.
+	rg.GET(":id", h.serve)
+	rg.HEAD(":id", h.serve)
+}
+
+// upload takes a multipart form with the file in "file".
+func (h *MediaHandler) upload(c *gin.Context) {
+	if !h.uc.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": usecases.ErrMediaDisabled.Error()})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMediaUpload)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large for MMS"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a multipart file field named file is required"})
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	out, err := h.uc.Upload(c.Request.Context(), currentAPIKey(c).UserID, header.Filename, header.Header.Get("Content-Type"), data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *MediaHandler) serve(c *gin.Context) {
+	media, err := h.uc.Open(c.Request.Context(), c.Param("id"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", media.ContentType)
+	c.Header("Content-Length", strconv.Itoa(len(media.Data)))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-transform")
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Data(http.StatusOK, media.ContentType, media.Data)
+}
@@ -1,26 +1,38 @@
 package httphdl
 
 import (
-	"context"
+	"errors"
+	"io"
 	"messenger-module/entities"
 	"messenger-module/usecases"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// streamHeartbeat keeps idle streams alive through proxies.
+const streamHeartbeat = 15 * time.Second
+
 type MessageHandler struct {
-	uc *usecases.MessageUsecase
+	uc   *usecases.MessageUsecase
+	feed *usecases.StatusFeed
+	auth *Authenticator
 }
 
-func NewMessageHandler(uc *usecases.MessageUsecase) *MessageHandler { return &MessageHandler{uc: uc} }
+func NewMessageHandler(uc *usecases.MessageUsecase, feed *usecases.StatusFeed, auth *Authenticator) *MessageHandler {
+	return &MessageHandler{uc: uc, feed: feed, auth: auth}
+}
 
 func (h *MessageHandler) Register(rg *gin.RouterGroup) {
-	rg.POST("/", h.create)
-	rg.GET("/", h.list)
-	rg.GET(":id", h.get)
-	rg.PUT(":id", h.update)
-	rg.DELETE(":id", h.delete)
+	rg.POST("/", h.auth.Require(entities.APIKeyScopeSend), h.create)
+	rg.GET("/", h.auth.Require(entities.APIKeyScopeRead), h.list)
+	rg.GET("stream", h.auth.Require(entities.APIKeyScopeRead), h.stream)
+	rg.POST("segments", h.auth.Require(entities.APIKeyScopeRead), h.segments)
+	rg.GET(":id", h.auth.Require(entities.APIKeyScopeRead), h.get)
+	rg.PUT(":id", h.auth.Require(entities.APIKeyScopeAdmin), h.update)
+	rg.DELETE(":id", h.auth.Require(entities.APIKeyScopeAdmin), h.delete)
+	rg.POST(":id/restore", h.auth.Require(entities.APIKeyScopeAdmin), h.restore)
 }
 
 func (h *MessageHandler) create(c *gin.Context) {
@@ -38,7 +50,10 @@ func (h *MessageHandler) create(c *gin.Context) {
 		}
 	}
 
-	message, err := h.uc.Create(c, input)
+	// Messages are always sent on behalf of the key's owner
+	input.UserID = currentAPIKey(c).UserID
+
+	message, err := h.uc.Create(c.Request.Context(), input)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -47,8 +62,26 @@ func (h *MessageHandler) create(c *gin.Context) {
 	c.JSON(http.StatusCreated, message)
 }
 
+// segments is a dry run: it reports how content would be encoded as SMS,
+// how many segments it takes and what it would cost, without sending it.
+func (h *MessageHandler) segments(c *gin.Context) {
+	var input struct {
+		Content       string `json:"content" binding:"required"`
+		SmartEncoding bool   `json:"smart_encoding"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.uc.Estimate(input.Content, input.SmartEncoding))
+}
+
 func (h *MessageHandler) list(c *gin.Context) {
-	out, err := h.uc.List(context.Background())
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+	out, err := h.uc.ListByUser(c.Request.Context(), currentAPIKey(c).UserID, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -58,7 +91,7 @@ func (h *MessageHandler) list(c *gin.Context) {
 
 func (h *MessageHandler) get(c *gin.Context) {
 	id := c.Param("id")
-	out, err := h.uc.Get(c.Request.Context(), id)
+	out, err := h.ownedMessage(c, id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -68,6 +101,10 @@ func (h *MessageHandler) get(c *gin.Context) {
 
 func (h *MessageHandler) update(c *gin.Context) {
 	id := c.Param("id")
+	if _, err := h.ownedMessage(c, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	var in entities.Message
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -83,9 +120,73 @@ func (h *MessageHandler) update(c *gin.Context) {
 
 func (h *MessageHandler) delete(c *gin.Context) {
 	id := c.Param("id")
+	if _, err := h.ownedMessage(c, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err := h.uc.Delete(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+func (h *MessageHandler) restore(c *gin.Context) {
+	id := c.Param("id")
+	// Deleted messages are invisible to Get, so check ownership on the deleted row
+	msg, err := h.uc.GetDeleted(c.Request.Context(), id)
+	if err != nil || msg.UserID != currentAPIKey(c).UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := h.uc.Restore(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ownedMessage loads a message and hides messages of other users as not found.
+func (h *MessageHandler) ownedMessage(c *gin.Context, id string) (entities.Message, error) {
+	out, err := h.uc.Get(c.Request.Context(), id)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	if out.UserID != currentAPIKey(c).UserID {
+		return entities.Message{}, errors.New("not found")
+	}
+	return out, nil
+}
+
+// stream pushes the caller's message status changes as server-sent events
+// until the client disconnects or the server shuts down.
+func (h *MessageHandler) stream(c *gin.Context) {
+	events, cancel := h.feed.Subscribe(currentAPIKey(c).UserID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("status", ev)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().UTC().Format(time.RFC3339))
+			return true
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package httphdl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deletedMessageRepo holds soft-deleted messages. Methods the tests do not
+// need fall through to the nil embedded interface and panic.
+type deletedMessageRepo struct {
+	usecases.MessageUsecaseRepo
+	deleted  map[string]entities.Message
+	restored []string
+}
+
+func (r *deletedMessageRepo) GetDeletedMessage(_ context.Context, id string) (entities.Message, error) {
+	m, ok := r.deleted[id]
+	if !ok {
+		return entities.Message{}, errors.New("not found")
+	}
+	return m, nil
+}
+
+func (r *deletedMessageRepo) RestoreMessage(_ context.Context, id string) error {
+	r.restored = append(r.restored, id)
+	delete(r.deleted, id)
+	return nil
+}
+
+func TestRestoreMessage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := newMemoryKeyRepo()
+	keys.users["u1"] = entities.User{ID: "u1", Active: true}
+	keyUC := usecases.NewAPIKeyUsecase(keys)
+	admin := issue(t, keyUC, "u1", "admin", "admin")
+	reader := issue(t, keyUC, "u1", "reader", "read")
+
+	tests := []struct {
+		name        string
+		key         string
+		id          string
+		want        int
+		wantRestore bool
+	}{
+		{"own message", admin, "mine", http.StatusNoContent, true},
+		{"another user's message", admin, "theirs", http.StatusNotFound, false},
+		{"unknown message", admin, "missing", http.StatusNotFound, false},
+		{"read key", reader, "mine", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &deletedMessageRepo{deleted: map[string]entities.Message{
+				"mine":   {ID: "mine", UserID: "u1"},
+				"theirs": {ID: "theirs", UserID: "u2"},
+			}}
+			uc := usecases.NewMessageUsecase(repo, nil, nil, nil, nil)
+			r := gin.New()
+			NewMessageHandler(uc, nil, NewAuthenticator(keyUC)).Register(r.Group("/messages/"))
+
+			req := httptest.NewRequest(http.MethodPost, "/messages/"+tt.id+"/restore", nil)
+			req.Header.Set("X-API-Key", tt.key)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			if restored := len(repo.restored) > 0; restored != tt.wantRestore {
+				t.Errorf("restored = %v, want %v", repo.restored, tt.wantRestore)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package httphdl
+
+import (
+	"strconv"
+	"time"
+
+	"messenger-module/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records request latency per route template, so path parameters
+// do not explode label cardinality. Unmatched routes share one label.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPLatency.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -1,22 +1,32 @@
 package httphdl
 
 import (
+	"errors"
 	"net/http"
 	"github.com/gin-gonic/gin"
 	"messenger-module/entities"
 	"messenger-module/usecases"
 )
 
-type PlanHandler struct{ uc *usecases.PlanUsecase }
+type PlanHandler struct {
+	uc   *usecases.PlanUsecase
+	auth *Authenticator
+}
 
-func NewPlanHandler(uc *usecases.PlanUsecase) *PlanHandler { return &PlanHandler{uc: uc} }
+func NewPlanHandler(uc *usecases.PlanUsecase, auth *Authenticator) *PlanHandler {
+	return &PlanHandler{uc: uc, auth: auth}
+}
 
+// Register mounts the routes. They manage every tenant's data, so all of
+// them need an admin key.
 func (h *PlanHandler) Register(rg *gin.RouterGroup) {
+	rg.Use(h.auth.Require(entities.APIKeyScopeAdmin))
 	rg.POST("/", h.create)
 	rg.GET("/", h.list)
 	rg.GET(":id", h.get)
 	rg.PUT(":id", h.update)
 	rg.DELETE(":id", h.delete)
+	rg.POST(":id/restore", h.restore)
 }
 
 func (h *PlanHandler) create(c *gin.Context) {
@@ -27,7 +37,11 @@ func (h *PlanHandler) create(c *gin.Context) {
 	c.JSON(http.StatusCreated, out)
 }
 func (h *PlanHandler) list(c *gin.Context) {
-	out, err := h.uc.List(c.Request.Context())
+	opts, ok := listOptions(c)
+	if !ok {
+		return
+	}
+	out, err := h.uc.List(c.Request.Context(), opts)
 	if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
 	c.JSON(http.StatusOK, out)
 }
@@ -47,6 +61,19 @@ func (h *PlanHandler) update(c *gin.Context) {
 }
 func (h *PlanHandler) delete(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.uc.Delete(c.Request.Context(), id); err != nil { c.JSON(http.StatusNotFound, gin.H{"error": err.Error()}); return }
+	if err := h.uc.Delete(c.Request.Context(), id); err != nil {
+		var conflict *usecases.ConflictError
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "references": conflict.References})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+func (h *PlanHandler) restore(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.uc.Restore(c.Request.Context(), id); err != nil { c.JSON(http.StatusNotFound, gin.H{"error": err.Error()}); return }
 	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,34 @@
+package httphdl
+
+import (
+	"net/http"
+
+	"messenger-module/entities"
+	"messenger-module/handlers"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProviderHandler struct {
+	factory      *handlers.MessageHandlerFactory
+	integrations *usecases.IntegrationUsecase
+	auth         *Authenticator
+}
+
+func NewProviderHandler(factory *handlers.MessageHandlerFactory, integrations *usecases.IntegrationUsecase, auth *Authenticator) *ProviderHandler {
+	return &ProviderHandler{factory: factory, integrations: integrations, auth: auth}
+}
+
+func (h *ProviderHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("/", h.auth.Require(entities.APIKeyScopeRead), h.list)
+}
+
+func (h *ProviderHandler) list(c *gin.Context) {
+	ready, err := h.integrations.ReadyProviders(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.factory.Providers(ready))
+}
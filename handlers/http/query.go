@@ -0,0 +1,22 @@
+package httphdl
+
+import (
+	"net/http"
+	"strconv"
+
+	"messenger-module/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listOptions reads common list query parameters such as include_deleted=true.
+// include_deleted is an admin option: for any other key it answers 403 and
+// returns false, and the handler should stop.
+func listOptions(c *gin.Context) (entities.ListOptions, bool) {
+	includeDeleted, _ := strconv.ParseBool(c.Query("include_deleted"))
+	if includeDeleted && !currentAPIKey(c).HasScope(entities.APIKeyScopeAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "include_deleted requires the admin scope"})
+		return entities.ListOptions{}, false
+	}
+	return entities.ListOptions{IncludeDeleted: includeDeleted}, true
+}
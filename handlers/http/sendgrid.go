@@ -2,6 +2,7 @@ package httphdl
 
 import (
 	"net/http"
+	"net/mail"
 
 	"messenger-module/entities"
 	"messenger-module/handlers"
@@ -16,18 +17,25 @@ type SendGridEmailRequest struct {
 	Subject     string `json:"subject" binding:"required"`
 	PlainText   string `json:"plain_text" binding:"required"`
 	HTMLContent string `json:"html_content"`
+
+	CC          []string              `json:"cc"`
+	BCC         []string              `json:"bcc"`
+	ReplyTo     string                `json:"reply_to"`
+	Headers     map[string]string     `json:"headers"`
+	Attachments []entities.Attachment `json:"attachments"`
 }
 
 type SendGridHTTPHandler struct {
 	sender *handlers.SendGridHandler
+	auth   *Authenticator
 }
 
-func NewSendGridHTTPHandler(sender *handlers.SendGridHandler) *SendGridHTTPHandler {
-	return &SendGridHTTPHandler{sender: sender}
+func NewSendGridHTTPHandler(sender *handlers.SendGridHandler, auth *Authenticator) *SendGridHTTPHandler {
+	return &SendGridHTTPHandler{sender: sender, auth: auth}
 }
 
 func (h *SendGridHTTPHandler) Register(rg *gin.RouterGroup) {
-	rg.POST("/send", h.sendEmail)
+	rg.POST("/send", h.auth.Require(entities.APIKeyScopeSend), h.sendEmail)
 }
 
 func (h *SendGridHTTPHandler) sendEmail(c *gin.Context) {
@@ -38,19 +46,33 @@ func (h *SendGridHTTPHandler) sendEmail(c *gin.Context) {
 	}
 
 	// Build entities.Message to send via MessageHandler interface
+	to := req.ToEmail
+	if req.ToName != "" {
+		to = (&mail.Address{Name: req.ToName, Address: req.ToEmail}).String()
+	}
 	msg := entities.Message{
 		Type:        "email",
 		Subject:     req.Subject,
 		Content:     req.PlainText,
-		Destination: req.ToEmail,
+		HTMLContent: req.HTMLContent,
+		Destination: to,
+		CC:          req.CC,
+		BCC:         req.BCC,
+		ReplyTo:     req.ReplyTo,
+		Headers:     req.Headers,
+		Attachments: req.Attachments,
+	}
+	if err := h.sender.ValidateMessage(msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	_, err := h.sender.SendMessage(msg)
+	externalID, err := h.sender.SendMessage(c.Request.Context(), msg)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Email sent successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Email sent successfully", "external_id": externalID})
 }
@@ -0,0 +1,65 @@
+package httphdl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+	"messenger-module/handlers"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendEmailBody is a valid send request with extra appended to it.
+func sendEmailBody(extra string) string {
+	return `{"to_email": "jane@example.com", "subject": "Hi", "plain_text": "Hello", ` + extra + `}`
+}
+
+func TestSendEmailValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newMemoryKeyRepo()
+	repo.users["u1"] = entities.User{ID: "u1", Active: true}
+	uc := usecases.NewAPIKeyUsecase(repo)
+
+	sg, err := handlers.NewSendGridHandler(confs.SendGridConfig{APIKey: "SG.test", FromEmail: "noreply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	NewSendGridHTTPHandler(sg, NewAuthenticator(uc)).Register(r.Group("/emails/"))
+
+	sender := issue(t, uc, "u1", "sender", "send")
+	tests := []struct {
+		name string
+		key  string
+		body string
+		want int
+	}{
+		{"no key", "", `{}`, http.StatusUnauthorized},
+		{"read-only key", issue(t, uc, "u1", "reader", "read"), `{}`, http.StatusForbidden},
+		// The requests below fail validation, so nothing is sent.
+		{"send key", sender, `{}`, http.StatusBadRequest},
+		{"reserved header", sender, sendEmailBody(`"headers": {"Sender": "ceo@example.com"}`), http.StatusBadRequest},
+		{"resent header", sender, sendEmailBody(`"headers": {"Resent-To": "list@example.com"}`), http.StatusBadRequest},
+		{"header injection", sender, sendEmailBody(`"headers": {"X-Campaign": "a\r\nBcc: list@example.com"}`), http.StatusBadRequest},
+		{"bad bcc", sender, sendEmailBody(`"bcc": ["not an address"]`), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/emails/send", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
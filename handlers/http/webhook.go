@@ -1,29 +1,160 @@
 package httphdl
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"messenger-module/entities"
+	"messenger-module/metrics"
 	"messenger-module/usecases"
 
 	"github.com/gin-gonic/gin"
+	"github.com/twilio/twilio-go/client"
 )
 
 type WebhookHandler struct {
 	msgUC  *usecases.MessageUsecase
 	statUC *usecases.MessageStatusUsecase
+	convUC *usecases.ConversationUsecase
+	logger *slog.Logger
+
+	// twilioAuthToken verifies X-Twilio-Signature; baseURL is the public
+	// URL Twilio calls us on, which the signature covers.
+	twilioAuthToken string
+	baseURL         string
 }
 
-func NewWebhookHandler(msgUC *usecases.MessageUsecase, statUC *usecases.MessageStatusUsecase) *WebhookHandler {
-	return &WebhookHandler{msgUC: msgUC, statUC: statUC}
+func NewWebhookHandler(msgUC *usecases.MessageUsecase, statUC *usecases.MessageStatusUsecase, convUC *usecases.ConversationUsecase, twilioAuthToken, baseURL string, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		msgUC:           msgUC,
+		statUC:          statUC,
+		convUC:          convUC,
+		logger:          logger,
+		twilioAuthToken: twilioAuthToken,
+		baseURL:         strings.TrimRight(baseURL, "/"),
+	}
 }
 
 func (h *WebhookHandler) Register(rg *gin.RouterGroup) {
 	rg.POST("/sendgrid", h.sendgrid)
-	rg.POST("/twilio", h.twilio)
+	rg.POST("/twilio", h.verifyTwilio, h.twilio)
+	rg.POST("/twilio/inbound", h.verifyTwilio, h.twilioInbound)
+}
+
+// verifyTwilio rejects requests without a valid X-Twilio-Signature: the
+// base64 HMAC-SHA1, keyed with the auth token, of the full request URL
+// followed by every form parameter name and value, sorted by name.
+func (h *WebhookHandler) verifyTwilio(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to parse form"})
+		return
+	}
+	signature := c.GetHeader("X-Twilio-Signature")
+	if h.twilioAuthToken == "" || signature == "" {
+		metrics.WebhooksRejected.WithLabelValues("twilio").Inc()
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid Twilio signature"})
+		return
+	}
+	params := make(map[string]string, len(c.Request.PostForm))
+	for name, values := range c.Request.PostForm {
+		params[name] = values[0]
+	}
+	validator := client.NewRequestValidator(h.twilioAuthToken)
+	if !validator.Validate(h.requestURL(c), params, signature) {
+		metrics.WebhooksRejected.WithLabelValues("twilio").Inc()
+		h.logger.WarnContext(c.Request.Context(), "twilio webhook signature mismatch", "path", c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid Twilio signature"})
+		return
+	}
+	c.Next()
+}
+
+// requestURL is the URL Twilio called: WEBHOOK_BASE_URL and the request
+// path, or what the request itself says when no base URL is configured.
+func (h *WebhookHandler) requestURL(c *gin.Context) string {
+	if h.baseURL != "" {
+		return h.baseURL + c.Request.URL.RequestURI()
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+}
+
+// emptyTwiML acknowledges an inbound message without replying to it.
+const emptyTwiML = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+
+// twilioInbound stores an SMS sent to one of our numbers. Point the number's
+// "A message comes in" webhook here.
+func (h *WebhookHandler) twilioInbound(c *gin.Context) {
+	metrics.WebhooksReceived.WithLabelValues("twilio").Inc()
+	h.handleInbound(c)
+}
+
+func (h *WebhookHandler) handleInbound(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := c.Request.ParseForm(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse form"})
+		return
+	}
+
+	in := usecases.InboundMessage{
+		ExternalID: c.PostForm("MessageSid"),
+		From:       c.PostForm("From"),
+		To:         c.PostForm("To"),
+		Body:       c.PostForm("Body"),
+	}
+	// MMS media arrives as NumMedia and MediaUrl0, MediaUrl1, ...
+	if n, err := strconv.Atoi(c.PostForm("NumMedia")); err == nil {
+		for i := 0; i < n; i++ {
+			if u := c.PostForm("MediaUrl" + strconv.Itoa(i)); u != "" {
+				in.MediaURLs = append(in.MediaURLs, u)
+			}
+		}
+	}
+	if in.ExternalID == "" || in.From == "" || in.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MessageSid, From and To are required"})
+		return
+	}
+
+	msg, err := h.convUC.Receive(ctx, in)
+	switch {
+	case errors.Is(err, usecases.ErrNoConversation):
+		// Acknowledge anyway; Twilio retrying will not find an owner either.
+		metrics.WebhooksUnmatched.WithLabelValues("twilio").Inc()
+		h.logger.WarnContext(ctx, "inbound message matched no conversation or number owner", "message_sid", in.ExternalID, "from", in.From, "to", in.To)
+	case err != nil:
+		h.logger.ErrorContext(ctx, "failed to store inbound message", "message_sid", in.ExternalID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	default:
+		metrics.WebhooksProcessed.WithLabelValues("twilio").Inc()
+		h.logger.InfoContext(ctx, "twilio inbound webhook received", "message_sid", in.ExternalID, "message_id", msg.ID)
+	}
+	c.Data(http.StatusOK, "text/xml", []byte(emptyTwiML))
+}
+
+// whatsAppErrors explains the Twilio error codes most often seen on failed
+// WhatsApp messages.
+var whatsAppErrors = map[string]string{
+	"63003": "recipient is not a WhatsApp user",
+	"63005": "WhatsApp did not accept the content",
+	"63013": "WhatsApp policy violation",
+	"63016": "outside the 24-hour session window; send a content template",
+	"63018": "WhatsApp rate limit exceeded",
+	"63021": "invalid content for WhatsApp",
+	"63024": "invalid recipient",
+	"63032": "WhatsApp will not deliver to this user",
+	"63049": "Meta chose not to deliver this marketing message",
 }
 
 type genericWebhook struct {
@@ -33,13 +164,13 @@ type genericWebhook struct {
 	Timestamp       int64  `json:"timestamp"`
 }
 
-func (h *WebhookHandler) handleGeneric(c *gin.Context, body genericWebhook) {
+func (h *WebhookHandler) handleGeneric(c *gin.Context, provider string, body genericWebhook) {
 	if body.ExternalID == "" || body.Status == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "external_id and status are required"})
 		return
 	}
 	// Find message by external id
-	msgs, err := h.msgUC.List(c.Request.Context())
+	msgs, err := h.msgUC.List(c.Request.Context(), entities.ListOptions{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -52,6 +183,8 @@ func (h *WebhookHandler) handleGeneric(c *gin.Context, body genericWebhook) {
 		}
 	}
 	if msg.ID == "" {
+		metrics.WebhooksUnmatched.WithLabelValues(provider).Inc()
+		h.logger.WarnContext(c.Request.Context(), "webhook event matched no message", "provider", provider, "external_id", body.ExternalID)
 		c.JSON(http.StatusNotFound, gin.H{"error": "message not found for external_id"})
 		return
 	}
@@ -85,9 +218,11 @@ func (h *WebhookHandler) handleGeneric(c *gin.Context, body genericWebhook) {
 	}
 	_, err = h.statUC.Create(c.Request.Context(), statusIn)
 	if err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to record webhook status", "provider", provider, "message_id", msg.ID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	metrics.WebhooksProcessed.WithLabelValues(provider).Inc()
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -108,22 +243,18 @@ func (h *WebhookHandler) sendgrid(c *gin.Context) {
 	// SendGrid sends an array of events
 	var events []sendgridEvent
 	if err := c.ShouldBindJSON(&events); err != nil {
-		fmt.Printf("SendGrid webhook error: %v\n", err)
+		h.logger.WarnContext(c.Request.Context(), "invalid sendgrid webhook payload", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event format"})
 		return
 	}
 
-	fmt.Printf("SendGrid webhook received %d events\n", len(events))
+	ctx := c.Request.Context()
+	h.logger.InfoContext(ctx, "sendgrid webhook received", "events", len(events))
+	metrics.WebhooksReceived.WithLabelValues("sendgrid").Add(float64(len(events)))
 
 	// Process each event
 	processedCount := 0
 	for idx, event := range events {
-		fmt.Printf("\n=== Processing SendGrid Event %d/%d ===\n", idx+1, len(events))
-		fmt.Printf("Event Type: %s\n", event.Event)
-		fmt.Printf("Email: %s\n", event.Email)
-		fmt.Printf("SMTP-ID: '%s'\n", event.SMTPId)
-		fmt.Printf("SG-Message-ID: '%s'\n", event.SGMessageID)
-		fmt.Printf("Timestamp: %d\n", event.Timestamp)
 
 		// Get external ID from event
 		externalID := event.SMTPId
@@ -145,9 +276,9 @@ func (h *WebhookHandler) sendgrid(c *gin.Context) {
 		// Remove angle brackets if present
 		extractedID = strings.Trim(extractedID, "<>")
 
-		fmt.Printf("🔍 Looking for message:\n")
-		fmt.Printf("   Raw external_id: '%s'\n", externalID)
-		fmt.Printf("   Extracted ID: '%s'\n", extractedID)
+		h.logger.DebugContext(ctx, "processing sendgrid event",
+			"index", idx, "event", event.Event, "email", event.Email,
+			"external_id", externalID, "extracted_id", extractedID, "timestamp", event.Timestamp)
 
 		// Map SendGrid event to our status
 		var status string
@@ -163,14 +294,14 @@ func (h *WebhookHandler) sendgrid(c *gin.Context) {
 		case "deferred":
 			status = "deferred"
 		default:
-			fmt.Printf("Unknown SendGrid event type: %s\n", event.Event)
+			h.logger.DebugContext(ctx, "ignoring sendgrid event type", "event", event.Event)
 			continue
 		}
 
 		// Find message by external id
-		msgs, err := h.msgUC.List(c.Request.Context())
+		msgs, err := h.msgUC.List(ctx, entities.ListOptions{})
 		if err != nil {
-			fmt.Printf("Error listing messages: %v\n", err)
+			h.logger.ErrorContext(ctx, "failed to list messages", "error", err)
 			continue
 		}
 
@@ -187,12 +318,8 @@ func (h *WebhookHandler) sendgrid(c *gin.Context) {
 		}
 
 		if msg.ID == "" {
-			for i, m := range msgs {
-				if i >= 3 {
-					break
-				}
-				fmt.Printf("      [%d] '%s'\n", i+1, m.ExternalID)
-			}
+			metrics.WebhooksUnmatched.WithLabelValues("sendgrid").Inc()
+			h.logger.WarnContext(ctx, "webhook event matched no message", "provider", "sendgrid", "external_id", extractedID)
 			continue
 		}
 
@@ -231,19 +358,21 @@ func (h *WebhookHandler) sendgrid(c *gin.Context) {
 			statusIn.DateDeferred = timestamp
 		}
 
-		_, err = h.statUC.Create(c.Request.Context(), statusIn)
+		_, err = h.statUC.Create(ctx, statusIn)
 		if err != nil {
-			fmt.Printf("Error creating status: %v\n", err)
+			h.logger.ErrorContext(ctx, "failed to record webhook status", "provider", "sendgrid", "message_id", msg.ID, "error", err)
 			continue
 		}
 
 		processedCount++
+		metrics.WebhooksProcessed.WithLabelValues("sendgrid").Inc()
 	}
 
 	c.JSON(http.StatusOK, gin.H{"ok": true, "processed": processedCount, "total": len(events)})
 }
 
 func (h *WebhookHandler) twilio(c *gin.Context) {
+	metrics.WebhooksReceived.WithLabelValues("twilio").Inc()
 	// Twilio posts form-encoded by default
 	if c.ContentType() == "application/x-www-form-urlencoded" || c.Request.Method == "POST" {
 		// Parse form data first
@@ -255,6 +384,13 @@ func (h *WebhookHandler) twilio(c *gin.Context) {
 		messageSid := c.PostForm("MessageSid")
 		messageStatus := c.PostForm("MessageStatus")
 
+		// Inbound messages carry SmsStatus=received instead of MessageStatus,
+		// for numbers whose incoming webhook points here.
+		if messageStatus == "" && c.PostForm("SmsStatus") == "received" {
+			h.handleInbound(c)
+			return
+		}
+
 		if messageSid == "" || messageStatus == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "MessageSid and MessageStatus are required"})
 			return
@@ -276,14 +412,27 @@ func (h *WebhookHandler) twilio(c *gin.Context) {
 			c.PostForm("DateUpdated"),
 		)
 
-		fmt.Printf("Twilio webhook received: %s\n", gatewayResponse)
+		// WhatsApp adds "read" to the SMS statuses, and its failures are
+		// only actionable with the meaning of the error code.
+		channel := "sms"
+		if strings.HasPrefix(c.PostForm("To"), "whatsapp:") || c.PostForm("ChannelPrefix") == "whatsapp" {
+			channel = "whatsapp"
+			if reason, ok := whatsAppErrors[c.PostForm("ErrorCode")]; ok {
+				gatewayResponse += ", Reason=" + reason
+			}
+		}
+
+		h.logger.InfoContext(c.Request.Context(), "twilio webhook received",
+			"message_sid", messageSid, "channel", channel, "status", messageStatus, "to", c.PostForm("To"), "error_code", c.PostForm("ErrorCode"))
 
+		// Twilio status callbacks carry no event time, so use the arrival time.
 		body := genericWebhook{
 			ExternalID:      messageSid,
 			Status:          messageStatus,
 			GatewayResponse: gatewayResponse,
+			Timestamp:       time.Now().Unix(),
 		}
-		h.handleGeneric(c, body)
+		h.handleGeneric(c, "twilio", body)
 		return
 	}
 
@@ -293,5 +442,5 @@ func (h *WebhookHandler) twilio(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	h.handleGeneric(c, body)
+	h.handleGeneric(c, "twilio", body)
 }
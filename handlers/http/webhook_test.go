@@ -0,0 +1,128 @@
+package httphdl
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// twilioSignature computes X-Twilio-Signature as Twilio documents it: the
+// base64 HMAC-SHA1 of the URL followed by each parameter name and value,
+// sorted by name.
+func twilioSignature(token, fullURL string, form url.Values) string {
+	names := make([]string, 0, len(form))
+	for name := range form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(fullURL)
+	for _, name := range names {
+		b.WriteString(name + form.Get(name))
+	}
+	mac := hmac.New(sha1.New, []byte(token))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyTwilio(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	form := url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"delivered"}, "To": {"+14155550123"}}
+	tampered := url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"failed"}, "To": {"+14155550123"}}
+
+	tests := []struct {
+		name      string
+		token     string
+		baseURL   string
+		target    string
+		forwarded string
+		form      url.Values
+		signature string
+		want      int
+	}{
+		{
+			name: "signed for the base URL", token: "secret", baseURL: "https://api.example.com/",
+			target: "/webhooks/twilio", form: form,
+			signature: twilioSignature("secret", "https://api.example.com/webhooks/twilio", form),
+			want:      http.StatusOK,
+		},
+		{
+			name: "query string is covered", token: "secret", baseURL: "https://api.example.com",
+			target: "/webhooks/twilio?attempt=2", form: form,
+			signature: twilioSignature("secret", "https://api.example.com/webhooks/twilio?attempt=2", form),
+			want:      http.StatusOK,
+		},
+		{
+			name: "request URL behind a TLS proxy", token: "secret",
+			target: "/webhooks/twilio", forwarded: "https", form: form,
+			signature: twilioSignature("secret", "https://messenger.internal/webhooks/twilio", form),
+			want:      http.StatusOK,
+		},
+		{
+			name: "tampered parameter", token: "secret", baseURL: "https://api.example.com",
+			target: "/webhooks/twilio", form: tampered,
+			signature: twilioSignature("secret", "https://api.example.com/webhooks/twilio", form),
+			want:      http.StatusForbidden,
+		},
+		{
+			name: "signed for another URL", token: "secret", baseURL: "https://api.example.com",
+			target: "/webhooks/twilio", form: form,
+			signature: twilioSignature("secret", "https://attacker.example.com/webhooks/twilio", form),
+			want:      http.StatusForbidden,
+		},
+		{
+			name: "scheme not forwarded", token: "secret",
+			target: "/webhooks/twilio", form: form,
+			signature: twilioSignature("secret", "https://messenger.internal/webhooks/twilio", form),
+			want:      http.StatusForbidden,
+		},
+		{
+			name: "wrong auth token", token: "secret", baseURL: "https://api.example.com",
+			target: "/webhooks/twilio", form: form,
+			signature: twilioSignature("other", "https://api.example.com/webhooks/twilio", form),
+			want:      http.StatusForbidden,
+		},
+		{
+			name: "missing signature", token: "secret", baseURL: "https://api.example.com",
+			target: "/webhooks/twilio", form: form,
+			want: http.StatusForbidden,
+		},
+		{
+			name: "no auth token configured", baseURL: "https://api.example.com",
+			target: "/webhooks/twilio", form: form,
+			signature: twilioSignature("", "https://api.example.com/webhooks/twilio", form),
+			want:      http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewWebhookHandler(nil, nil, nil, tt.token, tt.baseURL, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			r := gin.New()
+			r.POST("/webhooks/twilio", h.verifyTwilio, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodPost, "http://messenger.internal"+tt.target, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwarded)
+			}
+			if tt.signature != "" {
+				req.Header.Set("X-Twilio-Signature", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package httphdl
+
+import (
+	"net/http"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookDeliveryHandler exposes the caller's status callback delivery log.
+type WebhookDeliveryHandler struct {
+	uc   *usecases.StatusCallbackUsecase
+	auth *Authenticator
+}
+
+func NewWebhookDeliveryHandler(uc *usecases.StatusCallbackUsecase, auth *Authenticator) *WebhookDeliveryHandler {
+	return &WebhookDeliveryHandler{uc: uc, auth: auth}
+}
+
+func (h *WebhookDeliveryHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("/", h.auth.Require(entities.APIKeyScopeRead), h.list)
+	rg.GET(":id", h.auth.Require(entities.APIKeyScopeRead), h.get)
+	rg.POST(":id/redeliver", h.auth.Require(entities.APIKeyScopeSend), h.redeliver)
+}
+
+func (h *WebhookDeliveryHandler) list(c *gin.Context) {
+	out, err := h.uc.ListDeliveries(c.Request.Context(), currentAPIKey(c).UserID, c.Query("message_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *WebhookDeliveryHandler) get(c *gin.Context) {
+	out, err := h.uc.GetDelivery(c.Request.Context(), currentAPIKey(c).UserID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *WebhookDeliveryHandler) redeliver(c *gin.Context) {
+	out, err := h.uc.Redeliver(c.Request.Context(), currentAPIKey(c).UserID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, out)
+}
@@ -0,0 +1,70 @@
+package httphdl
+
+import (
+	"net/http"
+
+	"messenger-module/entities"
+	"messenger-module/usecases"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebPushHandler exposes the VAPID public key and lets users register their
+// end users' browser push subscriptions.
+type WebPushHandler struct {
+	uc   *usecases.WebPushUsecase
+	auth *Authenticator
+}
+
+func NewWebPushHandler(uc *usecases.WebPushUsecase, auth *Authenticator) *WebPushHandler {
+	return &WebPushHandler{uc: uc, auth: auth}
+}
+
+func (h *WebPushHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("vapid-public-key", h.auth.Require(entities.APIKeyScopeRead), h.publicKey)
+	rg.GET("subscriptions", h.auth.Require(entities.APIKeyScopeRead), h.list)
+	rg.POST("subscriptions", h.auth.Require(entities.APIKeyScopeSend), h.subscribe)
+	rg.DELETE("subscriptions/:id", h.auth.Require(entities.APIKeyScopeSend), h.unsubscribe)
+}
+
+func (h *WebPushHandler) publicKey(c *gin.Context) {
+	key, err := h.uc.PublicKey(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+func (h *WebPushHandler) list(c *gin.Context) {
+	out, err := h.uc.List(c.Request.Context(), currentAPIKey(c).UserID, c.Query("subscriber"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// subscribe takes the browser's PushSubscription JSON plus the subscriber it
+// belongs to.
+func (h *WebPushHandler) subscribe(c *gin.Context) {
+	var in entities.PushSubscription
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out, err := h.uc.Subscribe(c.Request.Context(), currentAPIKey(c).UserID, in)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, out)
+}
+
+func (h *WebPushHandler) unsubscribe(c *gin.Context) {
+	if err := h.uc.Unsubscribe(c.Request.Context(), currentAPIKey(c).UserID, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,8 +1,14 @@
 package handlers
 
-import "messenger-module/entities"
+import (
+	"context"
 
+	"messenger-module/entities"
+)
+
+// MessageHandler sends messages through one provider. SendMessage must
+// return promptly once ctx is done.
 type MessageHandler interface {
-	SendMessage(input entities.Message) (string, error)
+	SendMessage(ctx context.Context, input entities.Message) (string, error)
 	ValidateMessage(input entities.Message) error
 }
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	nethttp "net/http"
+	"strings"
+)
+
+// MMS limits. Twilio accepts up to 10 media files and 5 MB per message, and
+// resizes images to suit the carrier; other types reach the handset as they
+// are, so they have to fit the smallest common carrier limit.
+const (
+	mmsMaxMedia      = 10
+	mmsMaxTotalBytes = 5 << 20
+	mmsMaxImageBytes = 5 << 20
+	mmsMaxOtherBytes = 600 << 10
+)
+
+// mmsImageTypes are the images Twilio resizes for carriers.
+var mmsImageTypes = map[string]bool{
+	"image/jpeg": true, "image/png": true, "image/gif": true,
+}
+
+// mmsOtherTypes are the other types carriers commonly deliver.
+var mmsOtherTypes = map[string]bool{
+	"audio/mpeg": true, "audio/mp4": true, "audio/amr": true, "audio/3gpp": true,
+	"video/mp4": true, "video/3gpp": true, "video/quicktime": true,
+	"text/vcard": true, "text/x-vcard": true, "application/pdf": true,
+}
+
+// CheckMMSMedia reports whether a file of the given type and size, in
+// bytes, can be sent by MMS. A negative size is not checked.
+func CheckMMSMedia(contentType string, size int64) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q", contentType)
+	}
+	limit := int64(mmsMaxOtherBytes)
+	switch {
+	case mmsImageTypes[mediaType]:
+		limit = mmsMaxImageBytes
+	case mmsOtherTypes[mediaType]:
+	default:
+		return fmt.Errorf("content type %s is not supported by MMS", mediaType)
+	}
+	if size > limit {
+		return fmt.Errorf("%s media is %d bytes, MMS allows %d", mediaType, size, limit)
+	}
+	return nil
+}
+
+// checkMediaURLs asks each URL for its type and size with a HEAD request
+// and checks them against the MMS limits. Servers that do not answer HEAD,
+// or omit a header, are given the benefit of the doubt; Twilio checks again.
+// The URLs come from API callers, so only public addresses are contacted
+// and the response status is not reported back.
+func (h *TwillioHandler) checkMediaURLs(ctx context.Context, urls []string) error {
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
+
+	client := newPublicHTTPClient(h.timeout)
+	var total int64
+	for _, u := range urls {
+		req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodHead, u, nil)
+		if err != nil {
+			return fmt.Errorf("media URL %s: %w", u, err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("media URL %s is not reachable: %w", u, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == nethttp.StatusMethodNotAllowed || resp.StatusCode == nethttp.StatusNotImplemented {
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("media URL %s could not be fetched", u)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "" {
+			if err := CheckMMSMedia(ct, resp.ContentLength); err != nil {
+				return fmt.Errorf("media URL %s: %w", u, err)
+			}
+		}
+		if resp.ContentLength > 0 {
+			total += resp.ContentLength
+		}
+	}
+	if total > mmsMaxTotalBytes {
+		return fmt.Errorf("media total %d bytes, MMS allows %d", total, mmsMaxTotalBytes)
+	}
+	return nil
+}
+
+// validateMediaURLs checks the number and form of a message's media URLs,
+// which must be public http(s) URLs.
+func validateMediaURLs(urls []string, max int) error {
+	if len(urls) > max {
+		return fmt.Errorf("at most %d media URLs are allowed", max)
+	}
+	for _, u := range urls {
+		if strings.TrimSpace(u) == "" {
+			return errors.New("media URL must not be empty")
+		}
+		if err := CheckPublicURL(u); err != nil {
+			return fmt.Errorf("media URL: %w", err)
+		}
+	}
+	return nil
+}
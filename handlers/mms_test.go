@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"messenger-module/confs"
+)
+
+func TestCheckMMSMedia(t *testing.T) {
+	tests := []struct {
+		contentType string
+		size        int64
+		ok          bool
+	}{
+		{"image/jpeg", 4 << 20, true},
+		{"image/png; name=cat.png", -1, true},
+		{"image/gif", 6 << 20, false},
+		{"video/mp4", 500 << 10, true},
+		{"video/mp4", 700 << 10, false},
+		{"application/zip", 1, false},
+		{"not a type", 1, false},
+	}
+	for _, tt := range tests {
+		if err := CheckMMSMedia(tt.contentType, tt.size); (err == nil) != tt.ok {
+			t.Errorf("CheckMMSMedia(%q, %d) = %v, want ok=%v", tt.contentType, tt.size, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateMediaURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		ok   bool
+	}{
+		{"public", []string{"https://cdn.example.com/cat.png", "http://example.com/a.pdf"}, true},
+		{"none", nil, true},
+		{"too many", []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}, false},
+		{"empty", []string{" "}, false},
+		{"relative", []string{"/media/cat.png"}, false},
+		{"file scheme", []string{"file:///etc/passwd"}, false},
+		{"localhost", []string{"http://localhost:8080/admin"}, false},
+		{"loopback", []string{"http://127.0.0.1/cat.png"}, false},
+		{"private", []string{"http://10.1.2.3/cat.png"}, false},
+		{"metadata service", []string{"http://169.254.169.254/latest/meta-data"}, false},
+	}
+	for _, tt := range tests {
+		if err := validateMediaURLs(tt.urls, 2); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckMediaURLsRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("HEAD request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	h := NewTwillioHandler(confs.TwilioConfig{Timeout: confs.Duration(time.Second)}, "test", "")
+	err := h.checkMediaURLs(context.Background(), []string{srv.URL + "/cat.png"})
+	if !errors.Is(err, ErrInternalAddress) {
+		t.Errorf("err = %v, want ErrInternalAddress", err)
+	}
+}
@@ -1,42 +1,189 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	nethttp "net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
+	"messenger-module/confs"
 	"messenger-module/entities"
 )
 
-type NtfyHandler struct{}
+// ntfyMaxActions is the most action buttons ntfy accepts per notification.
+const ntfyMaxActions = 3
 
-func NewNtfyHandler() *NtfyHandler { return &NtfyHandler{} }
+var ntfyTopic = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+
+type NtfyHandler struct {
+	client  *nethttp.Client
+	baseURL string
+	token   string
+	timeout time.Duration
+}
+
+func NewNtfyHandler(cfg confs.NtfyConfig) *NtfyHandler {
+	return &NtfyHandler{
+		client:  newHTTPClient(time.Duration(cfg.Timeout)),
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		token:   cfg.Token,
+		timeout: time.Duration(cfg.Timeout),
+	}
+}
+
+// withIntegration returns a handler publishing to the integration's own
+// server and token, where set.
+func (h *NtfyHandler) withIntegration(integration entities.Integration) *NtfyHandler {
+	out := *h
+	if integration.BaseURL != "" {
+		out.baseURL = strings.TrimRight(integration.BaseURL, "/")
+		// Never send the default server's token to another server.
+		out.token = ""
+	}
+	if integration.Token != "" {
+		out.token = integration.Token
+	}
+	return &out
+}
 
 func (h *NtfyHandler) ValidateMessage(input entities.Message) error {
 	if input.Destination == "" {
 		return errors.New("destination is required")
 	}
+	if !ntfyTopic.MatchString(input.Destination) {
+		return errors.New("invalid ntfy topic: use up to 64 letters, digits, - or _")
+	}
 	if input.Content == "" {
 		return errors.New("content is required")
 	}
+	if opts := input.Ntfy; opts != nil {
+		if opts.Priority < 0 || opts.Priority > 5 {
+			return fmt.Errorf("ntfy priority must be between 1 and 5, got %d", opts.Priority)
+		}
+		for _, field := range []struct{ name, value string }{
+			{"click", opts.Click}, {"attach", opts.Attach}, {"icon", opts.Icon},
+		} {
+			if err := checkHTTPURL(field.value); err != nil {
+				return fmt.Errorf("ntfy %s: %w", field.name, err)
+			}
+		}
+		if len(opts.Actions) > ntfyMaxActions {
+			return fmt.Errorf("ntfy allows at most %d actions", ntfyMaxActions)
+		}
+		for i, a := range opts.Actions {
+			if a.Label == "" {
+				return fmt.Errorf("ntfy action %d: label is required", i)
+			}
+			switch a.Action {
+			case "view", "http":
+				if a.URL == "" {
+					return fmt.Errorf("ntfy action %d: url is required", i)
+				}
+				if err := checkHTTPURL(a.URL); err != nil {
+					return fmt.Errorf("ntfy action %d: %w", i, err)
+				}
+			case "broadcast":
+			default:
+				return fmt.Errorf("ntfy action %d: action must be view, http or broadcast", i)
+			}
+		}
+	}
+	return nil
+}
+
+func checkHTTPURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
 	return nil
 }
 
-func (h *NtfyHandler) SendMessage(input entities.Message) (string, error) {
+// ntfyPublish is ntfy's JSON publish request.
+type ntfyPublish struct {
+	Topic    string                `json:"topic"`
+	Message  string                `json:"message"`
+	Title    string                `json:"title,omitempty"`
+	Priority int                   `json:"priority,omitempty"`
+	Tags     []string              `json:"tags,omitempty"`
+	Click    string                `json:"click,omitempty"`
+	Attach   string                `json:"attach,omitempty"`
+	Filename string                `json:"filename,omitempty"`
+	Icon     string                `json:"icon,omitempty"`
+	Actions  []entities.NtfyAction `json:"actions,omitempty"`
+}
+
+// ntfyResponse covers both the published message and ntfy's error body.
+type ntfyResponse struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// SendMessage publishes input and returns the ntfy message ID.
+func (h *NtfyHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
 	input.Type = "ntfy"
 
 	if err := h.ValidateMessage(input); err != nil {
 		return "", err
 	}
-	httpClient := &nethttp.Client{}
-	req, err := nethttp.NewRequest("POST", "https://ntfy.sh/"+input.Destination, strings.NewReader(input.Content))
+
+	pub := ntfyPublish{Topic: input.Destination, Message: input.Content, Title: input.Subject}
+	if opts := input.Ntfy; opts != nil {
+		pub.Priority = opts.Priority
+		pub.Tags = opts.Tags
+		pub.Click = opts.Click
+		pub.Attach = opts.Attach
+		pub.Filename = opts.Filename
+		pub.Icon = opts.Icon
+		pub.Actions = opts.Actions
+	}
+	body, err := json.Marshal(pub)
 	if err != nil {
 		return "", err
 	}
-	resp, err := httpClient.Do(req)
+
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// JSON publishing goes to the server root; the topic is in the body.
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, h.baseURL+"/", bytes.NewReader(body))
 	if err != nil {
 		return "", err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to publish to ntfy: %w", err)
+	}
 	defer resp.Body.Close()
-	return "", nil
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("failed to read ntfy response: %w", err)
+	}
+	var out ntfyResponse
+	_ = json.Unmarshal(raw, &out)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if out.Error != "" {
+			return "", fmt.Errorf("ntfy error: status=%d: %s", resp.StatusCode, out.Error)
+		}
+		return "", fmt.Errorf("ntfy error: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if out.ID == "" {
+		return "", errors.New("no message ID in ntfy response")
+	}
+	return out.ID, nil
 }
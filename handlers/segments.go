@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SMS encodings.
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+)
+
+// Segment sizes: a single SMS carries 160 GSM-7 septets or 70 UCS-2 code
+// units; each part of a concatenated SMS loses room to its header.
+const (
+	gsm7Single  = 160
+	gsm7Multi   = 153
+	ucs2Single  = 70
+	ucs2Multi   = 67
+	smsMaxChars = 1600
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, less the escape character.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension characters are sent as an escape plus a septet, so they
+// count twice.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+var gsm7Septets = func() map[rune]int {
+	m := make(map[rune]int, utf8.RuneCountInString(gsm7Basic)+utf8.RuneCountInString(gsm7Extension))
+	for _, r := range gsm7Basic {
+		m[r] = 1
+	}
+	for _, r := range gsm7Extension {
+		m[r] = 2
+	}
+	return m
+}()
+
+// smartEncoder replaces Unicode look-alikes with GSM-7 characters, so text
+// pasted from word processors does not fall back to UCS-2.
+var smartEncoder = strings.NewReplacer(
+	// Quotes and primes.
+	"\u2018", "'", "\u2019", "'", "\u201A", "'", "\u201B", "'", "\u2032", "'",
+	"\u201C", `"`, "\u201D", `"`, "\u201E", `"`, "\u201F", `"`, "\u2033", `"`,
+	"\u00AB", `"`, "\u00BB", `"`,
+	// Hyphens, dashes and minus.
+	"\u2010", "-", "\u2011", "-", "\u2012", "-", "\u2013", "-", "\u2014", "-",
+	"\u2015", "-", "\u2212", "-",
+	"\u2026", "...", "\u2022", "-", "\u02C6", "^", "\u02DC", "~",
+	// Non-breaking and other wide or narrow spaces.
+	"\u00A0", " ", "\u2000", " ", "\u2001", " ", "\u2002", " ", "\u2003", " ",
+	"\u2004", " ", "\u2005", " ", "\u2006", " ", "\u2007", " ", "\u2008", " ",
+	"\u2009", " ", "\u200A", " ", "\u202F", " ", "\u205F", " ", "\u3000", " ",
+	// Zero-width characters.
+	"\u200B", "", "\u200C", "", "\u200D", "", "\u2060", "", "\uFEFF", "",
+)
+
+// SmartEncode replaces Unicode punctuation and spaces with their GSM-7
+// look-alikes. Other characters are left alone.
+func SmartEncode(s string) string {
+	return smartEncoder.Replace(s)
+}
+
+// SMSSegments describes how a text is sent as SMS. Units is its length in
+// UTF-16 code units, which is what carrier and Twilio length limits count:
+// a character outside the Basic Multilingual Plane, such as most emoji,
+// counts twice.
+type SMSSegments struct {
+	Encoding   string `json:"encoding"`
+	Segments   int    `json:"segments"`
+	Characters int    `json:"characters"`
+	Units      int    `json:"units"`
+}
+
+// CountSegments picks the encoding for s and counts the SMS segments it
+// takes. Characters are never split across segments: a GSM-7 escape pair
+// or a UCS-2 surrogate pair that does not fit moves to the next segment.
+func CountSegments(s string) SMSSegments {
+	out := SMSSegments{Encoding: EncodingGSM7, Characters: utf8.RuneCountInString(s)}
+	if s == "" {
+		return out
+	}
+	for _, r := range s {
+		out.Units += utf16Units(r)
+		if gsm7Septets[r] == 0 {
+			out.Encoding = EncodingUCS2
+		}
+	}
+
+	width := func(r rune) int { return gsm7Septets[r] }
+	single, multi := gsm7Single, gsm7Multi
+	if out.Encoding == EncodingUCS2 {
+		width = func(r rune) int { return utf16Units(r) }
+		single, multi = ucs2Single, ucs2Multi
+	}
+
+	total := 0
+	for _, r := range s {
+		total += width(r)
+	}
+	if total <= single {
+		out.Segments = 1
+		return out
+	}
+	used := 0
+	out.Segments = 1
+	for _, r := range s {
+		w := width(r)
+		if used+w > multi {
+			out.Segments++
+			used = 0
+		}
+		used += w
+	}
+	return out
+}
+
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
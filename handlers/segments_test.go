@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+func TestCountSegments(t *testing.T) {
+	a, zh, emoji := "a", "ж", "😀"
+	rep := strings.Repeat
+	tests := []struct {
+		name     string
+		in       string
+		encoding string
+		segments int
+		chars    int
+		units    int
+	}{
+		{"empty", "", EncodingGSM7, 0, 0, 0},
+
+		// GSM-7: 160 septets in one segment, 153 per part after that.
+		{"gsm7 160", rep(a, 160), EncodingGSM7, 1, 160, 160},
+		{"gsm7 161", rep(a, 161), EncodingGSM7, 2, 161, 161},
+		{"gsm7 306", rep(a, 306), EncodingGSM7, 2, 306, 306},
+		{"gsm7 307", rep(a, 307), EncodingGSM7, 3, 307, 307},
+		{"gsm7 escape fills 160", rep(a, 158) + "€", EncodingGSM7, 1, 159, 159},
+		{"gsm7 escape spills past 160", rep(a, 159) + "€", EncodingGSM7, 2, 160, 160},
+		// An escape pair is never split: at septet 153 the € moves to the
+		// second part, which then overflows into a third.
+		{"gsm7 escape at 153 boundary", rep(a, 152) + "€" + rep(a, 152), EncodingGSM7, 3, 305, 305},
+		{"gsm7 escape just before 153 boundary", rep(a, 151) + "€" + rep(a, 153), EncodingGSM7, 2, 305, 305},
+		{"gsm7 extension set", "^{}\\[~]|€\f", EncodingGSM7, 1, 10, 10},
+		{"gsm7 basic accents", "èéùìòÇØøÅåÆæßÉÄÖÑÜäöñüà", EncodingGSM7, 1, 23, 23},
+
+		// UCS-2: 70 code units in one segment, 67 per part after that.
+		{"ucs2 70", rep(zh, 70), EncodingUCS2, 1, 70, 70},
+		{"ucs2 71", rep(zh, 71), EncodingUCS2, 2, 71, 71},
+		{"ucs2 134", rep(zh, 134), EncodingUCS2, 2, 134, 134},
+		{"ucs2 135", rep(zh, 135), EncodingUCS2, 3, 135, 135},
+		{"one non-gsm character switches encoding", rep(a, 69) + zh, EncodingUCS2, 1, 70, 70},
+		{"ucs2 from gsm7-sized text", rep(a, 100) + zh, EncodingUCS2, 2, 101, 101},
+
+		// Surrogate pairs take two code units and are never split.
+		{"surrogates fill 70", rep(emoji, 35), EncodingUCS2, 1, 35, 70},
+		{"surrogates spill past 70", rep(emoji, 35) + a, EncodingUCS2, 2, 36, 71},
+		{"surrogate at 67 boundary", rep(zh, 66) + emoji + rep(zh, 66), EncodingUCS2, 3, 133, 134},
+		{"surrogate just before 67 boundary", rep(zh, 65) + emoji + rep(zh, 67), EncodingUCS2, 2, 133, 134},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CountSegments(tt.in)
+			want := SMSSegments{Encoding: tt.encoding, Segments: tt.segments, Characters: tt.chars, Units: tt.units}
+			if got != want {
+				t.Errorf("CountSegments = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSmartEncode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"“quoted” and ‘single’", `"quoted" and 'single'`},
+		{"«guillemets»", `"guillemets"`},
+		{"en–dash em—dash minus−", "en-dash em-dash minus-"},
+		{"wait…", "wait..."},
+		{"non\u00a0breaking\u2009thin\u3000wide", "non breaking thin wide"},
+		{"zero\u200bwidth\u200d\ufeff", "zerowidth"},
+		{"• bullet", "- bullet"},
+		{"кириллица stays", "кириллица stays"},
+		{"emoji 😀 stays", "emoji 😀 stays"},
+	}
+	for _, tt := range tests {
+		if got := SmartEncode(tt.in); got != tt.want {
+			t.Errorf("SmartEncode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	// Look-alikes alone should no longer force UCS-2.
+	in := "It’s “done” — ship it…"
+	if enc := CountSegments(in).Encoding; enc != EncodingUCS2 {
+		t.Fatalf("raw encoding = %s, want %s", enc, EncodingUCS2)
+	}
+	if enc := CountSegments(SmartEncode(in)).Encoding; enc != EncodingGSM7 {
+		t.Errorf("smart-encoded encoding = %s, want %s", enc, EncodingGSM7)
+	}
+}
+
+func TestTwilioLengthLimitCountsCodeUnits(t *testing.T) {
+	h := NewTwillioHandler(confs.TwilioConfig{PhoneNumber: "+14155550100"}, "test", "")
+	tests := []struct {
+		name    string
+		content string
+		ok      bool
+	}{
+		{"1600 gsm7", strings.Repeat("a", smsMaxChars), true},
+		{"1601 gsm7", strings.Repeat("a", smsMaxChars+1), false},
+		{"800 emoji are 1600 units", strings.Repeat("😀", smsMaxChars/2), true},
+		{"801 emoji are 1602 units", strings.Repeat("😀", smsMaxChars/2+1), false},
+	}
+	for _, tt := range tests {
+		err := h.ValidateMessage(entities.Message{Destination: "+14155550123", Content: tt.content})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -1,59 +1,114 @@
 package handlers
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"os"
+	netmail "net/mail"
 	"strings"
+	"time"
 
+	"messenger-module/confs"
 	"messenger-module/entities"
 
+	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
 
 type SendGridHandler struct {
-	client    *sendgrid.Client
+	client    *rest.Client
+	apiKey    string
+	timeout   time.Duration
 	fromName  string
 	fromEmail string
 }
 
-func NewSendGridHandler() (*SendGridHandler, error) {
-	apiKey := os.Getenv("SENDGRID_API_KEY")
-	if apiKey == "" {
+func NewSendGridHandler(cfg confs.SendGridConfig) (*SendGridHandler, error) {
+	if cfg.APIKey == "" {
 		return nil, errors.New("SENDGRID_API_KEY is required")
 	}
-
-	fromName := os.Getenv("SENDGRID_FROM_NAME")
-	fromEmail := os.Getenv("SENDGRID_FROM_EMAIL")
-	if fromEmail == "" {
+	if cfg.FromEmail == "" {
 		return nil, errors.New("SENDGRID_FROM_EMAIL is required")
 	}
 
-	client := sendgrid.NewSendClient(apiKey)
-
 	return &SendGridHandler{
-		client:    client,
-		fromName:  fromName,
-		fromEmail: fromEmail,
+		client:    &rest.Client{HTTPClient: newHTTPClient(time.Duration(cfg.Timeout))},
+		apiKey:    cfg.APIKey,
+		timeout:   time.Duration(cfg.Timeout),
+		fromName:  cfg.FromName,
+		fromEmail: cfg.FromEmail,
 	}, nil
 }
 
-func (h *SendGridHandler) SendMessage(input entities.Message) (string, error) {
+func (h *SendGridHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
 	input.Type = "email"
 
 	if err := h.ValidateMessage(input); err != nil {
 		return "", err
 	}
 
-	from := mail.NewEmail(h.fromName, h.fromEmail)
-	to := mail.NewEmail("", input.Destination)
-	message := mail.NewSingleEmail(from, "Message from API", to, input.Content, input.Content)
+	extras, err := parseEmailExtras(input)
+	if err != nil {
+		return "", err
+	}
+
+	subject := input.Subject
+	if subject == "" {
+		subject = "Message from API"
+	}
+	message := mail.NewV3Mail()
+	message.SetFrom(mail.NewEmail(h.fromName, h.fromEmail))
+	message.Subject = subject
+
+	p := mail.NewPersonalization()
+	to, _ := netmail.ParseAddress(input.Destination)
+	p.AddTos(sendgridEmail(to))
+	for _, a := range extras.cc {
+		p.AddCCs(sendgridEmail(a))
+	}
+	for _, a := range extras.bcc {
+		p.AddBCCs(sendgridEmail(a))
+	}
+	message.AddPersonalizations(p)
+	if extras.replyTo != nil {
+		message.SetReplyTo(sendgridEmail(extras.replyTo))
+	}
+
+	// SendGrid requires text/plain before text/html
+	message.AddContent(mail.NewContent("text/plain", input.Content))
+	if input.HTMLContent != "" {
+		message.AddContent(mail.NewContent("text/html", input.HTMLContent))
+	}
 
+	for name, value := range extras.headers {
+		message.SetHeader(name, value)
+	}
 	// Set unique message ID for tracking
 	message.SetHeader("X-Message-ID", fmt.Sprintf("msg_%s", strings.Replace(input.ID, "-", "", -1)))
 
-	response, err := h.client.Send(message)
+	for _, a := range extras.attachments {
+		att := mail.NewAttachment().
+			SetFilename(a.filename).
+			SetType(a.contentType).
+			SetDisposition(a.disposition).
+			SetContent(base64.StdEncoding.EncodeToString(a.data))
+		if a.contentID != "" {
+			att.SetContentID(a.contentID)
+		}
+		message.AddAttachment(att)
+	}
+
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// A fresh request per send; sendgrid.Client mutates its Body and is not
+	// safe for concurrent use.
+	request := sendgrid.GetRequest(h.apiKey, "/v3/mail/send", "")
+	request.Method = rest.Post
+	request.Body = mail.GetRequestBody(message)
+	response, err := h.client.SendWithContext(ctx, request)
 	if err != nil {
 		return "", fmt.Errorf("failed to send email: %w", err)
 	}
@@ -76,7 +131,7 @@ func (h *SendGridHandler) ValidateMessage(input entities.Message) error {
 		return errors.New("destination (email) is required")
 	}
 
-	if !strings.Contains(input.Destination, "@") {
+	if _, err := netmail.ParseAddress(input.Destination); err != nil {
 		return errors.New("invalid email format")
 	}
 
@@ -84,5 +139,10 @@ func (h *SendGridHandler) ValidateMessage(input entities.Message) error {
 		return errors.New("content is required")
 	}
 
-	return nil
+	_, err := parseEmailExtras(input)
+	return err
+}
+
+func sendgridEmail(a *netmail.Address) *mail.Email {
+	return mail.NewEmail(a.Name, a.Address)
 }
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+
+	"github.com/google/uuid"
+)
+
+// Slack Block Kit limits, in characters.
+const (
+	slackMaxHeader  = 150
+	slackMaxSection = 3000
+	slackMaxText    = 40000
+)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackHandler posts messages to Slack incoming-webhook URLs. The
+// destination is the webhook URL, which already names the channel.
+type SlackHandler struct {
+	poster chatPoster
+}
+
+func NewSlackHandler(cfg confs.ChatConfig) *SlackHandler {
+	return &SlackHandler{poster: newChatPoster("slack", slackWebhookHosts, cfg)}
+}
+
+func (h *SlackHandler) ValidateMessage(input entities.Message) error {
+	if input.Destination == "" {
+		return errors.New("destination (Slack webhook URL) is required")
+	}
+	if err := h.poster.checkURL(input.Destination); err != nil {
+		return fmt.Errorf("invalid Slack webhook URL: %w", err)
+	}
+	if input.Content == "" {
+		return errors.New("content is required")
+	}
+	if n := utf8.RuneCountInString(input.Content); n > slackMaxText {
+		return fmt.Errorf("content is %d characters, Slack allows %d", n, slackMaxText)
+	}
+	return nil
+}
+
+type slackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackPayload struct {
+	// Text is the notification and fallback text; Blocks are what is shown.
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackMessage renders the subject as a header block and the content as
+// mrkdwn sections. Slack's control characters are escaped, so content is
+// shown as written apart from *bold*, _italic_ and similar formatting.
+func slackMessage(input entities.Message) slackPayload {
+	var out slackPayload
+	content := slackEscaper.Replace(input.Content)
+	if input.Subject != "" {
+		out.Text = input.Subject
+		out.Blocks = append(out.Blocks, slackBlock{
+			Type: "header",
+			Text: &slackText{Type: "plain_text", Text: truncateRunes(input.Subject, slackMaxHeader), Emoji: true},
+		})
+	} else {
+		out.Text = truncateRunes(content, slackMaxSection)
+	}
+	for _, part := range splitRunes(content, slackMaxSection) {
+		out.Blocks = append(out.Blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: part}})
+	}
+	return out
+}
+
+// splitRunes cuts s into chunks of at most n runes, preferring to break
+// after a newline.
+func splitRunes(s string, n int) []string {
+	var parts []string
+	r := []rune(s)
+	for len(r) > n {
+		cut := n
+		for i := n - 1; i > n/2; i-- {
+			if r[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		parts = append(parts, string(r[:cut]))
+		r = r[cut:]
+	}
+	return append(parts, string(r))
+}
+
+// SendMessage posts input to the webhook URL. Slack returns no message ID,
+// so the returned ID is generated here.
+func (h *SlackHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = "slack"
+
+	if err := h.ValidateMessage(input); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(slackMessage(input))
+	if err != nil {
+		return "", err
+	}
+
+	status, resp, err := h.poster.post(ctx, input.Destination, body)
+	if err != nil {
+		return "", err
+	}
+	// Slack answers "ok", or an error code such as invalid_payload,
+	// channel_not_found or channel_is_archived.
+	if status < 200 || status > 299 {
+		return "", fmt.Errorf("slack error: status=%d: %s", status, resp)
+	}
+	return uuid.NewString(), nil
+}
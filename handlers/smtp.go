@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+// smtpMaxIdle is how long a pooled connection may sit unused before it is
+// discarded; servers commonly drop idle clients after a minute or so.
+const smtpMaxIdle = 30 * time.Second
+
+// SMTPHandler delivers email through an SMTP server, reusing a small pool of
+// authenticated connections.
+type SMTPHandler struct {
+	addr     string
+	host     string
+	tlsMode  string
+	username string
+	password string
+	auth     string
+	from     mail.Address
+	timeout  time.Duration
+	idle     chan *smtpConn
+	// rootCAs verifies the server certificate; nil uses the system roots.
+	rootCAs *x509.CertPool
+}
+
+type smtpConn struct {
+	client    *smtp.Client
+	conn      net.Conn
+	idleSince time.Time
+}
+
+func NewSMTPHandler(cfg confs.SMTPConfig) (*SMTPHandler, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SMTP_HOST is required")
+	}
+	if cfg.FromEmail == "" {
+		return nil, errors.New("SMTP_FROM_EMAIL is required")
+	}
+	poolSize := cfg.PoolSize
+	if poolSize < 0 {
+		poolSize = 0
+	}
+	return &SMTPHandler{
+		addr:     net.JoinHostPort(cfg.Host, cfg.Port),
+		host:     cfg.Host,
+		tlsMode:  cfg.TLS,
+		username: cfg.Username,
+		password: cfg.Password,
+		auth:     cfg.Auth,
+		from:     mail.Address{Name: cfg.FromName, Address: cfg.FromEmail},
+		timeout:  time.Duration(cfg.Timeout),
+		idle:     make(chan *smtpConn, poolSize),
+	}, nil
+}
+
+func (h *SMTPHandler) ValidateMessage(input entities.Message) error {
+	if input.Destination == "" {
+		return errors.New("destination (email) is required")
+	}
+	if _, err := mail.ParseAddress(input.Destination); err != nil {
+		return fmt.Errorf("invalid email address: %w", err)
+	}
+	if input.Content == "" {
+		return errors.New("content is required")
+	}
+	_, err := parseEmailExtras(input)
+	return err
+}
+
+// SendMessage delivers input and returns the Message-ID it was sent with.
+func (h *SMTPHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = "email"
+
+	if err := h.ValidateMessage(input); err != nil {
+		return "", err
+	}
+	to, _ := mail.ParseAddress(input.Destination)
+	extras, err := parseEmailExtras(input)
+	if err != nil {
+		return "", err
+	}
+
+	subject := input.Subject
+	if subject == "" {
+		subject = "Message from API"
+	}
+	messageID := newMessageID(h.from.Address)
+	htmlBody := input.HTMLContent
+	if htmlBody == "" {
+		htmlBody = textToHTML(input.Content)
+	}
+	raw, err := buildMIME(&h.from, to, subject, messageID, input.Content, htmlBody, extras, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
+
+	sc, err := h.get(ctx)
+	if err != nil {
+		return "", fmt.Errorf("smtp connect: %w", err)
+	}
+	// Cancellation unblocks any read or write in progress.
+	stop := context.AfterFunc(ctx, func() { sc.conn.SetDeadline(time.Unix(1, 0)) })
+	recipients := []string{to.Address}
+	for _, a := range slices.Concat(extras.cc, extras.bcc) {
+		recipients = append(recipients, a.Address)
+	}
+	err = h.deliver(sc.client, recipients, raw)
+	if !stop() {
+		// The connection's deadline was forced into the past; never reuse it.
+		if err == nil {
+			err = ctx.Err()
+		} else {
+			err = fmt.Errorf("%w (%v)", ctx.Err(), err)
+		}
+	}
+	if err != nil {
+		sc.client.Close()
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
+	h.put(sc)
+
+	return strings.Trim(messageID, "<>"), nil
+}
+
+func (h *SMTPHandler) deliver(c *smtp.Client, recipients []string, raw []byte) error {
+	if err := c.Mail(h.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// get returns a pooled connection that still answers, or dials a new one.
+func (h *SMTPHandler) get(ctx context.Context) (*smtpConn, error) {
+	deadline, _ := ctx.Deadline()
+	for {
+		select {
+		case sc := <-h.idle:
+			if time.Since(sc.idleSince) > smtpMaxIdle {
+				sc.client.Close()
+				continue
+			}
+			sc.conn.SetDeadline(deadline)
+			if err := sc.client.Reset(); err != nil {
+				sc.client.Close()
+				continue
+			}
+			return sc, nil
+		default:
+			return h.dial(ctx, deadline)
+		}
+	}
+}
+
+// put returns a healthy connection to the pool, closing it if the pool is full.
+func (h *SMTPHandler) put(sc *smtpConn) {
+	sc.conn.SetDeadline(time.Time{})
+	sc.idleSince = time.Now()
+	select {
+	case h.idle <- sc:
+	default:
+		sc.client.Quit()
+	}
+}
+
+func (h *SMTPHandler) dial(ctx context.Context, deadline time.Time) (*smtpConn, error) {
+	tlsConfig := &tls.Config{ServerName: h.host, RootCAs: h.rootCAs, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+	var err error
+	if h.tlsMode == "implicit" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", h.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", h.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, h.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := h.handshake(c, tlsConfig); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return &smtpConn{client: c, conn: conn}, nil
+}
+
+func (h *SMTPHandler) handshake(c *smtp.Client, tlsConfig *tls.Config) error {
+	if h.tlsMode == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if h.username == "" {
+		return nil
+	}
+
+	mechanism := h.auth
+	if mechanism == "" {
+		_, offered := c.Extension("AUTH")
+		mechs := strings.Fields(strings.ToUpper(offered))
+		switch {
+		case slices.Contains(mechs, "PLAIN"):
+			mechanism = "plain"
+		case slices.Contains(mechs, "LOGIN"):
+			mechanism = "login"
+		default:
+			return fmt.Errorf("server offers no supported AUTH mechanism (%q)", offered)
+		}
+	}
+	var a smtp.Auth
+	if mechanism == "login" {
+		a = &loginAuth{username: h.username, password: h.password, host: h.host}
+	} else {
+		a = smtp.PlainAuth("", h.username, h.password, h.host)
+	}
+	return c.Auth(a)
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks but some
+// servers still require. Like smtp.PlainAuth it refuses to send credentials
+// unencrypted except to localhost.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// newMessageID returns a globally unique Message-ID in the sender's domain.
+func newMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// textToHTML renders plain text as a minimal HTML body.
+func textToHTML(text string) string {
+	return "<html><body><p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n") + "</p></body></html>"
+}
+
+// buildMIME renders the message: a multipart/alternative of quoted-printable
+// text and HTML, wrapped in multipart/mixed when there are attachments.
+// Header values are encoded so they cannot inject further headers.
+func buildMIME(from, to *mail.Address, subject, messageID, text, htmlBody string, extras emailExtras, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	if len(extras.cc) > 0 {
+		fmt.Fprintf(&buf, "Cc: %s\r\n", joinAddresses(extras.cc))
+	}
+	if extras.replyTo != nil {
+		fmt.Fprintf(&buf, "Reply-To: %s\r\n", extras.replyTo.String())
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID)
+	names := make([]string, 0, len(extras.headers))
+	for name := range extras.headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, mime.QEncoding.Encode("utf-8", extras.headers[name]))
+	}
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+
+	if len(extras.attachments) == 0 {
+		alt := multipart.NewWriter(&buf)
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n\r\n", multipartType("alternative", alt))
+		if err := writeAlternative(alt, text, htmlBody); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n\r\n", multipartType("mixed", mixed))
+	var altBuf bytes.Buffer
+	alt := multipart.NewWriter(&altBuf)
+	if err := writeAlternative(alt, text, htmlBody); err != nil {
+		return nil, err
+	}
+	pw, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {multipartType("alternative", alt)}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pw.Write(altBuf.Bytes()); err != nil {
+		return nil, err
+	}
+	for _, a := range extras.attachments {
+		header := textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.contentType, map[string]string{"name": a.filename})},
+			"Content-Disposition":       {mime.FormatMediaType(a.disposition, map[string]string{"filename": a.filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		}
+		if a.contentID != "" {
+			header.Set("Content-ID", "<"+a.contentID+">")
+		}
+		pw, err := mixed.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(pw, a.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func multipartType(subtype string, w *multipart.Writer) string {
+	return mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": w.Boundary()})
+}
+
+func writeAlternative(w *multipart.Writer, text, htmlBody string) error {
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// writeBase64Lines writes data base64 encoded in 76-character lines, as MIME
+// requires.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+func joinAddresses(list []*mail.Address) string {
+	out := make([]string, len(list))
+	for i, a := range list {
+		out[i] = a.String()
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+// smtpServer is a minimal in-process SMTP server that records what the
+// client did. It speaks just enough of RFC 5321 for SMTPHandler.
+type smtpServer struct {
+	ln       net.Listener
+	cert     tls.Certificate
+	implicit bool     // wrap every connection in TLS from the first byte
+	starttls bool     // offer STARTTLS
+	authMech []string // AUTH mechanisms to offer
+
+	mu       sync.Mutex
+	conns    int
+	resets   int
+	messages []smtpMessage
+	auths    []smtpAuth
+}
+
+type smtpMessage struct {
+	from string
+	rcpt []string
+	data string
+	tls  bool
+}
+
+type smtpAuth struct {
+	mech, username, password string
+	tls                      bool
+}
+
+// testCertificate returns the httptest certificate, valid for 127.0.0.1,
+// and a pool that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv.TLS.Certificates[0], pool
+}
+
+func newSMTPServer(t *testing.T, s *smtpServer) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) handler(t *testing.T, cfg confs.SMTPConfig, roots *x509.CertPool) *SMTPHandler {
+	t.Helper()
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	cfg.Host, cfg.Port = "127.0.0.1", port
+	if cfg.FromEmail == "" {
+		cfg.FromEmail = "noreply@example.com"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = confs.Duration(5 * time.Second)
+	}
+	h, err := NewSMTPHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.rootCAs = roots
+	return h
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	secure := false
+	if s.implicit {
+		conn = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{s.cert}})
+		secure = true
+	}
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...any) { tp.PrintfLine(format, args...) }
+	reply("220 localhost ESMTP test")
+
+	var msg smtpMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"localhost"}
+			if s.starttls && !secure {
+				lines = append(lines, "STARTTLS")
+			}
+			if len(s.authMech) > 0 {
+				lines = append(lines, "AUTH "+strings.Join(s.authMech, " "))
+			}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				reply("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			conn = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{s.cert}})
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			a := smtpAuth{mech: strings.ToUpper(mech), tls: secure}
+			switch a.mech {
+			case "PLAIN":
+				raw, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(raw), "\x00")
+				if len(parts) == 3 {
+					a.username, a.password = parts[1], parts[2]
+				}
+			case "LOGIN":
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				a.username = s.readBase64(tp)
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				a.password = s.readBase64(tp)
+			default:
+				reply("504 unrecognised mechanism")
+				continue
+			}
+			s.mu.Lock()
+			s.auths = append(s.auths, a)
+			s.mu.Unlock()
+			reply("235 authenticated")
+		case "MAIL":
+			msg = smtpMessage{from: addrArg(arg), tls: secure}
+			reply("250 ok")
+		case "RCPT":
+			msg.rcpt = append(msg.rcpt, addrArg(arg))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "RSET":
+			s.mu.Lock()
+			s.resets++
+			s.mu.Unlock()
+			msg = smtpMessage{}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) readBase64(tp *textproto.Conn) string {
+	line, _ := tp.ReadLine()
+	b, _ := base64.StdEncoding.DecodeString(line)
+	return string(b)
+}
+
+// addrArg extracts the address from "FROM:<a@b>" or "TO:<a@b>".
+func addrArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(addr, " ")
+	return strings.Trim(addr, "<>")
+}
+
+func (s *smtpServer) snapshot() (conns, resets int, msgs []smtpMessage, auths []smtpAuth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, s.resets, append([]smtpMessage(nil), s.messages...), append([]smtpAuth(nil), s.auths...)
+}
+
+func testEmail(to string) entities.Message {
+	return entities.Message{Destination: to, Subject: "Hello", Content: "Hi there"}
+}
+
+func TestSMTPPoolReusesConnection(t *testing.T) {
+	srv := newSMTPServer(t, &smtpServer{})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "none", PoolSize: 1}, nil)
+
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if _, err := h.SendMessage(context.Background(), testEmail(to)); err != nil {
+			t.Fatalf("send to %s: %v", to, err)
+		}
+	}
+	conns, resets, msgs, _ := srv.snapshot()
+	if conns != 1 {
+		t.Errorf("connections = %d, want 1", conns)
+	}
+	if resets != 1 {
+		t.Errorf("RSETs = %d, want 1 before reusing the connection", resets)
+	}
+	if len(msgs) != 2 || msgs[0].from != "noreply@example.com" || msgs[0].rcpt[0] != "a@example.com" || msgs[1].rcpt[0] != "b@example.com" {
+		t.Errorf("messages = %+v", msgs)
+	}
+}
+
+func TestSMTPPoolDropsStaleConnection(t *testing.T) {
+	srv := newSMTPServer(t, &smtpServer{})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "none", PoolSize: 1}, nil)
+
+	if _, err := h.SendMessage(context.Background(), testEmail("a@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	sc := <-h.idle
+	sc.idleSince = time.Now().Add(-2 * smtpMaxIdle)
+	h.idle <- sc
+	if _, err := h.SendMessage(context.Background(), testEmail("b@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if conns, _, _, _ := srv.snapshot(); conns != 2 {
+		t.Errorf("connections = %d, want a fresh dial for the stale one", conns)
+	}
+}
+
+func TestSMTPWithoutPoolClosesConnection(t *testing.T) {
+	srv := newSMTPServer(t, &smtpServer{})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "none"}, nil)
+
+	for range 2 {
+		if _, err := h.SendMessage(context.Background(), testEmail("a@example.com")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conns, _, _, _ := srv.snapshot(); conns != 2 {
+		t.Errorf("connections = %d, want 2", conns)
+	}
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	cert, roots := testCertificate(t)
+	srv := newSMTPServer(t, &smtpServer{cert: cert, starttls: true, authMech: []string{"PLAIN"}})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "starttls", Username: "user", Password: "secret"}, roots)
+
+	if _, err := h.SendMessage(context.Background(), testEmail("a@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, msgs, auths := srv.snapshot()
+	if len(auths) != 1 || !auths[0].tls || auths[0].mech != "PLAIN" || auths[0].username != "user" || auths[0].password != "secret" {
+		t.Errorf("auths = %+v, want PLAIN user/secret over TLS", auths)
+	}
+	if len(msgs) != 1 || !msgs[0].tls {
+		t.Errorf("messages = %+v, want one sent over TLS", msgs)
+	}
+}
+
+func TestSMTPStartTLSRequired(t *testing.T) {
+	srv := newSMTPServer(t, &smtpServer{})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "starttls"}, nil)
+
+	_, err := h.SendMessage(context.Background(), testEmail("a@example.com"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("err = %v, want a missing STARTTLS error", err)
+	}
+	if _, _, msgs, _ := srv.snapshot(); len(msgs) != 0 {
+		t.Errorf("sent %d messages in the clear", len(msgs))
+	}
+}
+
+func TestSMTPStartTLSUntrustedCertificate(t *testing.T) {
+	cert, _ := testCertificate(t)
+	srv := newSMTPServer(t, &smtpServer{cert: cert, starttls: true})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "starttls"}, nil)
+
+	if _, err := h.SendMessage(context.Background(), testEmail("a@example.com")); err == nil {
+		t.Fatal("sent through a server with an untrusted certificate")
+	}
+}
+
+func TestSMTPImplicitTLS(t *testing.T) {
+	cert, roots := testCertificate(t)
+	srv := newSMTPServer(t, &smtpServer{cert: cert, implicit: true, authMech: []string{"PLAIN", "LOGIN"}})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "implicit", Username: "user", Password: "secret"}, roots)
+
+	if _, err := h.SendMessage(context.Background(), testEmail("a@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, msgs, auths := srv.snapshot()
+	if len(auths) != 1 || !auths[0].tls || auths[0].mech != "PLAIN" {
+		t.Errorf("auths = %+v, want PLAIN preferred over TLS", auths)
+	}
+	if len(msgs) != 1 || !msgs[0].tls {
+		t.Errorf("messages = %+v, want one sent over TLS", msgs)
+	}
+}
+
+func TestSMTPLoginAuth(t *testing.T) {
+	cert, roots := testCertificate(t)
+	tests := []struct {
+		name string
+		auth string
+		mech []string
+	}{
+		{"picked when only LOGIN is offered", "", []string{"LOGIN"}},
+		{"forced by config", "login", []string{"PLAIN", "LOGIN"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMTPServer(t, &smtpServer{cert: cert, starttls: true, authMech: tt.mech})
+			h := srv.handler(t, confs.SMTPConfig{TLS: "starttls", Username: "user", Password: "p@ss word", Auth: tt.auth}, roots)
+
+			if _, err := h.SendMessage(context.Background(), testEmail("a@example.com")); err != nil {
+				t.Fatal(err)
+			}
+			_, _, _, auths := srv.snapshot()
+			if len(auths) != 1 || auths[0].mech != "LOGIN" || auths[0].username != "user" || auths[0].password != "p@ss word" {
+				t.Errorf("auths = %+v, want LOGIN user/p@ss word", auths)
+			}
+		})
+	}
+}
+
+func TestSMTPNoSupportedAuth(t *testing.T) {
+	srv := newSMTPServer(t, &smtpServer{authMech: []string{"CRAM-MD5"}})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "none", Username: "user", Password: "secret"}, nil)
+
+	_, err := h.SendMessage(context.Background(), testEmail("a@example.com"))
+	if err == nil || !strings.Contains(err.Error(), "no supported AUTH") {
+		t.Fatalf("err = %v, want no supported AUTH mechanism", err)
+	}
+}
+
+func TestLoginAuthRefusesPlaintext(t *testing.T) {
+	a := &loginAuth{username: "user", password: "secret", host: "mail.example.com"}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "mail.example.com", TLS: false}); err == nil {
+		t.Error("LOGIN started over an unencrypted connection to a remote host")
+	}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "other.example.com", TLS: true}); err == nil {
+		t.Error("LOGIN started against the wrong host")
+	}
+	if _, err := a.Next([]byte("Realm:"), true); err == nil {
+		t.Error("LOGIN answered an unexpected challenge")
+	}
+}
+
+func TestSMTPRecipients(t *testing.T) {
+	srv := newSMTPServer(t, &smtpServer{})
+	h := srv.handler(t, confs.SMTPConfig{TLS: "none"}, nil)
+
+	msg := testEmail("to@example.com")
+	msg.CC = []string{"cc@example.com"}
+	msg.BCC = []string{"bcc@example.com"}
+	if _, err := h.SendMessage(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	_, _, msgs, _ := srv.snapshot()
+	if len(msgs) != 1 {
+		t.Fatalf("messages = %d, want 1", len(msgs))
+	}
+	if got := strings.Join(msgs[0].rcpt, ","); got != "to@example.com,cc@example.com,bcc@example.com" {
+		t.Errorf("RCPT TO = %s", got)
+	}
+	if strings.Contains(msgs[0].data, "bcc@example.com") {
+		t.Error("Bcc address leaked into the message")
+	}
+}
+
+func TestBuildMIME(t *testing.T) {
+	from := &mail.Address{Name: "Sender", Address: "noreply@example.com"}
+	to := &mail.Address{Name: "Zoë", Address: "to@example.com"}
+	extras := emailExtras{
+		cc:      []*mail.Address{{Address: "cc@example.com"}},
+		replyTo: &mail.Address{Address: "reply@example.com"},
+		headers: map[string]string{"X-Campaign": "spring ☀"},
+	}
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	text := "line one\nline two with a long tail " + strings.Repeat("x", 100)
+	htmlBody := textToHTML(text)
+
+	raw, err := buildMIME(from, to, "Grüße\r\nBcc: evil@example.com", "<id@example.com>", text, htmlBody, extras, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Grüße\r\nBcc: evil@example.com" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if m.Header.Get("Bcc") != "" {
+		t.Error("subject injected a Bcc header")
+	}
+	campaign, _ := dec.DecodeHeader(m.Header.Get("X-Campaign"))
+	for name, want := range map[string]string{
+		"Cc":         "<cc@example.com>",
+		"Reply-To":   "<reply@example.com>",
+		"Message-Id": "<id@example.com>",
+		"Date":       date.Format(time.RFC1123Z),
+	} {
+		if got := m.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if campaign != "spring ☀" {
+		t.Errorf("X-Campaign = %q", campaign)
+	}
+	if addr, err := mail.ParseAddress(m.Header.Get("To")); err != nil || addr.Name != "Zoë" {
+		t.Errorf("To = %q (%v)", m.Header.Get("To"), err)
+	}
+
+	parts := readParts(t, m.Header.Get("Content-Type"), m.Body)
+	if len(parts) != 2 {
+		t.Fatalf("parts = %d, want text and html", len(parts))
+	}
+	if parts[0].contentType != "text/plain" || parts[0].body != text {
+		t.Errorf("text part = %+v", parts[0])
+	}
+	if parts[1].contentType != "text/html" || parts[1].body != htmlBody {
+		t.Errorf("html part = %+v", parts[1])
+	}
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > 998 {
+			t.Errorf("line longer than 998 characters: %q", line)
+		}
+	}
+	if strings.Contains(string(raw), strings.Repeat("x", 100)) {
+		t.Error("long body line was not wrapped")
+	}
+}
+
+func TestBuildMIMEAttachments(t *testing.T) {
+	data := []byte(strings.Repeat("attachment bytes ", 20))
+	extras := emailExtras{attachments: []emailAttachment{
+		{filename: "notes.txt", contentType: "text/plain", disposition: "attachment", data: data},
+		{filename: "logo.png", contentType: "image/png", disposition: "inline", contentID: "logo", data: []byte{0x89, 'P', 'N', 'G'}},
+	}}
+	raw, err := buildMIME(&mail.Address{Address: "a@example.com"}, &mail.Address{Address: "b@example.com"},
+		"s", "<id@example.com>", "text", "<p>html</p>", extras, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %s, want multipart/mixed", mediaType)
+	}
+	parts := readParts(t, m.Header.Get("Content-Type"), m.Body)
+	if len(parts) != 3 {
+		t.Fatalf("parts = %d, want alternative plus two attachments", len(parts))
+	}
+	alt := readParts(t, parts[0].header.Get("Content-Type"), strings.NewReader(parts[0].body))
+	if len(alt) != 2 || alt[0].body != "text" || alt[1].body != "<p>html</p>" {
+		t.Errorf("alternative parts = %+v", alt)
+	}
+
+	for i, want := range extras.attachments {
+		p := parts[i+1]
+		disposition, params, _ := mime.ParseMediaType(p.header.Get("Content-Disposition"))
+		if disposition != want.disposition || params["filename"] != want.filename {
+			t.Errorf("attachment %d disposition = %q", i, p.header.Get("Content-Disposition"))
+		}
+		if p.contentType != want.contentType {
+			t.Errorf("attachment %d type = %s, want %s", i, p.contentType, want.contentType)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(p.body, "\n", ""))
+		if err != nil || string(decoded) != string(want.data) {
+			t.Errorf("attachment %d data = %q (%v)", i, decoded, err)
+		}
+	}
+	if got := parts[2].header.Get("Content-Id"); got != "<logo>" {
+		t.Errorf("Content-ID = %q, want <logo>", got)
+	}
+}
+
+type mimePart struct {
+	header      textproto.MIMEHeader
+	contentType string
+	body        string
+}
+
+// readParts reads every part of a multipart body. Quoted-printable parts
+// come back decoded.
+func readParts(t *testing.T, contentType string, body io.Reader) []mimePart {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := multipart.NewReader(bufio.NewReader(body), params["boundary"])
+	var out []mimePart
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		// Quoted-printable decodes line breaks to their canonical CRLF.
+		body := strings.ReplaceAll(string(b), "\r\n", "\n")
+		out = append(out, mimePart{header: p.Header, contentType: mediaType, body: body})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// statsWindow is how far back provider success/error rates look.
+const statsWindow = 15 * time.Minute
+
+// ProviderStatus describes one provider for introspection.
+type ProviderStatus struct {
+	Name        string   `json:"name"`
+	Registered  bool     `json:"registered"`
+	Configured  bool     `json:"configured"`
+	Window      string   `json:"window"`
+	Successes   int      `json:"successes"`
+	Failures    int      `json:"failures"`
+	SuccessRate *float64 `json:"success_rate"`
+	ErrorRate   *float64 `json:"error_rate"`
+	LastError   string   `json:"last_error,omitempty"`
+	LastErrorAt *string  `json:"last_error_at,omitempty"`
+}
+
+type outcome struct {
+	at time.Time
+	ok bool
+}
+
+// providerStats keeps send outcomes per provider for the last statsWindow.
+type providerStats struct {
+	mu          sync.Mutex
+	outcomes    map[string][]outcome
+	lastError   map[string]string
+	lastErrorAt map[string]time.Time
+}
+
+func newProviderStats() *providerStats {
+	return &providerStats{
+		outcomes:    map[string][]outcome{},
+		lastError:   map[string]string{},
+		lastErrorAt: map[string]time.Time{},
+	}
+}
+
+func (s *providerStats) record(provider string, err error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcomes[provider] = append(s.prune(provider, now), outcome{at: now, ok: err == nil})
+	if err != nil {
+		s.lastError[provider] = err.Error()
+		s.lastErrorAt[provider] = now
+	}
+}
+
+// prune drops outcomes older than the window. Callers hold s.mu.
+func (s *providerStats) prune(provider string, now time.Time) []outcome {
+	list := s.outcomes[provider]
+	cutoff := now.Add(-statsWindow)
+	i := 0
+	for i < len(list) && list[i].at.Before(cutoff) {
+		i++
+	}
+	list = list[i:]
+	s.outcomes[provider] = list
+	return list
+}
+
+func (s *providerStats) fill(st *ProviderStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.Window = statsWindow.String()
+	for _, o := range s.prune(st.Name, time.Now()) {
+		if o.ok {
+			st.Successes++
+		} else {
+			st.Failures++
+		}
+	}
+	if total := st.Successes + st.Failures; total > 0 {
+		success := float64(st.Successes) / float64(total)
+		failure := 1 - success
+		st.SuccessRate, st.ErrorRate = &success, &failure
+	}
+	if at, ok := s.lastErrorAt[st.Name]; ok {
+		ts := at.UTC().Format(time.RFC3339)
+		st.LastError, st.LastErrorAt = s.lastError[st.Name], &ts
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+
+	"github.com/google/uuid"
+)
+
+// teamsMaxPayload is the largest message Teams accepts, in bytes.
+const teamsMaxPayload = 28 << 10
+
+// TeamsHandler posts messages as Adaptive Cards to Microsoft Teams incoming
+// webhooks, either classic connectors or Workflows. The destination is the
+// webhook URL.
+type TeamsHandler struct {
+	poster chatPoster
+}
+
+func NewTeamsHandler(cfg confs.ChatConfig) *TeamsHandler {
+	return &TeamsHandler{poster: newChatPoster("teams", teamsWebhookHosts, cfg)}
+}
+
+func (h *TeamsHandler) ValidateMessage(input entities.Message) error {
+	if input.Destination == "" {
+		return errors.New("destination (Teams webhook URL) is required")
+	}
+	if err := h.poster.checkURL(input.Destination); err != nil {
+		return fmt.Errorf("invalid Teams webhook URL: %w", err)
+	}
+	if input.Content == "" {
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Wrap   bool   `json:"wrap"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+}
+
+type adaptiveCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	Body    []teamsTextBlock `json:"body"`
+	MSTeams struct {
+		Width string `json:"width"`
+	} `json:"msteams"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	ContentURL  *string      `json:"contentUrl"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+// teamsMessage renders the subject as a bold heading and the content as a
+// wrapped text block, which Teams formats as Markdown.
+func teamsMessage(input entities.Message) teamsPayload {
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+	}
+	card.MSTeams.Width = "Full"
+	if input.Subject != "" {
+		card.Body = append(card.Body, teamsTextBlock{Type: "TextBlock", Text: input.Subject, Wrap: true, Weight: "Bolder", Size: "Medium"})
+	}
+	card.Body = append(card.Body, teamsTextBlock{Type: "TextBlock", Text: input.Content, Wrap: true})
+	return teamsPayload{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+}
+
+// SendMessage posts input to the webhook URL. Teams returns no message ID,
+// so the returned ID is generated here.
+func (h *TeamsHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = "teams"
+
+	if err := h.ValidateMessage(input); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(teamsMessage(input))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > teamsMaxPayload {
+		return "", fmt.Errorf("message is %d bytes as a card, Teams allows %d", len(body), teamsMaxPayload)
+	}
+
+	status, resp, err := h.poster.post(ctx, input.Destination, body)
+	if err != nil {
+		return "", err
+	}
+	if status < 200 || status > 299 {
+		return "", fmt.Errorf("teams error: status=%d: %s", status, resp)
+	}
+	// Classic connectors answer 200 "1", but report some failures, such as
+	// throttling further down the line, as 200 with an error message.
+	if strings.Contains(resp, "returned HTTP error") {
+		return "", fmt.Errorf("teams error: %s", resp)
+	}
+	return uuid.NewString(), nil
+}
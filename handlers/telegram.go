@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+// telegramMaxText is the longest message text Telegram accepts, in characters.
+const telegramMaxText = 4096
+
+// A chat is addressed by its numeric ID (negative for groups and channels)
+// or, for public channels, by @username.
+var telegramChat = regexp.MustCompile(`^(-?[0-9]{1,20}|@[A-Za-z][A-Za-z0-9_]{4,31})$`)
+
+// telegramMarkdownEscaper escapes the characters MarkdownV2 reserves.
+var telegramMarkdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// TelegramHandler sends messages through the Telegram Bot API. The bot
+// token comes from the integration; the destination is the chat ID.
+type TelegramHandler struct {
+	client  *nethttp.Client
+	baseURL string
+	token   string
+	timeout time.Duration
+}
+
+func NewTelegramHandler(cfg confs.TelegramConfig) *TelegramHandler {
+	return &TelegramHandler{
+		client:  newHTTPClient(time.Duration(cfg.Timeout)),
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		timeout: time.Duration(cfg.Timeout),
+	}
+}
+
+// withIntegration returns a handler sending as the integration's bot,
+// through its own Bot API server if it sets one.
+func (h *TelegramHandler) withIntegration(integration entities.Integration) (*TelegramHandler, error) {
+	if integration.Token == "" {
+		return nil, errors.New("telegram integration has no bot token")
+	}
+	out := *h
+	out.token = integration.Token
+	if integration.BaseURL != "" {
+		out.baseURL = strings.TrimRight(integration.BaseURL, "/")
+	}
+	return &out, nil
+}
+
+func (h *TelegramHandler) ValidateMessage(input entities.Message) error {
+	if input.Destination == "" {
+		return errors.New("destination (chat ID) is required")
+	}
+	if !telegramChat.MatchString(input.Destination) {
+		return errors.New("invalid Telegram chat: use a numeric chat ID or @channelusername")
+	}
+	if input.Content == "" {
+		return errors.New("content is required")
+	}
+	parseMode := ""
+	if input.Telegram != nil {
+		parseMode = input.Telegram.ParseMode
+	}
+	switch parseMode {
+	case "":
+		// Formatted text is measured by Telegram after parsing; plain text
+		// can be checked here.
+		if n := utf8.RuneCountInString(telegramText(input)); n > telegramMaxText {
+			return fmt.Errorf("message is %d characters, Telegram allows %d", n, telegramMaxText)
+		}
+	case "MarkdownV2", "HTML":
+	default:
+		return fmt.Errorf("telegram parse_mode must be MarkdownV2 or HTML, got %q", parseMode)
+	}
+	return nil
+}
+
+// telegramText prefixes the content with the subject, in bold when the
+// parse mode allows. Content is sent as written, so it must already be
+// valid in the chosen parse mode.
+func telegramText(input entities.Message) string {
+	if input.Subject == "" {
+		return input.Content
+	}
+	parseMode := ""
+	if input.Telegram != nil {
+		parseMode = input.Telegram.ParseMode
+	}
+	switch parseMode {
+	case "MarkdownV2":
+		return "*" + telegramMarkdownEscaper.Replace(input.Subject) + "*\n" + input.Content
+	case "HTML":
+		return "<b>" + html.EscapeString(input.Subject) + "</b>\n" + input.Content
+	default:
+		return input.Subject + "\n\n" + input.Content
+	}
+}
+
+// telegramSendMessage is the Bot API sendMessage request.
+type telegramSendMessage struct {
+	ChatID              string               `json:"chat_id"`
+	Text                string               `json:"text"`
+	ParseMode           string               `json:"parse_mode,omitempty"`
+	DisableNotification bool                 `json:"disable_notification,omitempty"`
+	LinkPreviewOptions  *telegramLinkPreview `json:"link_preview_options,omitempty"`
+}
+
+type telegramLinkPreview struct {
+	IsDisabled bool `json:"is_disabled"`
+}
+
+// telegramResponse is the Bot API response envelope.
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	ErrorCode   int    `json:"error_code"`
+	Result      struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"result"`
+	Parameters struct {
+		MigrateToChatID int64 `json:"migrate_to_chat_id"`
+	} `json:"parameters"`
+}
+
+// SendMessage sends input and returns the Telegram message ID.
+func (h *TelegramHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = "telegram"
+
+	if h.token == "" {
+		return "", errors.New("telegram bot token is not set")
+	}
+	if err := h.ValidateMessage(input); err != nil {
+		return "", err
+	}
+
+	req := telegramSendMessage{ChatID: input.Destination, Text: telegramText(input)}
+	if opts := input.Telegram; opts != nil {
+		req.ParseMode = opts.ParseMode
+		req.DisableNotification = opts.DisableNotification
+		if opts.DisableWebPagePreview {
+			req.LinkPreviewOptions = &telegramLinkPreview{IsDisabled: true}
+		}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
+
+	httpReq, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, h.baseURL+"/bot"+h.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return "", errors.New("failed to build Telegram request")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		// The request URL carries the bot token; keep it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("failed to call Telegram: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Telegram response: %w", err)
+	}
+	var out telegramResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", fmt.Errorf("telegram error: status=%d: unreadable response", resp.StatusCode)
+	}
+	if !out.OK {
+		// Rate limit descriptions already say how long to wait; a group
+		// upgraded to a supergroup has to be addressed by its new ID.
+		if out.Parameters.MigrateToChatID != 0 {
+			return "", fmt.Errorf("telegram error: %d %s (chat moved to %d)", out.ErrorCode, out.Description, out.Parameters.MigrateToChatID)
+		}
+		return "", fmt.Errorf("telegram error: %d %s", out.ErrorCode, out.Description)
+	}
+	if out.Result.MessageID == 0 {
+		return "", errors.New("no message ID in Telegram response")
+	}
+	return strconv.FormatInt(out.Result.MessageID, 10), nil
+}
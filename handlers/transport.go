@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultProviderTimeout bounds a provider call when none is configured.
+const DefaultProviderTimeout = 10 * time.Second
+
+// sharedTransport is reused by every provider so connections are pooled and
+// no dial, TLS handshake or response header wait can hang indefinitely.
+var sharedTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   20,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// ErrInternalAddress is returned when a customer-supplied URL points at, or
+// resolves to, an address that is not on the public internet.
+var ErrInternalAddress = errors.New("destination is not a public internet address")
+
+// publicTransport is for URLs chosen by API callers: status callbacks,
+// webhook, chat and push endpoints. Every connection is checked after DNS
+// resolution, so a public hostname cannot lead to an internal service. It
+// never uses a proxy, which would hide the real destination from the check.
+var publicTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   denyInternalAddress,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   20,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// nonPublicPrefixes are ranges not covered by the netip.Addr predicates used
+// in isPublicAddr.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, including broadcast
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address.
+// Loopback, private (RFC 1918 and ULA), link-local (which includes cloud
+// metadata services), multicast and unspecified addresses are not.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// denyInternalAddress is a net.Dialer Control hook rejecting connections to
+// non-public addresses. address is already resolved to an IP.
+func denyInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addr) {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+// CheckPublicURL requires an absolute http(s) URL whose host is not an
+// internal IP literal or localhost. Hostnames are checked again when
+// connecting, after they resolve.
+func CheckPublicURL(raw string) error {
+	if err := checkHTTPURL(raw); err != nil {
+		return err
+	}
+	u, _ := url.Parse(raw)
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%q: %w", raw, ErrInternalAddress)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return fmt.Errorf("%q: %w", raw, ErrInternalAddress)
+	}
+	return nil
+}
+
+// newPublicHTTPClient returns a client on publicTransport that does not
+// follow redirects, so a public URL cannot bounce a request inward.
+func newPublicHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport:     publicTransport,
+		Timeout:       timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// newHTTPClient returns a client on the shared transport. The timeout is a
+// backstop; providers also derive a context deadline per call.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: sharedTransport, Timeout: timeout}
+}
+
+// contextTransport attaches ctx to every request, for SDKs that build their
+// requests without one.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// withTimeout applies the provider timeout to ctx.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultProviderTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -1,78 +1,167 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
+	"messenger-module/confs"
 	"messenger-module/entities"
 
-	"github.com/twilio/twilio-go"
+	"github.com/twilio/twilio-go/client"
 	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
-type TwillioHandler struct {
-	client *twilio.RestClient
-	from   string
-	env    string
-}
+// whatsAppPrefix marks a Twilio address as a WhatsApp number.
+const whatsAppPrefix = "whatsapp:"
+
+// whatsAppMaxMedia is how many media files WhatsApp accepts per message.
+const whatsAppMaxMedia = 1
 
-func NewTwillioHandler() *TwillioHandler {
-	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
-	authToken := os.Getenv("TWILIO_AUTH_TOKEN")
-	fromNumber := os.Getenv("TWILIO_PHONE_NUMBER")
-	env := os.Getenv("APP_ENV")
+var twilioContentSID = regexp.MustCompile(`^HX[0-9a-fA-F]{32}$`)
 
-	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: accountSid,
-		Password: authToken,
-	})
+type TwillioHandler struct {
+	// channel is "sms", or "whatsapp" for the copy returned by whatsApp.
+	channel        string
+	accountSID     string
+	authToken      string
+	timeout        time.Duration
+	from           string
+	env            string
+	virtualNumber  string
+	webhookBaseURL string
+}
 
+func NewTwillioHandler(cfg confs.TwilioConfig, env, webhookBaseURL string) *TwillioHandler {
 	return &TwillioHandler{
-		client: client,
-		from:   fromNumber,
-		env:    env,
+		channel:        "sms",
+		accountSID:     cfg.AccountSID,
+		authToken:      cfg.AuthToken,
+		timeout:        time.Duration(cfg.Timeout),
+		from:           cfg.PhoneNumber,
+		env:            env,
+		virtualNumber:  cfg.VirtualNumber,
+		webhookBaseURL: webhookBaseURL,
 	}
 }
 
+// whatsApp returns a handler sending WhatsApp messages through the same
+// account. Addresses get the whatsapp: prefix; callers may use it or not.
+func (h *TwillioHandler) whatsApp() *TwillioHandler {
+	out := *h
+	out.channel = "whatsapp"
+	return &out
+}
+
 func (h *TwillioHandler) ValidateMessage(input entities.Message) error {
+	if h.channel == "whatsapp" {
+		input.Destination = strings.TrimPrefix(input.Destination, whatsAppPrefix)
+	}
 	if input.Destination == "" {
 		return errors.New("recipient phone number is required")
 	}
 
-	if input.Content == "" {
+	if err := h.validateWhatsApp(input); err != nil {
+		return err
+	}
+
+	// An MMS may be media alone.
+	if input.Content == "" && !usesContentTemplate(input) && len(input.MediaURLs) == 0 {
 		return errors.New("message content is required")
 	}
 
-	if len(input.Content) > 1600 {
-		return errors.New("message content exceeds maximum length of 1600 characters")
+	if n := CountSegments(input.Content).Units; n > smsMaxChars {
+		return fmt.Errorf("message content is %d UTF-16 code units, the maximum is %d", n, smsMaxChars)
 	}
 
 	phoneRegex := regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
 	if !phoneRegex.MatchString(input.Destination) {
-		return fmt.Errorf(
-			"invalid phone number format: %s. Must be E.164 (e.g., +14155552671 for US, +447911123456 for UK)",
-			input.Destination,
-		)
+		return errors.New("invalid phone number format: destination must be E.164 (e.g., +14155552671 for US, +447911123456 for UK)")
 	}
 
-	if h.from != "" && input.Destination == h.from {
+	if from := h.sender(input); from != "" && input.Destination == from {
 		return errors.New("destination cannot be the same as the sender number")
 	}
 
 	return nil
 }
 
-func (h *TwillioHandler) SendMessage(input entities.Message) (string, error) {
-	input.Type = "sms"
-
-	dest := input.Destination
-	if h.env == "development" {
-		if v := os.Getenv("TWILIO_VIRTUAL_NUMBER"); strings.TrimSpace(v) != "" {
-			dest = strings.TrimSpace(v)
+// validateWhatsApp checks the template options, which only WhatsApp
+// messages may use, and media URLs, which WhatsApp limits to one.
+func (h *TwillioHandler) validateWhatsApp(input entities.Message) error {
+	if h.channel != "whatsapp" {
+		if input.WhatsApp != nil {
+			return errors.New("whatsapp options require a whatsapp integration")
+		}
+		return validateMediaURLs(input.MediaURLs, mmsMaxMedia)
+	}
+	if opts := input.WhatsApp; opts != nil {
+		if opts.ContentSID != "" && !twilioContentSID.MatchString(opts.ContentSID) {
+			return fmt.Errorf("invalid content_sid %q: expected HX followed by 32 hex digits", opts.ContentSID)
 		}
+		if opts.ContentSID == "" && len(opts.ContentVariables) > 0 {
+			return errors.New("content_variables require a content_sid")
+		}
+	}
+	if len(input.MediaURLs) > whatsAppMaxMedia {
+		return fmt.Errorf("whatsapp allows %d media file per message", whatsAppMaxMedia)
+	}
+	if err := validateMediaURLs(input.MediaURLs, whatsAppMaxMedia); err != nil {
+		return err
+	}
+	if usesContentTemplate(input) && len(input.MediaURLs) > 0 {
+		return errors.New("media_urls cannot be combined with a content template; put the media in the template")
+	}
+	return nil
+}
+
+// usesContentTemplate reports whether input is sent from a pre-approved
+// content template rather than its own body.
+func usesContentTemplate(input entities.Message) bool {
+	return input.WhatsApp != nil && input.WhatsApp.ContentSID != ""
+}
+
+// sender is the number a message goes out from: its own From, set from the
+// integration's number, or the configured default.
+func (h *TwillioHandler) sender(input entities.Message) string {
+	from := input.From
+	if from == "" {
+		from = h.from
+	}
+	if h.channel == "whatsapp" {
+		return strings.TrimPrefix(from, whatsAppPrefix)
+	}
+	return from
+}
+
+// api returns a Twilio API service whose requests carry ctx. twilio-go builds
+// requests without a context, so it is attached at the transport.
+func (h *TwillioHandler) api(ctx context.Context) *twilioApi.ApiService {
+	c := &client.Client{
+		Credentials: client.NewCredentials(h.accountSID, h.authToken),
+		HTTPClient: &http.Client{
+			Transport: contextTransport{ctx: ctx, base: sharedTransport},
+			Timeout:   h.timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	c.SetAccountSid(h.accountSID)
+	return twilioApi.NewApiService(client.NewRequestHandler(c))
+}
+
+func (h *TwillioHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = h.channel
+
+	dest := strings.TrimPrefix(input.Destination, whatsAppPrefix)
+	if h.env == "development" && h.virtualNumber != "" {
+		dest = h.virtualNumber
 	}
 
 	eff := input
@@ -81,17 +170,46 @@ func (h *TwillioHandler) SendMessage(input entities.Message) (string, error) {
 		return "", err
 	}
 
+	if h.channel == "sms" && len(input.MediaURLs) > 0 {
+		if err := h.checkMediaURLs(ctx, input.MediaURLs); err != nil {
+			return "", err
+		}
+	}
+
 	params := &twilioApi.CreateMessageParams{}
 
-	if base := strings.TrimRight(os.Getenv("WEBHOOK_BASE_URL"), "/"); base != "" {
+	if base := strings.TrimRight(h.webhookBaseURL, "/"); base != "" {
 		params.SetStatusCallback(fmt.Sprintf("%s/api/v1/webhooks/twilio", base))
 	}
 
-	params.SetTo(dest)
-	params.SetFrom(h.from)
-	params.SetBody(input.Content)
+	if h.channel == "whatsapp" {
+		params.SetTo(whatsAppPrefix + dest)
+		params.SetFrom(whatsAppPrefix + h.sender(input))
+	} else {
+		params.SetTo(dest)
+		params.SetFrom(h.sender(input))
+	}
+
+	if usesContentTemplate(input) {
+		params.SetContentSid(input.WhatsApp.ContentSID)
+		if len(input.WhatsApp.ContentVariables) > 0 {
+			vars, err := json.Marshal(input.WhatsApp.ContentVariables)
+			if err != nil {
+				return "", err
+			}
+			params.SetContentVariables(string(vars))
+		}
+	} else if input.Content != "" {
+		params.SetBody(input.Content)
+	}
+	if len(input.MediaURLs) > 0 {
+		params.SetMediaUrl(input.MediaURLs)
+	}
+
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
 
-	resp, err := h.client.Api.CreateMessage(params)
+	resp, err := h.api(ctx).CreateMessage(params)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEvent is the X-Messenger-Event value of webhook provider requests.
+const WebhookEvent = "message.created"
+
+// webhookReservedHeaders are set by the provider and may not be overridden by
+// an integration's custom headers.
+var webhookReservedHeaders = map[string]bool{
+	"Host": true, "Content-Length": true, "Content-Type": true, "Transfer-Encoding": true,
+	"Connection": true, SignatureHeader: true, EventHeader: true, DeliveryHeader: true,
+}
+
+// WebhookHandler POSTs messages as JSON to customer endpoints. The endpoint,
+// payload shape, extra headers and signing secret come from the integration.
+type WebhookHandler struct {
+	sender   *CallbackSender
+	url      string
+	template *template.Template
+	headers  map[string]string
+	secret   string
+}
+
+func NewWebhookHandler(cfg confs.WebhookConfig) *WebhookHandler {
+	return &WebhookHandler{sender: NewCallbackSender(time.Duration(cfg.Timeout))}
+}
+
+// withIntegration returns a handler posting to the integration's endpoint
+// with its payload template, headers and signing secret. The endpoint is
+// pinned on the integration, which any user on its plan may send through,
+// so nobody can have messages signed with its secret sent elsewhere.
+func (h *WebhookHandler) withIntegration(integration entities.Integration) (*WebhookHandler, error) {
+	if integration.BaseURL == "" {
+		return nil, errors.New("webhook integration has no base_url")
+	}
+	tmpl, err := ParseWebhookTemplate(integration.PayloadTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookHandler{
+		sender:   h.sender,
+		url:      integration.BaseURL,
+		template: tmpl,
+		headers:  integration.Headers,
+		secret:   integration.Token,
+	}, nil
+}
+
+// webhookTemplateData is what payload templates are executed with: every
+// Message field plus the delivery ID and send time.
+type webhookTemplateData struct {
+	entities.Message
+	DeliveryID string
+	SentAt     string
+}
+
+// defaultWebhookPayload is sent when the integration has no template.
+type defaultWebhookPayload struct {
+	DeliveryID    string `json:"delivery_id"`
+	SentAt        string `json:"sent_at"`
+	UserID        string `json:"user_id"`
+	IntegrationID string `json:"integration_id"`
+	Subject       string `json:"subject,omitempty"`
+	Content       string `json:"content"`
+	Destination   string `json:"destination"`
+	TraceID       string `json:"trace_id,omitempty"`
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	// json renders a value as a JSON literal, quoting and escaping strings.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseWebhookTemplate parses a payload template. Templates use text/template
+// syntax and should insert values with the json function, e.g.
+// {"text": {{json .Content}}}. An empty template selects the default payload.
+func ParseWebhookTemplate(src string) (*template.Template, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("payload").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload_template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ValidateWebhookHeaders checks an integration's custom headers.
+func ValidateWebhookHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if !headerName.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if webhookReservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+			return fmt.Errorf("header %s cannot be set", textproto.CanonicalMIMEHeaderKey(name))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %s must not contain line breaks", name)
+		}
+	}
+	return nil
+}
+
+func (h *WebhookHandler) ValidateMessage(input entities.Message) error {
+	if input.Destination == "" {
+		return errors.New("destination (webhook URL) is required")
+	}
+	if err := CheckPublicURL(input.Destination); err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if h.url != "" && input.Destination != h.url {
+		return errors.New("destination must be the webhook integration's base_url")
+	}
+	if input.Content == "" {
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+func (h *WebhookHandler) payload(input entities.Message, deliveryID string, sentAt time.Time) ([]byte, error) {
+	data := webhookTemplateData{Message: input, DeliveryID: deliveryID, SentAt: sentAt.UTC().Format(time.RFC3339)}
+	if h.template == nil {
+		return json.Marshal(defaultWebhookPayload{
+			DeliveryID:    data.DeliveryID,
+			SentAt:        data.SentAt,
+			UserID:        input.UserID,
+			IntegrationID: input.IntegrationID,
+			Subject:       input.Subject,
+			Content:       input.Content,
+			Destination:   input.Destination,
+			TraceID:       input.TraceID,
+		})
+	}
+	var buf bytes.Buffer
+	if err := h.template.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("payload_template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("payload_template did not produce valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// SendMessage POSTs the payload to the destination URL and returns the
+// delivery ID. Only a 2xx response counts as delivered; redirects are not
+// followed.
+func (h *WebhookHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = "webhook"
+
+	if err := h.ValidateMessage(input); err != nil {
+		return "", err
+	}
+
+	deliveryID := uuid.NewString()
+	now := time.Now()
+	body, err := h.payload(input, deliveryID, now)
+	if err != nil {
+		return "", err
+	}
+
+	headers := make(map[string]string, len(h.headers)+4)
+	for name, value := range h.headers {
+		headers[name] = value
+	}
+	headers["User-Agent"] = "messenger-module-webhooks"
+	headers[EventHeader] = WebhookEvent
+	headers[DeliveryHeader] = deliveryID
+	if h.secret != "" {
+		headers[SignatureHeader] = SignPayload(h.secret, now.Unix(), body)
+	}
+
+	// The response body is not passed back: it belongs to the endpoint, not
+	// to whoever sent the message.
+	status, _, err := h.sender.Post(ctx, input.Destination, headers, body)
+	if err != nil {
+		return "", err
+	}
+	if status < 200 || status > 299 {
+		return "", fmt.Errorf("webhook error: status=%d", status)
+	}
+	return deliveryID, nil
+}
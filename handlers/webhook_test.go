@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+func webhookMessage(destination string) entities.Message {
+	return entities.Message{
+		UserID: "u1", IntegrationID: "i1", Destination: destination,
+		Subject: "Deploy", Content: "Release \"v2\"\nis <out>",
+	}
+}
+
+// testSender returns a CallbackSender that, like NewCallbackSender, does not
+// follow redirects, but connects to srv whatever the URL's host. Requests
+// can then name a public host such as example.com, which the test
+// certificate is also valid for.
+func testSender(srv *httptest.Server) *CallbackSender {
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &CallbackSender{client: client, timeout: 5 * time.Second}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	sentAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "default payload",
+			want: `{"delivery_id":"d1","sent_at":"2026-03-01T12:00:00Z","user_id":"u1","integration_id":"i1",` +
+				`"subject":"Deploy","content":"Release \"v2\"\nis \u003cout\u003e","destination":"https://example.com/hook"}`,
+		},
+		{
+			name:     "json escapes values",
+			template: `{"text": {{json .Content}}, "id": {{json .DeliveryID}}, "at": {{json .SentAt}}}`,
+			want:     `{"text": "Release \"v2\"\nis \u003cout\u003e", "id": "d1", "at": "2026-03-01T12:00:00Z"}`,
+		},
+		{
+			name:     "unquoted value breaks JSON",
+			template: `{"text": "{{.Content}}"}`,
+			wantErr:  "did not produce valid JSON",
+		},
+		{
+			name:     "unknown field",
+			template: `{"text": {{json .Nope}}}`,
+			wantErr:  "payload_template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseWebhookTemplate(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := &WebhookHandler{template: tmpl}
+			got, err := h.payload(webhookMessage("https://example.com/hook"), "d1", sentAt)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("payload = %s\nwant      %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWebhookTemplate(t *testing.T) {
+	if tmpl, err := ParseWebhookTemplate("  \n"); tmpl != nil || err != nil {
+		t.Errorf("blank template = %v, %v, want the default payload", tmpl, err)
+	}
+	if _, err := ParseWebhookTemplate(`{"text": {{json .Content}`); err == nil {
+		t.Error("parsed a template with an unclosed action")
+	}
+	if _, err := ParseWebhookTemplate(`{{env "SECRET"}}`); err == nil {
+		t.Error("parsed a template calling an undefined function")
+	}
+}
+
+func TestValidateWebhookHeaders(t *testing.T) {
+	tests := []struct {
+		headers map[string]string
+		ok      bool
+	}{
+		{map[string]string{"Authorization": "Bearer x", "X-Team": "ops"}, true},
+		{map[string]string{"x-messenger-signature": "forged"}, false},
+		{map[string]string{"Content-Type": "text/plain"}, false},
+		{map[string]string{"Host": "internal"}, false},
+		{map[string]string{"Bad Name": "x"}, false},
+		{map[string]string{"X-Team": "ops\r\nX-Injected: 1"}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateWebhookHeaders(tt.headers); (err == nil) != tt.ok {
+			t.Errorf("ValidateWebhookHeaders(%v) = %v, want ok=%v", tt.headers, err, tt.ok)
+		}
+	}
+}
+
+func TestWebhookSendSigned(t *testing.T) {
+	var got http.Header
+	var body []byte
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	h := &WebhookHandler{
+		sender:  testSender(srv),
+		headers: map[string]string{"X-Team": "ops"},
+		secret:  "whsec_test",
+	}
+	id, err := h.SendMessage(context.Background(), webhookMessage("https://example.com/hook"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Get(DeliveryHeader) != id || got.Get(EventHeader) != WebhookEvent || got.Get("X-Team") != "ops" {
+		t.Errorf("headers = %v", got)
+	}
+	var payload defaultWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.DeliveryID != id {
+		t.Errorf("payload = %s (%v)", body, err)
+	}
+
+	ts, sig, ok := strings.Cut(got.Get(SignatureHeader), ",v1=")
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(strings.TrimPrefix(ts, "t=") + "." + string(body)))
+	if !ok || sig != hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("signature %q does not verify", got.Get(SignatureHeader))
+	}
+}
+
+func TestWebhookSendErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		ok     bool
+	}{
+		{"ok", http.StatusOK, true},
+		{"no content", http.StatusNoContent, true},
+		{"redirect not followed", http.StatusFound, false},
+		{"client error", http.StatusBadRequest, false},
+		{"server error", http.StatusBadGateway, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, "internal detail")
+			}))
+			defer srv.Close()
+
+			h := &WebhookHandler{sender: testSender(srv)}
+			_, err := h.SendMessage(context.Background(), webhookMessage("https://example.com/hook"))
+			if (err == nil) != tt.ok {
+				t.Errorf("err = %v, want ok=%v", err, tt.ok)
+			}
+			if err != nil && strings.Contains(err.Error(), "internal detail") {
+				t.Errorf("err = %v echoes the response body", err)
+			}
+		})
+	}
+}
+
+func TestWebhookRefusesInternalAddresses(t *testing.T) {
+	h := NewWebhookHandler(confs.WebhookConfig{Timeout: confs.Duration(time.Second)})
+	for _, dest := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.5/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"ftp://example.com/hook",
+	} {
+		if _, err := h.SendMessage(context.Background(), webhookMessage(dest)); err == nil {
+			t.Errorf("sent to %s", dest)
+		}
+	}
+
+	// A hostname is checked again once it resolves.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+	_, _, err := h.sender.Post(context.Background(), srv.URL, nil, []byte("{}"))
+	if !errors.Is(err, ErrInternalAddress) {
+		t.Errorf("err = %v, want ErrInternalAddress", err)
+	}
+}
+
+func TestWebhookPinnedToIntegration(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	base := &WebhookHandler{sender: testSender(srv)}
+
+	if _, err := base.withIntegration(entities.Integration{}); err == nil {
+		t.Error("built a webhook handler for an integration without base_url")
+	}
+	h, err := base.withIntegration(entities.Integration{BaseURL: "https://example.com/hook", Token: "whsec_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		destination string
+		ok          bool
+	}{
+		{"https://example.com/hook", true},
+		{"https://example.com/other", false},
+		{"https://attacker.example.com/hook", false},
+	}
+	for _, tt := range tests {
+		if _, err := h.SendMessage(context.Background(), webhookMessage(tt.destination)); (err == nil) != tt.ok {
+			t.Errorf("send to %s: err = %v, want ok=%v", tt.destination, err, tt.ok)
+		}
+	}
+}
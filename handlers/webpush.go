@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// webPushRecordSize is the aes128gcm record size; the whole message is
+	// one record, and push services accept at most 4096 bytes of it.
+	webPushRecordSize = 4096
+	// webPushMaxPayload is the largest plaintext that fits: the record size
+	// minus the header (86 bytes), the delimiter and the GCM tag.
+	webPushMaxPayload = webPushRecordSize - 86 - 1 - 16
+	// vapidTokenLifetime is how long each VAPID JWT is valid; at most 24h.
+	vapidTokenLifetime = 12 * time.Hour
+)
+
+// PushSubscriptionStore gives the webpush provider the service's VAPID keys
+// and a user's subscriptions, and lets it expire subscriptions the push
+// service reports gone.
+type PushSubscriptionStore interface {
+	VAPIDKeys(ctx context.Context) (entities.VAPIDKeys, error)
+	ActiveSubscriptions(ctx context.Context, userID, subscriber string) ([]entities.PushSubscription, error)
+	ExpireSubscription(ctx context.Context, id string) error
+}
+
+// WebPushHandler sends Web Push notifications to every active subscription
+// of the destination subscriber.
+type WebPushHandler struct {
+	client  *nethttp.Client
+	timeout time.Duration
+	subject string
+	ttl     time.Duration
+	store   PushSubscriptionStore
+}
+
+func NewWebPushHandler(cfg confs.WebPushConfig) *WebPushHandler {
+	return &WebPushHandler{
+		// Endpoints come from browsers via API callers; keep them public.
+		client:  newPublicHTTPClient(time.Duration(cfg.Timeout)),
+		timeout: time.Duration(cfg.Timeout),
+		subject: cfg.Subject,
+		ttl:     time.Duration(cfg.TTL),
+	}
+}
+
+// GenerateVAPIDKeys creates a new P-256 application server key pair.
+func GenerateVAPIDKeys() (entities.VAPIDKeys, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return entities.VAPIDKeys{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return entities.VAPIDKeys{}, err
+	}
+	pub, err := priv.PublicKey.ECDH()
+	if err != nil {
+		return entities.VAPIDKeys{}, err
+	}
+	return entities.VAPIDKeys{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(pub.Bytes()),
+		PrivateKey: base64.StdEncoding.EncodeToString(der),
+	}, nil
+}
+
+func parseVAPIDKey(keys entities.VAPIDKeys) (*ecdsa.PrivateKey, error) {
+	der, err := base64.StdEncoding.DecodeString(keys.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	priv, ok := key.(*ecdsa.PrivateKey)
+	if !ok || priv.Curve != elliptic.P256() {
+		return nil, errors.New("VAPID private key is not a P-256 key")
+	}
+	return priv, nil
+}
+
+// decodeBase64URL accepts the padded and unpadded base64url that browsers
+// and libraries produce.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ValidatePushSubscription checks a subscription's endpoint and keys.
+func ValidatePushSubscription(sub entities.PushSubscription) error {
+	// Push services are always https (RFC 8030), and never internal.
+	if !strings.HasPrefix(sub.Endpoint, "https://") {
+		return errors.New("endpoint must be an absolute https URL")
+	}
+	if err := CheckPublicURL(sub.Endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if sub.Keys == nil {
+		return errors.New("keys.p256dh and keys.auth are required")
+	}
+	p256dh, err := decodeBase64URL(sub.Keys.P256dh)
+	if err != nil {
+		return errors.New("keys.p256dh must be base64url")
+	}
+	if _, err := ecdh.P256().NewPublicKey(p256dh); err != nil {
+		return errors.New("keys.p256dh is not an uncompressed P-256 public key")
+	}
+	auth, err := decodeBase64URL(sub.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return errors.New("keys.auth must be 16 bytes, base64url encoded")
+	}
+	return nil
+}
+
+func (h *WebPushHandler) ValidateMessage(input entities.Message) error {
+	if input.Destination == "" {
+		return errors.New("destination (subscriber) is required")
+	}
+	if input.Content == "" {
+		return errors.New("content is required")
+	}
+	if opts := input.WebPush; opts != nil {
+		for _, field := range []struct{ name, value string }{{"url", opts.URL}, {"icon", opts.Icon}} {
+			if err := checkHTTPURL(field.value); err != nil {
+				return fmt.Errorf("webpush %s: %w", field.name, err)
+			}
+		}
+		if opts.TTL < 0 {
+			return errors.New("webpush ttl must not be negative")
+		}
+		switch opts.Urgency {
+		case "", "very-low", "low", "normal", "high":
+		default:
+			return fmt.Errorf("webpush urgency must be very-low, low, normal or high, got %q", opts.Urgency)
+		}
+	}
+	return nil
+}
+
+// webPushNotification is the JSON payload the site's service worker receives.
+type webPushNotification struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+// SendMessage pushes input to each active subscription of the destination
+// subscriber and returns a generated delivery ID. It succeeds if any
+// subscription accepted the push. Subscriptions answered with 404 or 410
+// are expired.
+func (h *WebPushHandler) SendMessage(ctx context.Context, input entities.Message) (string, error) {
+	input.Type = "webpush"
+
+	if err := h.ValidateMessage(input); err != nil {
+		return "", err
+	}
+	if h.store == nil {
+		return "", errors.New("webpush subscriptions are not available")
+	}
+
+	deliveryID := uuid.NewString()
+	note := webPushNotification{ID: deliveryID, Title: input.Subject, Body: input.Content}
+	ttl := int(h.ttl / time.Second)
+	urgency := ""
+	if opts := input.WebPush; opts != nil {
+		note.URL, note.Icon, note.Tag = opts.URL, opts.Icon, opts.Tag
+		if opts.TTL > 0 {
+			ttl = opts.TTL
+		}
+		urgency = opts.Urgency
+	}
+	payload, err := json.Marshal(note)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) > webPushMaxPayload {
+		return "", fmt.Errorf("notification is %d bytes, Web Push allows %d", len(payload), webPushMaxPayload)
+	}
+
+	keys, err := h.store.VAPIDKeys(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load VAPID keys: %w", err)
+	}
+	vapid, err := parseVAPIDKey(keys)
+	if err != nil {
+		return "", err
+	}
+	subs, err := h.store.ActiveSubscriptions(ctx, input.UserID, input.Destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to load push subscriptions: %w", err)
+	}
+	if len(subs) == 0 {
+		return "", fmt.Errorf("no active push subscriptions for subscriber %q", input.Destination)
+	}
+
+	var sent int
+	var errs []error
+	for _, sub := range subs {
+		status, err := h.push(ctx, vapid, keys.PublicKey, sub, payload, ttl, urgency)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case status == nethttp.StatusNotFound || status == nethttp.StatusGone:
+			if err := h.store.ExpireSubscription(ctx, sub.ID); err != nil {
+				errs = append(errs, fmt.Errorf("failed to expire subscription %s: %w", sub.ID, err))
+			} else {
+				errs = append(errs, fmt.Errorf("subscription %s has expired", sub.ID))
+			}
+		case status < 200 || status > 299:
+			errs = append(errs, fmt.Errorf("push service error for subscription %s: status=%d", sub.ID, status))
+		default:
+			sent++
+		}
+	}
+	if sent == 0 {
+		return "", errors.Join(errs...)
+	}
+	return deliveryID, nil
+}
+
+// push encrypts payload for sub and POSTs it to the subscription endpoint,
+// returning the push service's response status.
+func (h *WebPushHandler) push(ctx context.Context, vapid *ecdsa.PrivateKey, publicKey string, sub entities.PushSubscription, payload []byte, ttl int, urgency string) (int, error) {
+	if sub.Keys == nil {
+		return 0, fmt.Errorf("subscription %s has no keys", sub.ID)
+	}
+	p256dh, err := decodeBase64URL(sub.Keys.P256dh)
+	if err != nil {
+		return 0, fmt.Errorf("subscription %s: invalid p256dh key", sub.ID)
+	}
+	auth, err := decodeBase64URL(sub.Keys.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("subscription %s: invalid auth secret", sub.ID)
+	}
+	body, err := encryptWebPush(payload, p256dh, auth)
+	if err != nil {
+		return 0, fmt.Errorf("subscription %s: %w", sub.ID, err)
+	}
+	token, err := vapidToken(vapid, sub.Endpoint, h.subject, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := withTimeout(ctx, h.timeout)
+	defer cancel()
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(ttl))
+	if urgency != "" {
+		req.Header.Set("Urgency", urgency)
+	}
+	req.Header.Set("Authorization", "vapid t="+token+", k="+publicKey)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to push to subscription %s: %w", sub.ID, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	return resp.StatusCode, nil
+}
+
+// vapidToken signs the RFC 8292 JWT for the origin of endpoint.
+func vapidToken(key *ecdsa.PrivateKey, endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenLifetime).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the raw 64-byte r||s, not ASN.1.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// encryptWebPush encrypts payload for a subscription per RFC 8291, as a
+// single aes128gcm record (RFC 8188).
+func encryptWebPush(payload, uaPublic, authSecret []byte) ([]byte, error) {
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, errors.New("invalid p256dh key")
+	}
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := hkdfSHA256(authSecret, shared, keyInfo, 32)
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek := hkdfSHA256(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfSHA256(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length and the key ID, which is our
+	// ephemeral public key. The plaintext ends with the last-record delimiter.
+	out := make([]byte, 0, 16+4+1+len(asPublic)+len(payload)+1+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, webPushRecordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// hkdfSHA256 is HKDF-SHA256 (RFC 5869) for outputs of at most 32 bytes,
+// where expansion is a single HMAC.
+func hkdfSHA256(salt, ikm, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+	return expand.Sum(nil)[:length]
+}
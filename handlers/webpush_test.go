@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"messenger-module/entities"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// pushClient is a browser's side of a push subscription.
+type pushClient struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newPushClient(t *testing.T) pushClient {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return pushClient{key: key, auth: auth}
+}
+
+func (c pushClient) keys() *entities.PushKeys {
+	return &entities.PushKeys{
+		P256dh: base64.RawURLEncoding.EncodeToString(c.key.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(c.auth),
+	}
+}
+
+// decrypt reverses RFC 8291 as a user agent would, using an independent
+// HKDF implementation.
+func (c pushClient) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	plain, err := c.open(t, body)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	return plain
+}
+
+// open checks the aes128gcm header of body and decrypts its record.
+func (c pushClient) open(t *testing.T, body []byte) ([]byte, error) {
+	t.Helper()
+	if len(body) < 21 {
+		t.Fatalf("body is %d bytes, too short for an aes128gcm header", len(body))
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != webPushRecordSize {
+		t.Errorf("record size = %d, want %d", rs, webPushRecordSize)
+	}
+	idLen := int(body[20])
+	if idLen != 65 || len(body) < 21+idLen {
+		t.Fatalf("key ID length = %d, want a 65-byte P-256 point", idLen)
+	}
+	asPublic := body[21 : 21+idLen]
+	record := body[21+idLen:]
+	if len(body) > webPushRecordSize {
+		t.Errorf("message is %d bytes, over the %d byte record", len(body), webPushRecordSize)
+	}
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatalf("key ID is not a P-256 public key: %v", err)
+	}
+	shared, err := c.key.ECDH(asKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := append(append([]byte("WebPush: info\x00"), c.key.PublicKey().Bytes()...), asPublic...)
+	ikm := hkdfRead(t, c.auth, shared, info, 32)
+	cek := hkdfRead(t, salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfRead(t, salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := gcm.Open(nil, nonce, record, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The last record ends with 0x02 followed by optional zero padding.
+	plain = bytes.TrimRight(plain, "\x00")
+	if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing last-record delimiter in %x", plain)
+	}
+	return plain[:len(plain)-1], nil
+}
+
+func hkdfRead(t *testing.T, salt, secret, info []byte, n int) []byte {
+	t.Helper()
+	out := make([]byte, n)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestEncryptWebPushRoundTrip(t *testing.T) {
+	ua := newPushClient(t)
+	for _, payload := range [][]byte{
+		[]byte(`{"id":"1","body":"hello"}`),
+		[]byte("When I grow up, I want to be a watermelon"),
+		bytes.Repeat([]byte("x"), webPushMaxPayload),
+	} {
+		body, err := encryptWebPush(payload, ua.key.PublicKey().Bytes(), ua.auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ua.decrypt(t, body); !bytes.Equal(got, payload) {
+			t.Errorf("decrypted %q, want %q", got, payload)
+		}
+	}
+}
+
+func TestEncryptWebPushFreshKeys(t *testing.T) {
+	ua := newPushClient(t)
+	payload := []byte("same payload")
+	a, err := encryptWebPush(payload, ua.key.PublicKey().Bytes(), ua.auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := encryptWebPush(payload, ua.key.PublicKey().Bytes(), ua.auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a[:16], b[:16]) || bytes.Equal(a[21:86], b[21:86]) {
+		t.Error("salt or ephemeral key reused between messages")
+	}
+}
+
+func TestEncryptWebPushWrongAuthFails(t *testing.T) {
+	ua := newPushClient(t)
+	body, err := encryptWebPush([]byte("secret"), ua.key.PublicKey().Bytes(), ua.auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := ua
+	other.auth = make([]byte, 16)
+	if _, err := other.open(t, body); err == nil {
+		t.Error("decrypted with the wrong auth secret")
+	}
+	stranger := newPushClient(t)
+	stranger.auth = ua.auth
+	if _, err := stranger.open(t, body); err == nil {
+		t.Error("decrypted with the wrong private key")
+	}
+	if _, err := encryptWebPush([]byte("x"), []byte("not a key"), ua.auth); err == nil {
+		t.Error("encrypted to an invalid p256dh key")
+	}
+}
+
+func TestHKDFSHA256(t *testing.T) {
+	salt, ikm, info := []byte("salt"), []byte("input keying material"), []byte("info")
+	for _, n := range []int{12, 16, 32} {
+		if got, want := hkdfSHA256(salt, ikm, info, n), hkdfRead(t, salt, ikm, info, n); !bytes.Equal(got, want) {
+			t.Errorf("hkdfSHA256(%d) = %x, want %x", n, got, want)
+		}
+	}
+}
+
+func TestVAPIDToken(t *testing.T) {
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := parseVAPIDKey(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base64.RawURLEncoding.EncodeToString(pub.Bytes()); got != keys.PublicKey {
+		t.Fatalf("public key %s does not match the private key", keys.PublicKey)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	token, err := vapidToken(priv, "https://fcm.googleapis.com/fcm/send/abc?x=1", "mailto:ops@example.com", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts, want 3", len(parts))
+	}
+
+	var header struct{ Typ, Alg string }
+	decodeJWTPart(t, parts[0], &header)
+	if header.Alg != "ES256" || header.Typ != "JWT" {
+		t.Errorf("header = %+v", header)
+	}
+	var claims struct {
+		Aud string
+		Exp int64
+		Sub string
+	}
+	decodeJWTPart(t, parts[1], &claims)
+	if claims.Aud != "https://fcm.googleapis.com" {
+		t.Errorf("aud = %q, want the endpoint origin", claims.Aud)
+	}
+	if claims.Exp != now.Add(vapidTokenLifetime).Unix() || vapidTokenLifetime > 24*time.Hour {
+		t.Errorf("exp = %d, want now + %s and at most 24h", claims.Exp, vapidTokenLifetime)
+	}
+	if claims.Sub != "mailto:ops@example.com" {
+		t.Errorf("sub = %q", claims.Sub)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		t.Fatalf("signature is %d bytes (%v), want raw 64-byte r||s", len(sig), err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&priv.PublicKey, digest[:], r, s) {
+		t.Error("ES256 signature does not verify")
+	}
+	other, _ := GenerateVAPIDKeys()
+	otherKey, _ := parseVAPIDKey(other)
+	if ecdsa.Verify(&otherKey.PublicKey, digest[:], r, s) {
+		t.Error("signature verifies under an unrelated key")
+	}
+}
+
+func decodeJWTPart(t *testing.T, part string, v any) {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		t.Fatalf("JWT part is not unpadded base64url: %v", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// memoryPushStore is a PushSubscriptionStore over fixed subscriptions.
+type memoryPushStore struct {
+	keys    entities.VAPIDKeys
+	subs    []entities.PushSubscription
+	mu      sync.Mutex
+	expired []string
+}
+
+func (s *memoryPushStore) VAPIDKeys(context.Context) (entities.VAPIDKeys, error) {
+	return s.keys, nil
+}
+
+func (s *memoryPushStore) ActiveSubscriptions(context.Context, string, string) ([]entities.PushSubscription, error) {
+	return s.subs, nil
+}
+
+func (s *memoryPushStore) ExpireSubscription(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired = append(s.expired, id)
+	return nil
+}
+
+func TestWebPushSend(t *testing.T) {
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ua := newPushClient(t)
+
+	var mu sync.Mutex
+	var got []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got, bodies = append(got, r), append(bodies, body)
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	store := &memoryPushStore{keys: keys, subs: []entities.PushSubscription{
+		{ID: "live", Endpoint: srv.URL + "/push/live", Keys: ua.keys()},
+		{ID: "gone", Endpoint: srv.URL + "/push/gone", Keys: ua.keys()},
+	}}
+	h := &WebPushHandler{client: srv.Client(), timeout: 5 * time.Second, subject: "mailto:ops@example.com", ttl: time.Hour, store: store}
+
+	msg := entities.Message{
+		Destination: "user-1",
+		Subject:     "Hi",
+		Content:     "Your order shipped",
+		WebPush:     &entities.WebPushOptions{URL: "https://shop.example.com/orders/1", Urgency: "high", TTL: 60},
+	}
+	id, err := h.SendMessage(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("pushed %d times, want 2", len(got))
+	}
+	if len(store.expired) != 1 || store.expired[0] != "gone" {
+		t.Errorf("expired = %v, want [gone]", store.expired)
+	}
+
+	req := got[0]
+	for name, want := range map[string]string{
+		"Content-Encoding": "aes128gcm",
+		"Content-Type":     "application/octet-stream",
+		"Ttl":              "60",
+		"Urgency":          "high",
+	} {
+		if v := req.Header.Get(name); v != want {
+			t.Errorf("%s = %q, want %q", name, v, want)
+		}
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "vapid t=") || !strings.HasSuffix(auth, ", k="+keys.PublicKey) {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	var note webPushNotification
+	if err := json.Unmarshal(ua.decrypt(t, bodies[0]), &note); err != nil {
+		t.Fatal(err)
+	}
+	if note.ID != id || note.Title != "Hi" || note.Body != "Your order shipped" || note.URL != "https://shop.example.com/orders/1" {
+		t.Errorf("notification = %+v", note)
+	}
+}
+
+func TestValidatePushSubscription(t *testing.T) {
+	ua := newPushClient(t)
+	tests := []struct {
+		name     string
+		endpoint string
+		keys     *entities.PushKeys
+		ok       bool
+	}{
+		{"valid", "https://fcm.googleapis.com/fcm/send/abc", ua.keys(), true},
+		{"http endpoint", "http://fcm.googleapis.com/fcm/send/abc", ua.keys(), false},
+		{"internal endpoint", "https://127.0.0.1/push", ua.keys(), false},
+		{"localhost endpoint", "https://localhost/push", ua.keys(), false},
+		{"missing keys", "https://fcm.googleapis.com/fcm/send/abc", nil, false},
+		{"short auth", "https://fcm.googleapis.com/fcm/send/abc", &entities.PushKeys{P256dh: ua.keys().P256dh, Auth: "AAAA"}, false},
+		{"bad p256dh", "https://fcm.googleapis.com/fcm/send/abc", &entities.PushKeys{P256dh: "AAAA", Auth: ua.keys().Auth}, false},
+	}
+	for _, tt := range tests {
+		err := ValidatePushSubscription(entities.PushSubscription{Endpoint: tt.endpoint, Keys: tt.keys})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
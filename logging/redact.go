@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// destinationKeys hold recipient addresses; contentKeys hold message bodies.
+var (
+	destinationKeys = map[string]bool{"destination": true, "to": true, "from": true, "email": true, "phone": true}
+	contentKeys     = map[string]bool{"content": true, "body": true, "subject": true}
+)
+
+// Addresses embedded in free text, such as a provider's error message.
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\d{7,15}`)
+)
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case destinationKeys[key]:
+		return slog.String(a.Key, RedactDestination(a.Value.String()))
+	case contentKeys[key]:
+		return slog.String(a.Key, RedactContent(a.Value.String()))
+	case key == "error" || key == "err":
+		return slog.String(a.Key, RedactText(a.Value.String()))
+	}
+	if err, ok := a.Value.Any().(error); ok && a.Value.Kind() == slog.KindAny {
+		return slog.String(a.Key, RedactText(err.Error()))
+	}
+	return a
+}
+
+// RedactText masks every email address and phone-like number in s, for
+// values such as errors that may quote a recipient.
+func RedactText(s string) string {
+	s = emailPattern.ReplaceAllStringFunc(s, RedactDestination)
+	return phonePattern.ReplaceAllStringFunc(s, RedactDestination)
+}
+
+// RedactDestination masks an email address or phone number, keeping just
+// enough to tell recipients apart when debugging: "j***@example.com",
+// "+1******2671".
+func RedactDestination(s string) string {
+	if s == "" {
+		return s
+	}
+	if at := strings.LastIndex(s, "@"); at > 0 {
+		return s[:1] + "***" + s[at:]
+	}
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	keep := 2
+	if strings.HasPrefix(s, "+") {
+		keep = 3
+	}
+	if keep+4 >= len(s) {
+		keep = 0
+	}
+	return s[:keep] + strings.Repeat("*", len(s)-keep-4) + s[len(s)-4:]
+}
+
+// RedactContent replaces message content with its length.
+func RedactContent(s string) string {
+	if s == "" {
+		return s
+	}
+	return fmt.Sprintf("[redacted %d chars]", len(s))
+}
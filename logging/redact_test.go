@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"messenger-module/confs"
+)
+
+func TestRedactDestination(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"jane@example.com", "j***@example.com"},
+		{"j@example.com", "j***@example.com"},
+		{"+14155552671", "+14*****2671"},
+		{"4155552671", "41****2671"},
+		{"+12345", "**2345"},
+		{"1234", "****"},
+		{"12", "**"},
+		{"whatsapp:+14155552671", "wh***************2671"},
+	}
+	for _, tt := range tests {
+		if got := RedactDestination(tt.in); got != tt.want {
+			t.Errorf("RedactDestination(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactContent(t *testing.T) {
+	if got := RedactContent(""); got != "" {
+		t.Errorf("RedactContent(\"\") = %q", got)
+	}
+	if got := RedactContent("your code is 123456"); got != "[redacted 19 chars]" {
+		t.Errorf("RedactContent = %q", got)
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"connection refused", "connection refused"},
+		{"550 5.1.1 <jane@example.com>: mailbox unavailable", "550 5.1.1 <j***@example.com>: mailbox unavailable"},
+		{"The 'To' number +14155552671 is not a valid phone number.", "The 'To' number +14*****2671 is not a valid phone number."},
+		{"chat 4155552671 not found (code 400)", "chat 41****2671 not found (code 400)"},
+		{"status=503 after 123456 bytes", "status=503 after 123456 bytes"},
+	}
+	for _, tt := range tests {
+		if got := RedactText(tt.in); got != tt.want {
+			t.Errorf("RedactText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// logLine logs msg with args through a redacting logger and returns the
+// decoded JSON record.
+func logLine(t *testing.T, ctx context.Context, msg string, args ...any) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	NewWithWriter(&buf, confs.LogConfig{Level: "debug"}).InfoContext(ctx, msg, args...)
+	var out map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("log output %q: %v", buf.String(), err)
+	}
+	return out
+}
+
+func TestLoggerRedactsAttributes(t *testing.T) {
+	tests := []struct {
+		key, value, want string
+	}{
+		{"destination", "+14155552671", "+14*****2671"},
+		{"to", "jane@example.com", "j***@example.com"},
+		{"From", "+14155550100", "+14*****0100"},
+		{"email", "jane@example.com", "j***@example.com"},
+		{"phone", "+14155552671", "+14*****2671"},
+		{"content", "secret body", "[redacted 11 chars]"},
+		{"Body", "secret body", "[redacted 11 chars]"},
+		{"subject", "Password reset", "[redacted 14 chars]"},
+		{"message_id", "m-1", "m-1"},
+		{"provider", "twilio", "twilio"},
+	}
+	for _, tt := range tests {
+		out := logLine(t, context.Background(), "sent", tt.key, tt.value)
+		if got := out[tt.key]; got != tt.want {
+			t.Errorf("%s = %v, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerRedactsErrors(t *testing.T) {
+	err := errors.New("twilio error: The 'To' number +14155552671 is not valid")
+	tests := []struct {
+		name string
+		args []any
+		key  string
+	}{
+		{"error key", []any{"error", err.Error()}, "error"},
+		{"err key", []any{"err", err.Error()}, "err"},
+		{"error value under another key", []any{"cause", err}, "cause"},
+	}
+	for _, tt := range tests {
+		out := logLine(t, context.Background(), "send failed", tt.args...)
+		if got := out[tt.key]; got != "twilio error: The 'To' number +14*****2671 is not valid" {
+			t.Errorf("%s: %s = %v", tt.name, tt.key, got)
+		}
+	}
+}
+
+func TestLoggerRedactsInsideGroups(t *testing.T) {
+	out := logLine(t, context.Background(), "sent", slog.Group("message", "destination", "+14155552671", "content", "hi there"))
+	group, _ := out["message"].(map[string]any)
+	if group["destination"] != "+14*****2671" || group["content"] != "[redacted 8 chars]" {
+		t.Errorf("message group = %v", group)
+	}
+	if raw, _ := json.Marshal(out); strings.Contains(string(raw), "4155552671") {
+		t.Errorf("phone number leaked: %s", raw)
+	}
+}
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	out := logLine(t, WithRequestID(context.Background(), "req-42"), "hello")
+	if out["request_id"] != "req-42" {
+		t.Errorf("request_id = %v, want req-42", out["request_id"])
+	}
+	if _, ok := logLine(t, context.Background(), "hello")["request_id"]; ok {
+		t.Error("request_id logged without one in the context")
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewWithWriter(&buf, confs.LogConfig{Level: "warn"})
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("warn-level output = %q", buf.String())
+	}
+}
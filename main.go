@@ -1,26 +1,49 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
 
+	"messenger-module/cli"
 	"messenger-module/confs"
 	"messenger-module/db"
-	"messenger-module/server"
+	"messenger-module/logging"
+	"messenger-module/tracing"
 )
 
 func main() {
-	if err := confs.LoadConfig(); err != nil {
-		log.Fatalf("failed to load .env: %v", err)
+	cfg, err := confs.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
-	database, err := db.Connect()
+	logger := logging.New(cfg.Log)
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatalf("failed to connect db: %v", err)
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	database, err := db.Connect(cfg.Database)
+	if err != nil {
+		logger.Error("failed to connect db", "error", err)
+		os.Exit(1)
+	}
+	runErr := cli.Run(cfg, database, logger, os.Args[1:])
+	// Flush spans before the pool closes so trailing queries are exported.
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
+	// The server has stopped its workers by now, so the pool can go.
+	if err := database.Close(); err != nil {
+		logger.Error("failed to close db", "error", err)
 	}
-	port := os.Getenv("PORT")
-	if port == "" { port = "8080" }
-	srv := server.NewServer()
-	if err := srv.Start(database, port); err != nil {
-		log.Fatalf("server error: %v", err)
+	if runErr != nil {
+		fmt.Fprintln(os.Stderr, runErr)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,96 @@
+// Package metrics holds the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "messenger"
+
+// Registry is the registry every collector in this package is registered on.
+var Registry = prometheus.NewRegistry()
+
+var (
+	MessagesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_created_total",
+		Help:      "Messages stored after a send attempt was accepted by the provider.",
+	}, []string{"provider", "channel"})
+
+	MessagesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Provider send calls that succeeded.",
+	}, []string{"provider", "channel"})
+
+	MessagesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_failed_total",
+		Help:      "Provider send calls that returned an error.",
+	}, []string{"provider", "channel"})
+
+	WebhooksReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_received_total",
+		Help:      "Inbound provider webhook events received.",
+	}, []string{"provider"})
+
+	WebhooksProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_processed_total",
+		Help:      "Inbound provider webhook events recorded as a message status.",
+	}, []string{"provider"})
+
+	WebhooksUnmatched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_unmatched_total",
+		Help:      "Inbound provider webhook events whose external ID matched no message.",
+	}, []string{"provider"})
+
+	WebhooksRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_rejected_total",
+		Help:      "Inbound provider webhook requests rejected for a missing or invalid signature.",
+	}, []string{"provider"})
+
+	ProviderLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of provider send calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "outcome"})
+
+	HTTPLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of API requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// QueueDepth counts work waiting or in progress, labelled by queue.
+	// "outbound" is the number of provider sends currently in flight.
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Items waiting or in progress per queue.",
+	}, []string{"queue"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesCreated, MessagesSent, MessagesFailed,
+		WebhooksReceived, WebhooksProcessed, WebhooksUnmatched, WebhooksRejected,
+		ProviderLatency, HTTPLatency, QueueDepth,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
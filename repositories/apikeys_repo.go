@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"messenger-module/db"
+	"messenger-module/entities"
+)
+
+// APIKeys CRUD methods
+func (r *DBRepository) CreateAPIKey(ctx context.Context, in entities.APIKey) (entities.APIKey, error) {
+	m := toDBAPIKey(in)
+	if err := r.database.GetDB().WithContext(ctx).Create(&m).Error; err != nil {
+		return entities.APIKey{}, err
+	}
+	return toDomainAPIKey(m), nil
+}
+
+func (r *DBRepository) GetAPIKey(ctx context.Context, id string) (entities.APIKey, error) {
+	var m db.APIKeyModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.APIKey{}, err
+	}
+	return toDomainAPIKey(m), nil
+}
+
+func (r *DBRepository) GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, error) {
+	var m db.APIKeyModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "key_hash = ?", hash).Error; err != nil {
+		return entities.APIKey{}, err
+	}
+	return toDomainAPIKey(m), nil
+}
+
+func (r *DBRepository) ListAPIKeys(ctx context.Context, userID string) ([]entities.APIKey, error) {
+	var rows []db.APIKeyModel
+	if err := r.database.GetDB().WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.APIKey, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainAPIKey(m))
+	}
+	return out, nil
+}
+
+func (r *DBRepository) UpdateAPIKey(ctx context.Context, id string, in entities.APIKey) (entities.APIKey, error) {
+	var m db.APIKeyModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.APIKey{}, err
+	}
+	if in.Name != "" {
+		m.Name = in.Name
+	}
+	if in.LastUsedAt != nil {
+		if t, err := time.Parse(time.RFC3339, *in.LastUsedAt); err == nil {
+			m.LastUsedAt = &t
+		}
+	}
+	if in.RevokedAt != nil {
+		if t, err := time.Parse(time.RFC3339, *in.RevokedAt); err == nil {
+			m.RevokedAt = &t
+		}
+	}
+	if err := r.database.GetDB().WithContext(ctx).Save(&m).Error; err != nil {
+		return entities.APIKey{}, err
+	}
+	return toDomainAPIKey(m), nil
+}
+
+func (r *DBRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	res := r.database.GetDB().WithContext(ctx).Delete(&db.APIKeyModel{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("not found")
+	}
+	return nil
+}
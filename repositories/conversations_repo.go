@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"messenger-module/db"
+	"messenger-module/entities"
+
+	"gorm.io/gorm/clause"
+)
+
+// Conversations methods
+
+// TouchConversation returns the user's conversation between ourNumber and
+// theirNumber, creating it if needed, and marks it active at the given time.
+func (r *DBRepository) TouchConversation(ctx context.Context, in entities.Conversation, at time.Time) (entities.Conversation, error) {
+	m := db.ConversationModel{
+		UserID:        in.UserID,
+		IntegrationID: in.IntegrationID,
+		OurNumber:     in.OurNumber,
+		TheirNumber:   in.TheirNumber,
+		LastMessageAt: at,
+	}
+	err := r.database.GetDB().WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "our_number"}, {Name: "their_number"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"integration_id":  in.IntegrationID,
+			"last_message_at": at,
+			"updated_at":      at,
+		}),
+	}, clause.Returning{}).Create(&m).Error
+	if err != nil {
+		return entities.Conversation{}, err
+	}
+	return toDomainConversation(m), nil
+}
+
+func (r *DBRepository) GetConversation(ctx context.Context, id string) (entities.Conversation, error) {
+	var m db.ConversationModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.Conversation{}, err
+	}
+	return toDomainConversation(m), nil
+}
+
+// FindConversation returns the most recently active conversation between
+// ourNumber and theirNumber, whichever user it belongs to, or a zero
+// Conversation if there is none.
+func (r *DBRepository) FindConversation(ctx context.Context, ourNumber, theirNumber string) (entities.Conversation, error) {
+	var rows []db.ConversationModel
+	err := r.database.GetDB().WithContext(ctx).
+		Where("our_number = ? AND their_number = ?", ourNumber, theirNumber).
+		Order("last_message_at DESC").
+		Limit(1).
+		Find(&rows).Error
+	if err != nil {
+		return entities.Conversation{}, err
+	}
+	if len(rows) == 0 {
+		return entities.Conversation{}, nil
+	}
+	return toDomainConversation(rows[0]), nil
+}
+
+// FindNumberUser returns the user of the most recently active conversation
+// on ourNumber, with any number, or "" if there is none.
+func (r *DBRepository) FindNumberUser(ctx context.Context, ourNumber string) (string, error) {
+	var ids []string
+	err := r.database.GetDB().WithContext(ctx).Model(&db.ConversationModel{}).
+		Where("our_number = ?", ourNumber).
+		Order("last_message_at DESC").
+		Limit(1).
+		Pluck("user_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// ListConversations returns a user's conversations, most recently active first.
+func (r *DBRepository) ListConversations(ctx context.Context, userID string) ([]entities.Conversation, error) {
+	var rows []db.ConversationModel
+	if err := r.database.GetDB().WithContext(ctx).Where("user_id = ?", userID).Order("last_message_at DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.Conversation, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainConversation(m))
+	}
+	return out, nil
+}
+
+// ListConversationMessages returns a conversation's messages, oldest first.
+func (r *DBRepository) ListConversationMessages(ctx context.Context, conversationID string) ([]entities.Message, error) {
+	var rows []db.MessageModel
+	if err := r.database.GetDB().WithContext(ctx).Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.Message, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainMessage(m))
+	}
+	return out, nil
+}
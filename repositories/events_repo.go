@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+
+	"messenger-module/db"
+)
+
+// PublishStatusEvent broadcasts a status event to every instance listening
+// on db.StatusEventsChannel, including this one.
+func (r *DBRepository) PublishStatusEvent(ctx context.Context, payload string) error {
+	return db.Notify(ctx, r.database, db.StatusEventsChannel, payload)
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"messenger-module/db"
 	"messenger-module/entities"
@@ -25,9 +26,68 @@ func (r *DBRepository) GetIntegration(ctx context.Context, id string) (entities.
 	return toDomainIntegration(m), nil
 }
 
-func (r *DBRepository) ListIntegrations(ctx context.Context) ([]entities.Integration, error) {
+func (r *DBRepository) ListIntegrations(ctx context.Context, opts entities.ListOptions) ([]entities.Integration, error) {
 	var rows []db.IntegrationModel
-	if err := r.database.GetDB().WithContext(ctx).Find(&rows).Error; err != nil {
+	if err := r.query(ctx, opts).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.Integration, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainIntegration(m))
+	}
+	return out, nil
+}
+
+// GetDeletedIntegration returns a soft-deleted integration.
+func (r *DBRepository) GetDeletedIntegration(ctx context.Context, id string) (entities.Integration, error) {
+	var m db.IntegrationModel
+	if err := r.findDeleted(ctx, &m, id); err != nil {
+		return entities.Integration{}, err
+	}
+	return toDomainIntegration(m), nil
+}
+
+// ListIntegrationsByPlan returns the live integrations on planID.
+func (r *DBRepository) ListIntegrationsByPlan(ctx context.Context, planID string) ([]entities.Integration, error) {
+	var rows []db.IntegrationModel
+	if err := r.database.GetDB().WithContext(ctx).Where("plan_id = ?", planID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.Integration, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainIntegration(m))
+	}
+	return out, nil
+}
+
+// FindIntegrationByPhoneNumber returns the live integration that owns number,
+// the most recently updated one if several do, or a zero Integration if
+// none does.
+func (r *DBRepository) FindIntegrationByPhoneNumber(ctx context.Context, number string) (entities.Integration, error) {
+	var rows []db.IntegrationModel
+	err := r.database.GetDB().WithContext(ctx).
+		Where("phone_number = ?", number).
+		Order("updated_at DESC").
+		Limit(1).
+		Find(&rows).Error
+	if err != nil {
+		return entities.Integration{}, err
+	}
+	if len(rows) == 0 {
+		return entities.Integration{}, nil
+	}
+	return toDomainIntegration(rows[0]), nil
+}
+
+// ListIntegrationsByName returns the live integrations whose name matches
+// one of names, ignoring case.
+func (r *DBRepository) ListIntegrationsByName(ctx context.Context, names []string) ([]entities.Integration, error) {
+	lower := make([]string, 0, len(names))
+	for _, n := range names {
+		lower = append(lower, strings.ToLower(n))
+	}
+	var rows []db.IntegrationModel
+	if err := r.database.GetDB().WithContext(ctx).Where("LOWER(name) IN ?", lower).Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := make([]entities.Integration, 0, len(rows))
@@ -49,7 +109,22 @@ func (r *DBRepository) UpdateIntegration(ctx context.Context, id string, in enti
 		m.Type = in.Type
 	}
 	if in.PlanID != "" {
-		m.PlanID = in.PlanID
+		m.PlanID = &in.PlanID
+	}
+	if in.PhoneNumber != "" {
+		m.PhoneNumber = in.PhoneNumber
+	}
+	if in.BaseURL != "" {
+		m.BaseURL = in.BaseURL
+	}
+	if in.Token != "" {
+		m.APIKey = in.Token
+	}
+	if in.PayloadTemplate != "" {
+		m.PayloadTemplate = in.PayloadTemplate
+	}
+	if in.Headers != nil {
+		m.Headers = encodeHeaders(in.Headers)
 	}
 	if err := r.database.GetDB().WithContext(ctx).Save(&m).Error; err != nil {
 		return entities.Integration{}, err
@@ -67,3 +142,7 @@ func (r *DBRepository) DeleteIntegration(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+func (r *DBRepository) RestoreIntegration(ctx context.Context, id string) error {
+	return r.restore(ctx, &db.IntegrationModel{}, id)
+}
@@ -1,16 +1,20 @@
 package repositories
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"messenger-module/db"
 	"messenger-module/entities"
+
+	"gorm.io/gorm"
 )
 
 func toDomainUser(m db.UserModel) entities.User {
 	var del string
-	if m.DeletedAt != nil {
-		del = m.DeletedAt.Format(time.RFC3339)
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
 	}
 	return entities.User{
 		ID:        m.ID,
@@ -18,31 +22,106 @@ func toDomainUser(m db.UserModel) entities.User {
 		UpdatedAt: m.UpdatedAt.Format(time.RFC3339),
 		DeletedAt: del,
 		Name:      m.Name,
-		APIKey:    m.APIKey,
 		Active:    m.Active,
+
+		CallbackURL:    m.CallbackURL,
+		CallbackSecret: m.CallbackSecret,
 	}
 }
 
 func toDBUser(e entities.User) db.UserModel {
-	var del *time.Time
+	var del gorm.DeletedAt
 	if e.DeletedAt != "" {
 		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
-			del = &t
+			del = gorm.DeletedAt{Time: t, Valid: true}
 		}
 	}
 	return db.UserModel{
 		ID:        e.ID,
 		DeletedAt: del,
 		Name:      e.Name,
-		APIKey:    e.APIKey,
 		Active:    e.Active,
+
+		CallbackURL:    e.CallbackURL,
+		CallbackSecret: e.CallbackSecret,
+	}
+}
+
+func toDomainAPIKey(m db.APIKeyModel) entities.APIKey {
+	var del string
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
+	}
+	k := entities.APIKey{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: m.UpdatedAt.Format(time.RFC3339),
+		DeletedAt: del,
+		UserID:    m.UserID,
+		Name:      m.Name,
+		Prefix:    m.Prefix,
+		KeyHash:   m.KeyHash,
+		Scopes:    []string{},
+	}
+	if m.Scopes != "" {
+		k.Scopes = strings.Split(m.Scopes, ",")
+	}
+	if m.ExpiresAt != nil {
+		t := m.ExpiresAt.Format(time.RFC3339)
+		k.ExpiresAt = &t
+	}
+	if m.LastUsedAt != nil {
+		t := m.LastUsedAt.Format(time.RFC3339)
+		k.LastUsedAt = &t
+	}
+	if m.RevokedAt != nil {
+		t := m.RevokedAt.Format(time.RFC3339)
+		k.RevokedAt = &t
+	}
+	return k
+}
+
+func toDBAPIKey(e entities.APIKey) db.APIKeyModel {
+	var del gorm.DeletedAt
+	if e.DeletedAt != "" {
+		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
+			del = gorm.DeletedAt{Time: t, Valid: true}
+		}
+	}
+	var expires, lastUsed, revoked *time.Time
+	if e.ExpiresAt != nil {
+		if t, err := time.Parse(time.RFC3339, *e.ExpiresAt); err == nil {
+			expires = &t
+		}
+	}
+	if e.LastUsedAt != nil {
+		if t, err := time.Parse(time.RFC3339, *e.LastUsedAt); err == nil {
+			lastUsed = &t
+		}
+	}
+	if e.RevokedAt != nil {
+		if t, err := time.Parse(time.RFC3339, *e.RevokedAt); err == nil {
+			revoked = &t
+		}
+	}
+	return db.APIKeyModel{
+		ID:         e.ID,
+		DeletedAt:  del,
+		UserID:     e.UserID,
+		Name:       e.Name,
+		Prefix:     e.Prefix,
+		KeyHash:    e.KeyHash,
+		Scopes:     strings.Join(e.Scopes, ","),
+		ExpiresAt:  expires,
+		LastUsedAt: lastUsed,
+		RevokedAt:  revoked,
 	}
 }
 
 func toDomainPlan(m db.PlanModel) entities.Plan {
 	var del string
-	if m.DeletedAt != nil {
-		del = m.DeletedAt.Format(time.RFC3339)
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
 	}
 	return entities.Plan{
 		ID:         m.ID,
@@ -56,10 +135,10 @@ func toDomainPlan(m db.PlanModel) entities.Plan {
 }
 
 func toDBPlan(e entities.Plan) db.PlanModel {
-	var del *time.Time
+	var del gorm.DeletedAt
 	if e.DeletedAt != "" {
 		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
-			del = &t
+			del = gorm.DeletedAt{Time: t, Valid: true}
 		}
 	}
 	return db.PlanModel{
@@ -73,8 +152,8 @@ func toDBPlan(e entities.Plan) db.PlanModel {
 
 func toDomainUserPlan(m db.UserPlanModel) entities.UserPlan {
 	var del string
-	if m.DeletedAt != nil {
-		del = m.DeletedAt.Format(time.RFC3339)
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
 	}
 	return entities.UserPlan{
 		ID:        m.ID,
@@ -88,10 +167,10 @@ func toDomainUserPlan(m db.UserPlanModel) entities.UserPlan {
 }
 
 func toDBUserPlan(e entities.UserPlan) db.UserPlanModel {
-	var del *time.Time
+	var del gorm.DeletedAt
 	if e.DeletedAt != "" {
 		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
-			del = &t
+			del = gorm.DeletedAt{Time: t, Valid: true}
 		}
 	}
 	return db.UserPlanModel{
@@ -105,80 +184,166 @@ func toDBUserPlan(e entities.UserPlan) db.UserPlanModel {
 
 func toDomainIntegration(m db.IntegrationModel) entities.Integration {
 	var del string
-	if m.DeletedAt != nil {
-		del = m.DeletedAt.Format(time.RFC3339)
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
+	}
+	var planID string
+	if m.PlanID != nil {
+		planID = *m.PlanID
 	}
 	return entities.Integration{
-		ID:        m.ID,
-		CreatedAt: m.CreatedAt.Format(time.RFC3339),
-		UpdatedAt: m.UpdatedAt.Format(time.RFC3339),
-		DeletedAt: del,
-		Name:      m.Name,
-		Type:      m.Type,
-		PlanID:    m.PlanID,
+		ID:              m.ID,
+		CreatedAt:       m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:       m.UpdatedAt.Format(time.RFC3339),
+		DeletedAt:       del,
+		Name:            m.Name,
+		Type:            m.Type,
+		PlanID:          planID,
+		PhoneNumber:     m.PhoneNumber,
+		BaseURL:         m.BaseURL,
+		Token:           m.APIKey,
+		PayloadTemplate: m.PayloadTemplate,
+		Headers:         decodeHeaders(m.Headers),
 	}
 }
 
 func toDBIntegration(e entities.Integration) db.IntegrationModel {
-	var del *time.Time
+	var del gorm.DeletedAt
 	if e.DeletedAt != "" {
 		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
-			del = &t
+			del = gorm.DeletedAt{Time: t, Valid: true}
 		}
 	}
+	// An empty plan id is stored as NULL so it does not violate the foreign key
+	var planID *string
+	if e.PlanID != "" {
+		planID = &e.PlanID
+	}
 	return db.IntegrationModel{
-		ID:        e.ID,
-		DeletedAt: del,
-		Name:      e.Name,
-		Type:      e.Type,
-		PlanID:    e.PlanID,
+		ID:              e.ID,
+		DeletedAt:       del,
+		Name:            e.Name,
+		Type:            e.Type,
+		PlanID:          planID,
+		PhoneNumber:     e.PhoneNumber,
+		BaseURL:         e.BaseURL,
+		APIKey:          e.Token,
+		PayloadTemplate: e.PayloadTemplate,
+		Headers:         encodeHeaders(e.Headers),
 	}
 }
 
+// encodeHeaders stores a header map as a JSON object; nil is stored empty.
+func encodeHeaders(h map[string]string) string {
+	if len(h) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(h)
+	return string(b)
+}
+
+func decodeHeaders(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	var h map[string]string
+	_ = json.Unmarshal([]byte(s), &h)
+	return h
+}
+
+// encodeStrings stores a list as a JSON array; an empty list is stored empty.
+func encodeStrings(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+func decodeStrings(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var list []string
+	_ = json.Unmarshal([]byte(s), &list)
+	return list
+}
+
 func toDomainMessage(m db.MessageModel) entities.Message {
 	var del string
-	if m.DeletedAt != nil {
-		del = m.DeletedAt.Format(time.RFC3339)
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
+	}
+	var conversationID string
+	if m.ConversationID != nil {
+		conversationID = *m.ConversationID
 	}
 	return entities.Message{
-		ID:            m.ID,
-		CreatedAt:     m.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:     m.UpdatedAt.Format(time.RFC3339),
-		DeletedAt:     del,
-		Type:          m.Type,
-		Subject:       m.Subject,
-		Content:       m.Content,
-		Destination:   m.Destination,
-		ExternalID:    m.ExternalID,
-		UserID:        m.UserID,
-		IntegrationID: m.IntegrationID,
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      m.UpdatedAt.Format(time.RFC3339),
+		DeletedAt:      del,
+		Type:           m.Type,
+		Subject:        m.Subject,
+		Content:        m.Content,
+		HTMLContent:    m.HTMLContent,
+		Destination:    m.Destination,
+		ExternalID:     m.ExternalID,
+		TraceID:        m.TraceID,
+		CallbackURL:    m.CallbackURL,
+		UserID:         m.UserID,
+		IntegrationID:  m.IntegrationID,
+		Direction:      m.Direction,
+		From:           m.FromNumber,
+		ConversationID: conversationID,
+		MediaURLs:      decodeStrings(m.MediaURLs),
+		Encoding:       m.Encoding,
+		Segments:       m.Segments,
 	}
 }
 
 func toDBMessage(e entities.Message) db.MessageModel {
-	var del *time.Time
+	var del gorm.DeletedAt
 	if e.DeletedAt != "" {
 		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
-			del = &t
+			del = gorm.DeletedAt{Time: t, Valid: true}
 		}
 	}
+	// Messages created before direction existed, or without one, are outbound
+	direction := e.Direction
+	if direction == "" {
+		direction = entities.DirectionOutbound
+	}
+	var conversationID *string
+	if e.ConversationID != "" {
+		conversationID = &e.ConversationID
+	}
 	return db.MessageModel{
-		ID:            e.ID,
-		DeletedAt:     del,
-		Type:          e.Type,
-		Subject:       e.Subject,
-		Content:       e.Content,
-		Destination:   e.Destination,
-		ExternalID:    e.ExternalID,
-		UserID:        e.UserID,
-		IntegrationID: e.IntegrationID,
+		ID:             e.ID,
+		DeletedAt:      del,
+		Type:           e.Type,
+		Subject:        e.Subject,
+		Content:        e.Content,
+		HTMLContent:    e.HTMLContent,
+		Destination:    e.Destination,
+		ExternalID:     e.ExternalID,
+		TraceID:        e.TraceID,
+		CallbackURL:    e.CallbackURL,
+		UserID:         e.UserID,
+		IntegrationID:  e.IntegrationID,
+		Direction:      direction,
+		FromNumber:     e.From,
+		ConversationID: conversationID,
+		MediaURLs:      encodeStrings(e.MediaURLs),
+		Encoding:       e.Encoding,
+		Segments:       e.Segments,
 	}
 }
 
 func toDomainMessageStatus(m db.MessageStatusModel) entities.MessageStatus {
 	var del string
-	if m.DeletedAt != nil {
-		del = m.DeletedAt.Format(time.RFC3339)
+	if m.DeletedAt.Valid {
+		del = m.DeletedAt.Time.Format(time.RFC3339)
 	}
 	ms := entities.MessageStatus{
 		ID:              m.ID,
@@ -214,10 +379,10 @@ func toDomainMessageStatus(m db.MessageStatusModel) entities.MessageStatus {
 }
 
 func toDBMessageStatus(e entities.MessageStatus) db.MessageStatusModel {
-	var del *time.Time
+	var del gorm.DeletedAt
 	if e.DeletedAt != "" {
 		if t, err := time.Parse(time.RFC3339, e.DeletedAt); err == nil {
-			del = &t
+			del = gorm.DeletedAt{Time: t, Valid: true}
 		}
 	}
 	var sent, opened, errt, canceled, deferred *time.Time
@@ -260,3 +425,124 @@ func toDBMessageStatus(e entities.MessageStatus) db.MessageStatusModel {
 		DateDeferred:    deferred,
 	}
 }
+
+func toDomainWebhookDelivery(m db.WebhookDeliveryModel) entities.WebhookDelivery {
+	d := entities.WebhookDelivery{
+		ID:           m.ID,
+		CreatedAt:    m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:    m.UpdatedAt.Format(time.RFC3339),
+		UserID:       m.UserID,
+		MessageID:    m.MessageID,
+		URL:          m.URL,
+		Event:        m.Event,
+		Payload:      m.Payload,
+		State:        m.State,
+		Attempts:     m.Attempts,
+		ResponseCode: m.ResponseCode,
+		ResponseBody: m.ResponseBody,
+		LastError:    m.LastError,
+	}
+	if m.MessageStatusID != nil {
+		d.MessageStatusID = *m.MessageStatusID
+	}
+	if m.RedeliveryOf != nil {
+		d.RedeliveryOf = *m.RedeliveryOf
+	}
+	if m.NextAttemptAt != nil {
+		t := m.NextAttemptAt.Format(time.RFC3339)
+		d.NextAttemptAt = &t
+	}
+	if m.LastAttemptAt != nil {
+		t := m.LastAttemptAt.Format(time.RFC3339)
+		d.LastAttemptAt = &t
+	}
+	return d
+}
+
+func toDBWebhookDelivery(e entities.WebhookDelivery) db.WebhookDeliveryModel {
+	m := db.WebhookDeliveryModel{
+		ID:           e.ID,
+		UserID:       e.UserID,
+		MessageID:    e.MessageID,
+		URL:          e.URL,
+		Event:        e.Event,
+		Payload:      e.Payload,
+		State:        e.State,
+		Attempts:     e.Attempts,
+		ResponseCode: e.ResponseCode,
+		ResponseBody: e.ResponseBody,
+		LastError:    e.LastError,
+	}
+	if e.MessageStatusID != "" {
+		m.MessageStatusID = &e.MessageStatusID
+	}
+	if e.RedeliveryOf != "" {
+		m.RedeliveryOf = &e.RedeliveryOf
+	}
+	if e.NextAttemptAt != nil {
+		if t, err := time.Parse(time.RFC3339, *e.NextAttemptAt); err == nil {
+			m.NextAttemptAt = &t
+		}
+	}
+	if e.LastAttemptAt != nil {
+		if t, err := time.Parse(time.RFC3339, *e.LastAttemptAt); err == nil {
+			m.LastAttemptAt = &t
+		}
+	}
+	return m
+}
+
+func toDomainConversation(m db.ConversationModel) entities.Conversation {
+	return entities.Conversation{
+		ID:            m.ID,
+		CreatedAt:     m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     m.UpdatedAt.Format(time.RFC3339),
+		UserID:        m.UserID,
+		IntegrationID: m.IntegrationID,
+		OurNumber:     m.OurNumber,
+		TheirNumber:   m.TheirNumber,
+		LastMessageAt: m.LastMessageAt.Format(time.RFC3339),
+	}
+}
+
+func toDomainPushSubscription(m db.PushSubscriptionModel) entities.PushSubscription {
+	out := entities.PushSubscription{
+		ID:         m.ID,
+		CreatedAt:  m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  m.UpdatedAt.Format(time.RFC3339),
+		UserID:     m.UserID,
+		Subscriber: m.Subscriber,
+		Endpoint:   m.Endpoint,
+		Keys:       &entities.PushKeys{P256dh: m.P256dh, Auth: m.Auth},
+	}
+	if m.ExpiredAt != nil {
+		out.ExpiredAt = m.ExpiredAt.Format(time.RFC3339)
+	}
+	return out
+}
+
+func toDBPushSubscription(e entities.PushSubscription) db.PushSubscriptionModel {
+	m := db.PushSubscriptionModel{
+		ID:         e.ID,
+		UserID:     e.UserID,
+		Subscriber: e.Subscriber,
+		Endpoint:   e.Endpoint,
+	}
+	if e.Keys != nil {
+		m.P256dh = e.Keys.P256dh
+		m.Auth = e.Keys.Auth
+	}
+	return m
+}
+
+func toDomainMedia(m db.MediaModel) entities.Media {
+	return entities.Media{
+		ID:          m.ID,
+		CreatedAt:   m.CreatedAt.Format(time.RFC3339),
+		UserID:      m.UserID,
+		Filename:    m.Filename,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		Data:        m.Data,
+	}
+}
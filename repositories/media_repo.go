@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+
+	"messenger-module/db"
+	"messenger-module/entities"
+)
+
+// Media methods
+
+func (r *DBRepository) CreateMedia(ctx context.Context, in entities.Media) (entities.Media, error) {
+	m := db.MediaModel{
+		UserID:      in.UserID,
+		Filename:    in.Filename,
+		ContentType: in.ContentType,
+		Size:        int64(len(in.Data)),
+		Data:        in.Data,
+	}
+	if err := r.database.GetDB().WithContext(ctx).Create(&m).Error; err != nil {
+		return entities.Media{}, err
+	}
+	return toDomainMedia(m), nil
+}
+
+// GetMedia returns an uploaded file, including its content.
+func (r *DBRepository) GetMedia(ctx context.Context, id string) (entities.Media, error) {
+	var m db.MediaModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.Media{}, err
+	}
+	return toDomainMedia(m), nil
+}
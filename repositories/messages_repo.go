@@ -25,9 +25,34 @@ func (r *DBRepository) GetMessage(ctx context.Context, id string) (entities.Mess
 	return toDomainMessage(m), nil
 }
 
-func (r *DBRepository) ListMessages(ctx context.Context) ([]entities.Message, error) {
+// FindMessageByExternalID finds a message by its provider message ID. It
+// returns a zero Message if there is none.
+func (r *DBRepository) FindMessageByExternalID(ctx context.Context, externalID string) (entities.Message, error) {
 	var rows []db.MessageModel
-	if err := r.database.GetDB().WithContext(ctx).Find(&rows).Error; err != nil {
+	if err := r.database.GetDB().WithContext(ctx).Where("external_id = ?", externalID).Limit(1).Find(&rows).Error; err != nil {
+		return entities.Message{}, err
+	}
+	if len(rows) == 0 {
+		return entities.Message{}, nil
+	}
+	return toDomainMessage(rows[0]), nil
+}
+
+func (r *DBRepository) ListMessages(ctx context.Context, opts entities.ListOptions) ([]entities.Message, error) {
+	var rows []db.MessageModel
+	if err := r.query(ctx, opts).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.Message, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainMessage(m))
+	}
+	return out, nil
+}
+
+func (r *DBRepository) ListMessagesByUser(ctx context.Context, userID string, opts entities.ListOptions) ([]entities.Message, error) {
+	var rows []db.MessageModel
+	if err := r.query(ctx, opts).Where("user_id = ?", userID).Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := make([]entities.Message, 0, len(rows))
@@ -51,6 +76,9 @@ func (r *DBRepository) UpdateMessage(ctx context.Context, id string, in entities
 	if in.Content != "" {
 		m.Content = in.Content
 	}
+	if in.HTMLContent != "" {
+		m.HTMLContent = in.HTMLContent
+	}
 	if in.Destination != "" {
 		m.Destination = in.Destination
 	}
@@ -73,3 +101,16 @@ func (r *DBRepository) DeleteMessage(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// GetDeletedMessage returns a soft-deleted message.
+func (r *DBRepository) GetDeletedMessage(ctx context.Context, id string) (entities.Message, error) {
+	var m db.MessageModel
+	if err := r.findDeleted(ctx, &m, id); err != nil {
+		return entities.Message{}, err
+	}
+	return toDomainMessage(m), nil
+}
+
+func (r *DBRepository) RestoreMessage(ctx context.Context, id string) error {
+	return r.restore(ctx, &db.MessageModel{}, id)
+}
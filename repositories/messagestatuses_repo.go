@@ -26,9 +26,22 @@ func (r *DBRepository) GetMessageStatus(ctx context.Context, id string) (entitie
 	return toDomainMessageStatus(m), nil
 }
 
-func (r *DBRepository) ListMessageStatuses(ctx context.Context) ([]entities.MessageStatus, error) {
+func (r *DBRepository) ListMessageStatuses(ctx context.Context, opts entities.ListOptions) ([]entities.MessageStatus, error) {
 	var rows []db.MessageStatusModel
-	if err := r.database.GetDB().WithContext(ctx).Find(&rows).Error; err != nil {
+	if err := r.query(ctx, opts).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.MessageStatus, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainMessageStatus(m))
+	}
+	return out, nil
+}
+
+// ListMessageStatusesByMessage returns a message's statuses, oldest first.
+func (r *DBRepository) ListMessageStatusesByMessage(ctx context.Context, messageID string) ([]entities.MessageStatus, error) {
+	var rows []db.MessageStatusModel
+	if err := r.database.GetDB().WithContext(ctx).Where("message_id = ?", messageID).Order("created_at ASC").Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := make([]entities.MessageStatus, 0, len(rows))
@@ -91,3 +104,7 @@ func (r *DBRepository) DeleteMessageStatus(ctx context.Context, id string) error
 	}
 	return nil
 }
+
+func (r *DBRepository) RestoreMessageStatus(ctx context.Context, id string) error {
+	return r.restore(ctx, &db.MessageStatusModel{}, id)
+}
@@ -25,9 +25,9 @@ func (r *DBRepository) GetPlan(ctx context.Context, id string) (entities.Plan, e
 	return toDomainPlan(m), nil
 }
 
-func (r *DBRepository) ListPlans(ctx context.Context) ([]entities.Plan, error) {
+func (r *DBRepository) ListPlans(ctx context.Context, opts entities.ListOptions) ([]entities.Plan, error) {
 	var rows []db.PlanModel
-	if err := r.database.GetDB().WithContext(ctx).Find(&rows).Error; err != nil {
+	if err := r.query(ctx, opts).Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := make([]entities.Plan, 0, len(rows))
@@ -67,3 +67,7 @@ func (r *DBRepository) DeletePlan(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+func (r *DBRepository) RestorePlan(ctx context.Context, id string) error {
+	return r.restore(ctx, &db.PlanModel{}, id)
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"messenger-module/db"
+)
+
+// purgeStep purges one table. keep excludes rows that must stay because
+// removing them would cascade to, or be blocked by, rows still in use.
+type purgeStep struct {
+	model interface{}
+	keep  string
+}
+
+// liveChild matches rows of table, not soft-deleted, whose column points at
+// the row being purged.
+func liveChild(table, column, parent string) string {
+	return "NOT EXISTS (SELECT 1 FROM " + table + " c WHERE c." + column + " = " + parent + ".id AND c.deleted_at IS NULL)"
+}
+
+// PurgeDeleted permanently removes rows soft-deleted before cutoff, children
+// first, and returns the number of rows removed. A parent that still has
+// live children is kept, since its ON DELETE CASCADE foreign keys would
+// otherwise hard-delete rows that were never deleted. It is purged on a
+// later run, once its children are gone.
+func (r *DBRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	steps := []purgeStep{
+		{model: &db.WebhookDeliveryModel{}},
+		{model: &db.MessageStatusModel{}, keep: liveChild("webhook_deliveries", "message_status_id", "message_status_models")},
+		{model: &db.MessageModel{}, keep: liveChild("message_status_models", "message_id", "message_models") +
+			" AND " + liveChild("webhook_deliveries", "message_id", "message_models")},
+		{model: &db.APIKeyModel{}},
+		{model: &db.UserPlanModel{}},
+		{model: &db.IntegrationModel{}, keep: liveChild("message_models", "integration_id", "integration_models")},
+		// Plans are referenced with ON DELETE RESTRICT, so any reference,
+		// deleted or not, blocks them.
+		{model: &db.PlanModel{}, keep: "NOT EXISTS (SELECT 1 FROM user_plan_models c WHERE c.plan_id = plan_models.id)" +
+			" AND NOT EXISTS (SELECT 1 FROM integration_models c WHERE c.plan_id = plan_models.id)"},
+		{model: &db.UserModel{}, keep: liveChild("message_models", "user_id", "user_models") +
+			" AND " + liveChild("api_keys", "user_id", "user_models") +
+			" AND " + liveChild("user_plan_models", "user_id", "user_models") +
+			" AND " + liveChild("webhook_deliveries", "user_id", "user_models")},
+	}
+	var total int64
+	for _, s := range steps {
+		q := r.database.GetDB().WithContext(ctx).Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+		if s.keep != "" {
+			q = q.Where(s.keep)
+		}
+		res := q.Delete(s.model)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
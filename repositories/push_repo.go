@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"messenger-module/db"
+	"messenger-module/entities"
+
+	"gorm.io/gorm/clause"
+)
+
+// Web Push methods
+
+// vapidKeyID is the primary key of the single VAPID key pair row.
+const vapidKeyID = "default"
+
+// GetVAPIDKeys returns the stored key pair, or zero VAPIDKeys if none has
+// been generated yet.
+func (r *DBRepository) GetVAPIDKeys(ctx context.Context) (entities.VAPIDKeys, error) {
+	var rows []db.VAPIDKeyModel
+	if err := r.database.GetDB().WithContext(ctx).Where("id = ?", vapidKeyID).Limit(1).Find(&rows).Error; err != nil {
+		return entities.VAPIDKeys{}, err
+	}
+	if len(rows) == 0 {
+		return entities.VAPIDKeys{}, nil
+	}
+	return entities.VAPIDKeys{PublicKey: rows[0].PublicKey, PrivateKey: rows[0].PrivateKey}, nil
+}
+
+// CreateVAPIDKeys stores keys unless another instance stored a pair first,
+// and returns whichever pair is stored.
+func (r *DBRepository) CreateVAPIDKeys(ctx context.Context, keys entities.VAPIDKeys) (entities.VAPIDKeys, error) {
+	m := db.VAPIDKeyModel{ID: vapidKeyID, PublicKey: keys.PublicKey, PrivateKey: keys.PrivateKey}
+	if err := r.database.GetDB().WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&m).Error; err != nil {
+		return entities.VAPIDKeys{}, err
+	}
+	return r.GetVAPIDKeys(ctx)
+}
+
+// SavePushSubscription creates a subscription, or refreshes the keys and
+// subscriber of one already registered by the same user for that endpoint
+// and clears its expiry.
+func (r *DBRepository) SavePushSubscription(ctx context.Context, in entities.PushSubscription) (entities.PushSubscription, error) {
+	now := time.Now().UTC()
+	m := toDBPushSubscription(in)
+	err := r.database.GetDB().WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"subscriber": m.Subscriber,
+			"p256dh":     m.P256dh,
+			"auth":       m.Auth,
+			"expired_at": nil,
+			"updated_at": now,
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "push_subscriptions.user_id = excluded.user_id"}}},
+	}, clause.Returning{}).Create(&m).Error
+	if err != nil {
+		return entities.PushSubscription{}, err
+	}
+	if m.ID == "" {
+		return entities.PushSubscription{}, errors.New("endpoint is registered to another user")
+	}
+	return toDomainPushSubscription(m), nil
+}
+
+func (r *DBRepository) GetPushSubscription(ctx context.Context, id string) (entities.PushSubscription, error) {
+	var m db.PushSubscriptionModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.PushSubscription{}, err
+	}
+	return toDomainPushSubscription(m), nil
+}
+
+// ListPushSubscriptions returns a user's subscriptions, oldest first,
+// limited to one subscriber unless subscriber is empty. Expired
+// subscriptions are included.
+func (r *DBRepository) ListPushSubscriptions(ctx context.Context, userID, subscriber string) ([]entities.PushSubscription, error) {
+	q := r.database.GetDB().WithContext(ctx).Where("user_id = ?", userID)
+	if subscriber != "" {
+		q = q.Where("subscriber = ?", subscriber)
+	}
+	var rows []db.PushSubscriptionModel
+	if err := q.Order("created_at ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.PushSubscription, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainPushSubscription(m))
+	}
+	return out, nil
+}
+
+// ExpirePushSubscription marks a subscription gone, so it is no longer sent to.
+func (r *DBRepository) ExpirePushSubscription(ctx context.Context, id string, at time.Time) error {
+	return r.database.GetDB().WithContext(ctx).Model(&db.PushSubscriptionModel{}).
+		Where("id = ? AND expired_at IS NULL", id).
+		Updates(map[string]interface{}{"expired_at": at, "updated_at": at}).Error
+}
+
+func (r *DBRepository) DeletePushSubscription(ctx context.Context, id string) error {
+	res := r.database.GetDB().WithContext(ctx).Delete(&db.PushSubscriptionModel{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("not found")
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package repositories
 
 import (
+	"context"
+	"errors"
+
 	"messenger-module/db"
+	"messenger-module/entities"
+
+	"gorm.io/gorm"
 )
 
 type DBRepository struct {
@@ -11,3 +17,37 @@ type DBRepository struct {
 func NewDBRepository(database db.Database) *DBRepository {
 	return &DBRepository{database: database}
 }
+
+// query returns a session honouring opts.IncludeDeleted.
+func (r *DBRepository) query(ctx context.Context, opts entities.ListOptions) *gorm.DB {
+	q := r.database.GetDB().WithContext(ctx)
+	if opts.IncludeDeleted {
+		q = q.Unscoped()
+	}
+	return q
+}
+
+// findDeleted loads the soft-deleted row of dest's model with id, so its
+// references can be checked before it is restored.
+func (r *DBRepository) findDeleted(ctx context.Context, dest interface{}, id string) error {
+	err := r.database.GetDB().WithContext(ctx).Unscoped().
+		First(dest, "id = ? AND deleted_at IS NOT NULL", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("not found")
+	}
+	return err
+}
+
+// restore clears deleted_at on a soft-deleted row of model.
+func (r *DBRepository) restore(ctx context.Context, model interface{}, id string) error {
+	res := r.database.GetDB().WithContext(ctx).Unscoped().Model(model).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("not found")
+	}
+	return nil
+}
@@ -25,9 +25,31 @@ func (r *DBRepository) GetUserPlan(ctx context.Context, id string) (entities.Use
 	return toDomainUserPlan(m), nil
 }
 
-func (r *DBRepository) ListUserPlans(ctx context.Context) ([]entities.UserPlan, error) {
+func (r *DBRepository) ListUserPlans(ctx context.Context, opts entities.ListOptions) ([]entities.UserPlan, error) {
 	var rows []db.UserPlanModel
-	if err := r.database.GetDB().WithContext(ctx).Find(&rows).Error; err != nil {
+	if err := r.query(ctx, opts).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.UserPlan, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainUserPlan(m))
+	}
+	return out, nil
+}
+
+// GetDeletedUserPlan returns a soft-deleted user plan.
+func (r *DBRepository) GetDeletedUserPlan(ctx context.Context, id string) (entities.UserPlan, error) {
+	var m db.UserPlanModel
+	if err := r.findDeleted(ctx, &m, id); err != nil {
+		return entities.UserPlan{}, err
+	}
+	return toDomainUserPlan(m), nil
+}
+
+// ListUserPlansByPlan returns the live user plans on planID.
+func (r *DBRepository) ListUserPlansByPlan(ctx context.Context, planID string) ([]entities.UserPlan, error) {
+	var rows []db.UserPlanModel
+	if err := r.database.GetDB().WithContext(ctx).Where("plan_id = ?", planID).Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := make([]entities.UserPlan, 0, len(rows))
@@ -65,3 +87,7 @@ func (r *DBRepository) DeleteUserPlan(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+func (r *DBRepository) RestoreUserPlan(ctx context.Context, id string) error {
+	return r.restore(ctx, &db.UserPlanModel{}, id)
+}
@@ -3,9 +3,12 @@ package repositories
 import (
 	"context"
 	"errors"
+	"time"
 
 	"messenger-module/db"
 	"messenger-module/entities"
+
+	"gorm.io/gorm"
 )
 
 // Users CRUD methods
@@ -25,9 +28,9 @@ func (r *DBRepository) GetUser(ctx context.Context, id string) (entities.User, e
 	return toDomainUser(m), nil
 }
 
-func (r *DBRepository) ListUsers(ctx context.Context) ([]entities.User, error) {
+func (r *DBRepository) ListUsers(ctx context.Context, opts entities.ListOptions) ([]entities.User, error) {
 	var rows []db.UserModel
-	if err := r.database.GetDB().WithContext(ctx).Find(&rows).Error; err != nil {
+	if err := r.query(ctx, opts).Find(&rows).Error; err != nil {
 		return nil, err
 	}
 	out := make([]entities.User, 0, len(rows))
@@ -37,6 +40,33 @@ func (r *DBRepository) ListUsers(ctx context.Context) ([]entities.User, error) {
 	return out, nil
 }
 
+// userCreationLock is the advisory lock key CreateUserReportingFirst holds
+// while it checks for existing users.
+const userCreationLock = 0x6d6d5f7573657273 // "mm_users"
+
+// CreateUserReportingFirst checks for existing users and inserts in under a
+// transaction-scoped advisory lock, so a concurrent call waits for this one
+// to commit and then sees its user.
+func (r *DBRepository) CreateUserReportingFirst(ctx context.Context, in entities.User) (entities.User, bool, error) {
+	m := toDBUser(in)
+	first := false
+	err := r.database.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", userCreationLock).Error; err != nil {
+			return err
+		}
+		var ids []string
+		if err := tx.Unscoped().Model(&db.UserModel{}).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		first = len(ids) == 0
+		return tx.Create(&m).Error
+	})
+	if err != nil {
+		return entities.User{}, false, err
+	}
+	return toDomainUser(m), first, nil
+}
+
 func (r *DBRepository) UpdateUser(ctx context.Context, id string, in entities.User) (entities.User, error) {
 	var m db.UserModel
 	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
@@ -46,9 +76,6 @@ func (r *DBRepository) UpdateUser(ctx context.Context, id string, in entities.Us
 	if in.Name != "" {
 		m.Name = in.Name
 	}
-	if in.APIKey != "" {
-		m.APIKey = in.APIKey
-	}
 	m.Active = in.Active
 	if err := r.database.GetDB().WithContext(ctx).Save(&m).Error; err != nil {
 		return entities.User{}, err
@@ -56,13 +83,44 @@ func (r *DBRepository) UpdateUser(ctx context.Context, id string, in entities.Us
 	return toDomainUser(m), nil
 }
 
+// DeleteUser soft-deletes the user together with their API keys and user
+// plans in one transaction. The keys are also revoked, so restoring the user
+// does not bring them back into use.
 func (r *DBRepository) DeleteUser(ctx context.Context, id string) error {
-	res := r.database.GetDB().WithContext(ctx).Delete(&db.UserModel{}, "id = ?", id)
-	if res.Error != nil {
-		return res.Error
+	return r.database.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&db.UserModel{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("not found")
+		}
+		if err := tx.Model(&db.APIKeyModel{}).
+			Where("user_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&db.APIKeyModel{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&db.UserPlanModel{}, "user_id = ?", id).Error
+	})
+}
+
+func (r *DBRepository) RestoreUser(ctx context.Context, id string) error {
+	return r.restore(ctx, &db.UserModel{}, id)
+}
+
+// UpdateUserCallback sets the user's status callback URL and signing secret.
+func (r *DBRepository) UpdateUserCallback(ctx context.Context, id, url, secret string) (entities.User, error) {
+	var m db.UserModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.User{}, err
 	}
-	if res.RowsAffected == 0 {
-		return errors.New("not found")
+	m.CallbackURL = url
+	m.CallbackSecret = secret
+	if err := r.database.GetDB().WithContext(ctx).Save(&m).Error; err != nil {
+		return entities.User{}, err
 	}
-	return nil
+	return toDomainUser(m), nil
 }
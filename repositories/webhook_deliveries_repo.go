@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"messenger-module/db"
+	"messenger-module/entities"
+)
+
+// WebhookDeliveries methods
+func (r *DBRepository) CreateWebhookDelivery(ctx context.Context, in entities.WebhookDelivery) (entities.WebhookDelivery, error) {
+	m := toDBWebhookDelivery(in)
+	if err := r.database.GetDB().WithContext(ctx).Create(&m).Error; err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	return toDomainWebhookDelivery(m), nil
+}
+
+func (r *DBRepository) GetWebhookDelivery(ctx context.Context, id string) (entities.WebhookDelivery, error) {
+	var m db.WebhookDeliveryModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", id).Error; err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	return toDomainWebhookDelivery(m), nil
+}
+
+// ListWebhookDeliveries returns a user's deliveries, newest first, optionally
+// for one message.
+func (r *DBRepository) ListWebhookDeliveries(ctx context.Context, userID, messageID string) ([]entities.WebhookDelivery, error) {
+	q := r.database.GetDB().WithContext(ctx).Where("user_id = ?", userID)
+	if messageID != "" {
+		q = q.Where("message_id = ?", messageID)
+	}
+	var rows []db.WebhookDeliveryModel
+	if err := q.Order("created_at DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]entities.WebhookDelivery, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainWebhookDelivery(m))
+	}
+	return out, nil
+}
+
+// ClaimDueWebhookDeliveries leases up to limit pending deliveries that are
+// due, pushing their next attempt out by lease so other instances skip them
+// while this one sends.
+func (r *DBRepository) ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]entities.WebhookDelivery, error) {
+	var rows []db.WebhookDeliveryModel
+	err := r.database.GetDB().WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE state = ? AND deleted_at IS NULL AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), entities.DeliveryPending, now, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]entities.WebhookDelivery, 0, len(rows))
+	for _, m := range rows {
+		out = append(out, toDomainWebhookDelivery(m))
+	}
+	return out, nil
+}
+
+// UpdateWebhookDelivery saves the outcome of a delivery attempt.
+func (r *DBRepository) UpdateWebhookDelivery(ctx context.Context, in entities.WebhookDelivery) (entities.WebhookDelivery, error) {
+	var m db.WebhookDeliveryModel
+	if err := r.database.GetDB().WithContext(ctx).First(&m, "id = ?", in.ID).Error; err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	upd := toDBWebhookDelivery(in)
+	m.State = upd.State
+	m.Attempts = upd.Attempts
+	m.NextAttemptAt = upd.NextAttemptAt
+	m.LastAttemptAt = upd.LastAttemptAt
+	m.ResponseCode = upd.ResponseCode
+	m.ResponseBody = upd.ResponseBody
+	m.LastError = upd.LastError
+	if err := r.database.GetDB().WithContext(ctx).Save(&m).Error; err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	return toDomainWebhookDelivery(m), nil
+}
+
+func (r *DBRepository) CountPendingWebhookDeliveries(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.database.GetDB().WithContext(ctx).Model(&db.WebhookDeliveryModel{}).
+		Where("state = ?", entities.DeliveryPending).Count(&n).Error
+	return n, err
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"messenger-module/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// Worker is a named background loop that runs until its context is cancelled.
+type Worker struct {
+	Name string
+	Run  func(ctx context.Context)
+
+	running atomic.Bool
+}
+
+// start runs the worker, tracking whether it is alive. A worker that returns
+// or panics before shutdown is reported as stopped by the health checks.
+func (w *Worker) start(ctx context.Context, logger *slog.Logger) {
+	w.running.Store(true)
+	defer w.running.Store(false)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("worker panicked", "worker", w.Name, "panic", r)
+		}
+	}()
+	w.Run(ctx)
+}
+
+type check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) workerChecks() (map[string]check, bool) {
+	checks := make(map[string]check, len(s.workers))
+	healthy := true
+	for _, w := range s.workers {
+		if w.running.Load() {
+			checks[w.Name] = check{Status: "running"}
+		} else {
+			checks[w.Name] = check{Status: "stopped"}
+			healthy = false
+		}
+	}
+	return checks, healthy
+}
+
+// healthz is the liveness probe: the process is serving and its workers are alive.
+func (s *Server) healthz(c *gin.Context) {
+	workers, ok := s.workerChecks()
+	status, code := "ok", http.StatusOK
+	if !ok && s.ready.Load() {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "workers": workers})
+}
+
+// readyz is the readiness probe: the server is not shutting down, the
+// database answers, the schema is current and every worker is running.
+func (s *Server) readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	ready := s.ready.Load()
+	checks := gin.H{}
+
+	dbCheck := pingDatabase(ctx, s.database)
+	checks["database"] = dbCheck
+	ready = ready && dbCheck.Status == "ok"
+
+	migCheck := s.checkMigrations(ctx)
+	checks["migrations"] = migCheck
+	ready = ready && migCheck.Status == "ok"
+
+	workers, ok := s.workerChecks()
+	checks["workers"] = workers
+	ready = ready && ok
+
+	status, code := "ready", http.StatusOK
+	if !s.ready.Load() {
+		status, code = "shutting down", http.StatusServiceUnavailable
+	} else if !ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+func pingDatabase(ctx context.Context, database db.Database) check {
+	sqlDB, err := database.GetDB().DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		return check{Status: "error", Error: err.Error()}
+	}
+	return check{Status: "ok"}
+}
+
+// checkMigrations reports whether the schema is current. Migrations are
+// embedded in the binary, so once none are pending that stays true for the
+// life of the process and the database is not asked again.
+func (s *Server) checkMigrations(ctx context.Context) check {
+	if s.migrationsCurrent.Load() {
+		return check{Status: "ok"}
+	}
+	if s.migrator == nil {
+		return check{Status: "error", Error: "migrations could not be loaded"}
+	}
+	pending, err := s.migrator.CountPending(ctx)
+	if err != nil {
+		return check{Status: "error", Error: err.Error()}
+	}
+	if pending > 0 {
+		return check{Status: "pending", Error: fmt.Sprintf("%d migration(s) not applied", pending)}
+	}
+	s.migrationsCurrent.Store(true)
+	return check{Status: "ok"}
+}
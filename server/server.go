@@ -1,10 +1,19 @@
 package server
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"messenger-module/confs"
 	"messenger-module/db"
 	"messenger-module/handlers"
 	httphdl "messenger-module/handlers/http"
+	"messenger-module/metrics"
 	"messenger-module/repositories"
 	"messenger-module/usecases"
 
@@ -12,14 +21,94 @@ import (
 )
 
 type Server struct {
-	app *gin.Engine
+	app      *gin.Engine
+	cfg      *confs.Config
+	database db.Database
+	logger   *slog.Logger
+	ready    atomic.Bool
+	workers  []*Worker
+
+	// migrator and migrationsCurrent back the readiness migration check.
+	migrator          *db.Migrator
+	migrationsCurrent atomic.Bool
+
+	onShutdown []func()
 }
 
-func NewServer() *Server {
-	return &Server{app: gin.Default()}
+func NewServer(cfg *confs.Config, logger *slog.Logger) *Server {
+	if cfg.Env != "development" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	return &Server{app: gin.New(), cfg: cfg, logger: logger}
+}
+
+// Run serves until ctx is cancelled, then shuts down in order: readiness
+// reports unhealthy, in-flight requests drain, and background workers stop.
+// The caller owns the database and closes it after Run returns.
+func (s *Server) Run(ctx context.Context, database db.Database) error {
+	s.setup(database)
+
+	srv := &http.Server{Addr: ":" + s.cfg.Port, Handler: s.app}
+	for _, fn := range s.onShutdown {
+		srv.RegisterOnShutdown(fn)
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var wg sync.WaitGroup
+	for _, w := range s.workers {
+		wg.Add(1)
+		go func(w *Worker) {
+			defer wg.Done()
+			w.start(workerCtx, s.logger)
+		}(w)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	s.ready.Store(true)
+	s.logger.Info("server listening", "addr", srv.Addr)
+
+	select {
+	case err := <-serveErr:
+		s.ready.Store(false)
+		stopWorkers()
+		wg.Wait()
+		return err
+	case <-ctx.Done():
+	}
+
+	// Report not-ready first so load balancers stop routing new traffic here.
+	s.ready.Store(false)
+	s.logger.Info("shutdown: readiness off", "readiness_delay", s.cfg.Shutdown.ReadinessDelay.String())
+	time.Sleep(time.Duration(s.cfg.Shutdown.ReadinessDelay))
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.Shutdown.DrainTimeout))
+	defer cancel()
+	err := srv.Shutdown(drainCtx)
+	if err != nil {
+		s.logger.Warn("shutdown: drain incomplete", "drain_timeout", s.cfg.Shutdown.DrainTimeout.String(), "error", err)
+		srv.Close()
+	}
+	if serr := <-serveErr; serr != nil && !errors.Is(serr, http.ErrServerClosed) && err == nil {
+		err = serr
+	}
+
+	stopWorkers()
+	wg.Wait()
+	s.logger.Info("shutdown: complete")
+	return err
 }
 
-func (s *Server) Start(database db.Database, port string) error {
+func (s *Server) setup(database db.Database) {
+	s.database = database
+	if migrator, err := db.NewMigrator(database); err != nil {
+		s.logger.Error("failed to load migrations", "error", err)
+	} else {
+		s.migrator = migrator
+	}
 
 	// Disable automatic redirects to avoid 307 responses
 	s.app.RedirectFixedPath = false
@@ -27,47 +116,79 @@ func (s *Server) Start(database db.Database, port string) error {
 	s.app.HandleMethodNotAllowed = true
 
 	s.app.Static("/examples", "./examples")
+	s.app.Use(httphdl.Recovery(s.logger), httphdl.RequestID(), httphdl.Tracing(), httphdl.RequestLogger(s.logger), httphdl.Metrics())
+	s.app.GET("/metrics", gin.WrapH(metrics.Handler()))
+	s.app.GET("/healthz", s.healthz)
+	s.app.GET("/readyz", s.readyz)
+
 	// repositories and usecases
 	repo := repositories.NewDBRepository(database)
-	userUC := usecases.NewUserUsecase(repo)
-	planUC := usecases.NewPlanUsecase(repo)
-	userPlanUC := usecases.NewUserPlanUsecase(repo)
-	integrationUC := usecases.NewIntegrationUsecase(repo)
-	messageUC := usecases.NewMessageUsecase(repo)
-	messageStatusUC := usecases.NewMessageStatusUsecase(repo)
+	factory := handlers.NewMessageHandlerFactory(s.cfg)
+	uc := usecases.NewSet(repo, factory, s.cfg, s.logger)
+	auth := httphdl.NewAuthenticator(uc.APIKeys)
+
+	// background jobs
+	s.workers = append(s.workers,
+		&Worker{Name: "purge", Run: usecases.NewPurgeJob(repo, s.cfg.SoftDeleteRetention(), time.Hour, s.logger).Run},
+		&Worker{Name: "status-callbacks", Run: uc.StatusCallbacks.Run},
+		&Worker{Name: "status-events", Run: func(ctx context.Context) {
+			db.NewListener(s.cfg.Database, db.StatusEventsChannel, s.logger).Run(ctx, uc.StatusFeed.Receive)
+		}},
+	)
+	// Open streams never go idle on their own, so end them when draining starts.
+	s.onShutdown = append(s.onShutdown, uc.StatusFeed.Close)
 
 	// routes
 	api := s.app.Group("/api/v1")
 
 	users := api.Group("/users/")
-	httphdl.NewUserHandler(userUC, planUC, userPlanUC).Register(users)
+	httphdl.NewUserHandler(uc.Users, uc.Registration, auth).Register(users)
+
+	apiKeys := api.Group("/api-keys/")
+	httphdl.NewAPIKeyHandler(uc.APIKeys, auth).Register(apiKeys)
 
 	plans := api.Group("/plans/")
-	httphdl.NewPlanHandler(planUC).Register(plans)
+	httphdl.NewPlanHandler(uc.Plans, auth).Register(plans)
 
 	userplans := api.Group("/user-plans/")
-	httphdl.NewUserPlanHandler(userPlanUC).Register(userplans)
+	httphdl.NewUserPlanHandler(uc.UserPlans, auth).Register(userplans)
 
 	integrations := api.Group("/integrations/")
-	httphdl.NewIntegrationHandler(integrationUC).Register(integrations)
+	httphdl.NewIntegrationHandler(uc.Integrations, auth).Register(integrations)
 
 	messages := api.Group("/messages/")
-	httphdl.NewMessageHandler(messageUC).Register(messages)
+	httphdl.NewMessageHandler(uc.Messages, uc.StatusFeed, auth).Register(messages)
+
+	providers := api.Group("/providers/")
+	httphdl.NewProviderHandler(factory, uc.Integrations, auth).Register(providers)
+
+	callback := api.Group("/callback/")
+	httphdl.NewCallbackHandler(uc.StatusCallbacks, auth).Register(callback)
+
+	deliveries := api.Group("/webhook-deliveries/")
+	httphdl.NewWebhookDeliveryHandler(uc.StatusCallbacks, auth).Register(deliveries)
+
+	conversations := api.Group("/conversations/")
+	httphdl.NewConversationHandler(uc.Conversations, auth).Register(conversations)
+
+	webpush := api.Group("/webpush/")
+	httphdl.NewWebPushHandler(uc.WebPush, auth).Register(webpush)
+
+	media := api.Group("/media/")
+	httphdl.NewMediaHandler(uc.Media, auth).Register(media)
 
 	statuses := api.Group("/message-statuses/")
-	httphdl.NewMessageStatusHandler(messageStatusUC).Register(statuses)
+	httphdl.NewMessageStatusHandler(uc.MessageStatuses, auth).Register(statuses)
 
 	// webhooks
 	webhooks := api.Group("/webhooks/")
-	httphdl.NewWebhookHandler(messageUC, messageStatusUC).Register(webhooks)
+	httphdl.NewWebhookHandler(uc.Messages, uc.MessageStatuses, uc.Conversations, s.cfg.Twilio.AuthToken, s.cfg.WebhookBaseURL, s.logger).Register(webhooks)
 
 	// emails via SendGrid
-	if sg, err := handlers.NewSendGridHandler(); err != nil {
-		log.Printf("sendgrid disabled: %v", err)
+	if sg, err := handlers.NewSendGridHandler(s.cfg.SendGrid); err != nil {
+		s.logger.Info("sendgrid disabled", "reason", err)
 	} else {
 		emails := api.Group("/emails/")
-		httphdl.NewSendGridHTTPHandler(sg).Register(emails)
+		httphdl.NewSendGridHTTPHandler(sg, auth).Register(emails)
 	}
-
-	return s.app.Run(":" + port)
 }
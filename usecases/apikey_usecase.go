@@ -0,0 +1,240 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"messenger-module/entities"
+)
+
+// apiKeyTokenPrefix marks secrets issued by this service so they are easy to
+// spot in logs and secret scanners.
+const apiKeyTokenPrefix = "mm_"
+
+// apiKeyVisiblePrefixLen is how much of the secret is stored in clear text so
+// users can tell their keys apart.
+const apiKeyVisiblePrefixLen = len(apiKeyTokenPrefix) + 8
+
+// apiKeyTouchInterval is how stale LastUsedAt may get before a successful
+// authentication writes it again.
+const apiKeyTouchInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("invalid or expired api key")
+
+// ErrScopeNotGranted is returned when a key tries to create a key with a
+// scope it does not have itself.
+var ErrScopeNotGranted = errors.New("api key cannot grant scope")
+
+// APIKeyUsecaseRepo combines all repositories needed by APIKeyUsecase
+type APIKeyUsecaseRepo interface {
+	APIKeyRepo
+	UserRepo
+}
+
+type APIKeyUsecase struct{ repo APIKeyUsecaseRepo }
+
+func NewAPIKeyUsecase(repo APIKeyUsecaseRepo) *APIKeyUsecase { return &APIKeyUsecase{repo: repo} }
+
+// Create issues a new key for in.UserID. The returned key carries the
+// plaintext Secret; only its SHA-256 hash is persisted.
+func (u *APIKeyUsecase) Create(ctx context.Context, in entities.APIKey) (entities.APIKey, error) {
+	if in.UserID == "" {
+		return entities.APIKey{}, errors.New("user_id is required")
+	}
+	if _, err := u.repo.GetUser(ctx, in.UserID); err != nil {
+		return entities.APIKey{}, fmt.Errorf("user not found: %w", err)
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return entities.APIKey{}, errors.New("name is required")
+	}
+	scopes, err := normalizeScopes(in.Scopes)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	if in.ExpiresAt != nil {
+		t, err := time.Parse(time.RFC3339, *in.ExpiresAt)
+		if err != nil {
+			return entities.APIKey{}, errors.New("expires_at must be RFC3339")
+		}
+		if !t.After(time.Now()) {
+			return entities.APIKey{}, errors.New("expires_at must be in the future")
+		}
+	}
+
+	secret, err := generateAPIKeySecret()
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+
+	key := entities.APIKey{
+		UserID:    in.UserID,
+		Name:      in.Name,
+		Prefix:    secret[:apiKeyVisiblePrefixLen],
+		KeyHash:   HashAPIKey(secret),
+		Scopes:    scopes,
+		ExpiresAt: in.ExpiresAt,
+	}
+	created, err := u.repo.CreateAPIKey(ctx, key)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	created.Secret = secret
+	return created, nil
+}
+
+// CreateByKey issues a new key for the owner of caller. The new key may not
+// grant a scope caller lacks, so a read-only key cannot mint a send or admin
+// key.
+func (u *APIKeyUsecase) CreateByKey(ctx context.Context, caller entities.APIKey, in entities.APIKey) (entities.APIKey, error) {
+	scopes, err := normalizeScopes(in.Scopes)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	for _, s := range scopes {
+		if !caller.HasScope(s) {
+			return entities.APIKey{}, fmt.Errorf("%w: %s", ErrScopeNotGranted, s)
+		}
+	}
+	in.UserID = caller.UserID
+	in.Scopes = scopes
+	return u.Create(ctx, in)
+}
+
+func (u *APIKeyUsecase) Get(ctx context.Context, id string) (entities.APIKey, error) {
+	return u.repo.GetAPIKey(ctx, id)
+}
+
+func (u *APIKeyUsecase) List(ctx context.Context, userID string) ([]entities.APIKey, error) {
+	return u.repo.ListAPIKeys(ctx, userID)
+}
+
+// Revoke marks the key as revoked. Revoked keys are kept for auditing.
+func (u *APIKeyUsecase) Revoke(ctx context.Context, userID, id string) (entities.APIKey, error) {
+	key, err := u.getOwned(ctx, userID, id)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	return u.repo.UpdateAPIKey(ctx, id, entities.APIKey{RevokedAt: &now})
+}
+
+// Rotate issues a replacement key with the same name, scopes and expiry and
+// revokes the old one.
+func (u *APIKeyUsecase) Rotate(ctx context.Context, userID, id string) (entities.APIKey, error) {
+	old, err := u.getOwned(ctx, userID, id)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	if old.RevokedAt != nil {
+		return entities.APIKey{}, errors.New("api key is already revoked")
+	}
+	fresh, err := u.Create(ctx, entities.APIKey{
+		UserID:    old.UserID,
+		Name:      old.Name,
+		Scopes:    old.Scopes,
+		ExpiresAt: old.ExpiresAt,
+	})
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	if _, err := u.Revoke(ctx, userID, id); err != nil {
+		return entities.APIKey{}, fmt.Errorf("failed to revoke old key: %w", err)
+	}
+	return fresh, nil
+}
+
+// Authenticate resolves a plaintext secret to its key, rejecting revoked and
+// expired keys and keys of inactive users. LastUsedAt is refreshed on success,
+// at most once per apiKeyTouchInterval so busy keys do not write on every
+// request.
+func (u *APIKeyUsecase) Authenticate(ctx context.Context, secret string) (entities.APIKey, error) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return entities.APIKey{}, ErrInvalidAPIKey
+	}
+	key, err := u.repo.GetAPIKeyByHash(ctx, HashAPIKey(secret))
+	if err != nil {
+		return entities.APIKey{}, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil {
+		return entities.APIKey{}, ErrInvalidAPIKey
+	}
+	if key.ExpiresAt != nil {
+		if t, err := time.Parse(time.RFC3339, *key.ExpiresAt); err == nil && !t.After(time.Now()) {
+			return entities.APIKey{}, ErrInvalidAPIKey
+		}
+	}
+	user, err := u.repo.GetUser(ctx, key.UserID)
+	if err != nil || !user.Active {
+		return entities.APIKey{}, ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if key.LastUsedAt != nil {
+		if t, err := time.Parse(time.RFC3339, *key.LastUsedAt); err == nil && now.Sub(t) < apiKeyTouchInterval {
+			return key, nil
+		}
+	}
+	stamp := now.Format(time.RFC3339)
+	if updated, err := u.repo.UpdateAPIKey(ctx, key.ID, entities.APIKey{LastUsedAt: &stamp}); err == nil {
+		key = updated
+	}
+	return key, nil
+}
+
+func (u *APIKeyUsecase) getOwned(ctx context.Context, userID, id string) (entities.APIKey, error) {
+	key, err := u.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return entities.APIKey{}, err
+	}
+	if key.UserID != userID {
+		return entities.APIKey{}, errors.New("not found")
+	}
+	return key, nil
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 of a plaintext key.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKeySecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return apiKeyTokenPrefix + hex.EncodeToString(buf), nil
+}
+
+// normalizeScopes validates and de-duplicates scopes, defaulting to send+read.
+func normalizeScopes(in []string) ([]string, error) {
+	if len(in) == 0 {
+		return []string{entities.APIKeyScopeSend, entities.APIKeyScopeRead}, nil
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case entities.APIKeyScopeSend, entities.APIKeyScopeRead, entities.APIKeyScopeAdmin:
+		default:
+			return nil, fmt.Errorf("unknown scope: %s", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
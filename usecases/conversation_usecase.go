@@ -0,0 +1,196 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"messenger-module/entities"
+	"messenger-module/handlers"
+)
+
+// ErrNoConversation is returned for inbound SMS that neither replies to a
+// conversation nor arrives on a number an integration owns with a single
+// user behind it, so there is nobody to attribute it to.
+var ErrNoConversation = errors.New("no conversation for these numbers")
+
+// ConversationUsecaseRepo combines the repositories needed by ConversationUsecase.
+type ConversationUsecaseRepo interface {
+	ConversationRepo
+	MessageRepo
+	IntegrationRepo
+	UserPlanRepo
+}
+
+// InboundMessage is an SMS received on one of our numbers.
+type InboundMessage struct {
+	ExternalID string // provider message ID
+	From       string // their number
+	To         string // our number
+	Body       string
+	MediaURLs  []string // MMS media, hosted by the provider
+}
+
+type ConversationUsecase struct {
+	repo     ConversationUsecaseRepo
+	statuses *MessageStatusUsecase
+	logger   *slog.Logger
+}
+
+func NewConversationUsecase(repo ConversationUsecaseRepo, statuses *MessageStatusUsecase, logger *slog.Logger) *ConversationUsecase {
+	return &ConversationUsecase{repo: repo, statuses: statuses, logger: logger}
+}
+
+// Thread attaches an outbound SMS to the conversation between its sender and
+// destination numbers, starting one if needed.
+func (u *ConversationUsecase) Thread(ctx context.Context, msg entities.Message) (entities.Message, error) {
+	if msg.From == "" || msg.Destination == "" {
+		return msg, nil
+	}
+	conv, err := u.repo.TouchConversation(ctx, entities.Conversation{
+		UserID:        msg.UserID,
+		IntegrationID: msg.IntegrationID,
+		OurNumber:     msg.From,
+		TheirNumber:   msg.Destination,
+	}, time.Now())
+	if err != nil {
+		return msg, fmt.Errorf("failed to thread message: %w", err)
+	}
+	msg.ConversationID = conv.ID
+	return msg, nil
+}
+
+// Receive stores an inbound SMS on the conversation it replies to, or starts
+// one when the sender texts one of our numbers first. Provider retries of the
+// same message return the stored copy.
+func (u *ConversationUsecase) Receive(ctx context.Context, in InboundMessage) (entities.Message, error) {
+	existing, err := u.repo.FindMessageByExternalID(ctx, in.ExternalID)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	if existing.ID != "" {
+		return existing, nil
+	}
+
+	conv, err := u.repo.FindConversation(ctx, in.To, in.From)
+	if err != nil {
+		return entities.Message{}, err
+	}
+	if conv.ID == "" {
+		if conv, err = u.start(ctx, in); err != nil {
+			return entities.Message{}, err
+		}
+	}
+
+	inbound := entities.Message{
+		UserID:         conv.UserID,
+		IntegrationID:  conv.IntegrationID,
+		Type:           "sms",
+		Direction:      entities.DirectionInbound,
+		Content:        in.Body,
+		Destination:    in.To,
+		From:           in.From,
+		ExternalID:     in.ExternalID,
+		ConversationID: conv.ID,
+		MediaURLs:      in.MediaURLs,
+	}
+	if len(in.MediaURLs) == 0 {
+		seg := handlers.CountSegments(in.Body)
+		inbound.Encoding = seg.Encoding
+		inbound.Segments = seg.Segments
+	}
+	msg, err := u.repo.CreateMessage(ctx, inbound)
+	if err != nil {
+		return entities.Message{}, fmt.Errorf("failed to store inbound message: %w", err)
+	}
+	if _, err := u.repo.TouchConversation(ctx, conv, time.Now()); err != nil {
+		u.logger.WarnContext(ctx, "failed to update conversation", "conversation_id", conv.ID, "error", err)
+	}
+
+	// Recording a status lets callbacks and live streams announce the reply.
+	if _, err := u.statuses.Create(ctx, entities.MessageStatus{
+		MessageID:  msg.ID,
+		ExternalID: in.ExternalID,
+		Status:     "received",
+	}); err != nil {
+		u.logger.WarnContext(ctx, "failed to record received status", "message_id", msg.ID, "error", err)
+	}
+	u.logger.InfoContext(ctx, "inbound message received",
+		"message_id", msg.ID, "conversation_id", conv.ID, "from", in.From, "to", in.To)
+	return msg, nil
+}
+
+// start opens a conversation for a first message from in.From, attributed
+// to the integration that owns in.To. The user is whoever last texted from
+// that number, or else the only active user on the integration's plan.
+func (u *ConversationUsecase) start(ctx context.Context, in InboundMessage) (entities.Conversation, error) {
+	integ, err := u.repo.FindIntegrationByPhoneNumber(ctx, in.To)
+	if err != nil {
+		return entities.Conversation{}, err
+	}
+	if integ.ID == "" {
+		return entities.Conversation{}, ErrNoConversation
+	}
+	userID, err := u.repo.FindNumberUser(ctx, in.To)
+	if err != nil {
+		return entities.Conversation{}, err
+	}
+	if userID == "" && integ.PlanID != "" {
+		if userID, err = u.planUser(ctx, integ.PlanID); err != nil {
+			return entities.Conversation{}, err
+		}
+	}
+	if userID == "" {
+		return entities.Conversation{}, ErrNoConversation
+	}
+	conv, err := u.repo.TouchConversation(ctx, entities.Conversation{
+		UserID:        userID,
+		IntegrationID: integ.ID,
+		OurNumber:     in.To,
+		TheirNumber:   in.From,
+	}, time.Now())
+	if err != nil {
+		return entities.Conversation{}, fmt.Errorf("failed to start conversation: %w", err)
+	}
+	return conv, nil
+}
+
+// planUser returns the only user with an active subscription to planID, or
+// "" if there are none or several.
+func (u *ConversationUsecase) planUser(ctx context.Context, planID string) (string, error) {
+	ups, err := u.repo.ListUserPlansByPlan(ctx, planID)
+	if err != nil {
+		return "", err
+	}
+	userID := ""
+	for _, up := range ups {
+		if !up.Active || up.UserID == userID {
+			continue
+		}
+		if userID != "" {
+			return "", nil
+		}
+		userID = up.UserID
+	}
+	return userID, nil
+}
+
+// List returns the user's conversations, most recently active first.
+func (u *ConversationUsecase) List(ctx context.Context, userID string) ([]entities.Conversation, error) {
+	return u.repo.ListConversations(ctx, userID)
+}
+
+// Messages returns a conversation's messages, oldest first. Conversations of
+// other users are reported as not found.
+func (u *ConversationUsecase) Messages(ctx context.Context, userID, id string) ([]entities.Message, error) {
+	conv, err := u.repo.GetConversation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if conv.UserID != userID {
+		return nil, errors.New("not found")
+	}
+	return u.repo.ListConversationMessages(ctx, id)
+}
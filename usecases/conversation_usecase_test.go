@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"messenger-module/entities"
+)
+
+// inboundRepo holds conversations, integrations and user plans in memory.
+// Methods the tests do not need fall through to the nil embedded interfaces
+// and panic.
+type inboundRepo struct {
+	ConversationUsecaseRepo
+	MessageStatusRepo
+	conversations []entities.Conversation
+	integrations  []entities.Integration
+	userPlans     []entities.UserPlan
+	messages      []entities.Message
+}
+
+func (r *inboundRepo) FindMessageByExternalID(_ context.Context, externalID string) (entities.Message, error) {
+	for _, m := range r.messages {
+		if m.ExternalID == externalID {
+			return m, nil
+		}
+	}
+	return entities.Message{}, nil
+}
+
+func (r *inboundRepo) FindConversation(_ context.Context, ourNumber, theirNumber string) (entities.Conversation, error) {
+	for _, c := range r.conversations {
+		if c.OurNumber == ourNumber && c.TheirNumber == theirNumber {
+			return c, nil
+		}
+	}
+	return entities.Conversation{}, nil
+}
+
+func (r *inboundRepo) FindNumberUser(_ context.Context, ourNumber string) (string, error) {
+	for _, c := range r.conversations {
+		if c.OurNumber == ourNumber {
+			return c.UserID, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *inboundRepo) FindIntegrationByPhoneNumber(_ context.Context, number string) (entities.Integration, error) {
+	for _, i := range r.integrations {
+		if i.PhoneNumber == number {
+			return i, nil
+		}
+	}
+	return entities.Integration{}, nil
+}
+
+func (r *inboundRepo) ListUserPlansByPlan(_ context.Context, planID string) ([]entities.UserPlan, error) {
+	var out []entities.UserPlan
+	for _, up := range r.userPlans {
+		if up.PlanID == planID {
+			out = append(out, up)
+		}
+	}
+	return out, nil
+}
+
+func (r *inboundRepo) TouchConversation(_ context.Context, in entities.Conversation, _ time.Time) (entities.Conversation, error) {
+	for _, c := range r.conversations {
+		if c.UserID == in.UserID && c.OurNumber == in.OurNumber && c.TheirNumber == in.TheirNumber {
+			return c, nil
+		}
+	}
+	in.ID = "conv-" + in.TheirNumber
+	r.conversations = append(r.conversations, in)
+	return in, nil
+}
+
+func (r *inboundRepo) CreateMessage(_ context.Context, in entities.Message) (entities.Message, error) {
+	in.ID = "msg-" + in.ExternalID
+	r.messages = append(r.messages, in)
+	return in, nil
+}
+
+func (r *inboundRepo) CreateMessageStatus(_ context.Context, in entities.MessageStatus) (entities.MessageStatus, error) {
+	return in, nil
+}
+
+func TestReceive(t *testing.T) {
+	const ours, theirs = "+14155550100", "+14155550199"
+	integ := entities.Integration{ID: "twilio", PhoneNumber: ours, PlanID: "pro"}
+
+	tests := []struct {
+		name     string
+		repo     inboundRepo
+		wantUser string
+		wantErr  error
+	}{
+		{
+			name: "reply to a conversation",
+			repo: inboundRepo{
+				integrations:  []entities.Integration{integ},
+				conversations: []entities.Conversation{{ID: "c1", UserID: "u1", IntegrationID: "twilio", OurNumber: ours, TheirNumber: theirs}},
+			},
+			wantUser: "u1",
+		},
+		{
+			name: "first message goes to the number's last user",
+			repo: inboundRepo{
+				integrations:  []entities.Integration{integ},
+				conversations: []entities.Conversation{{ID: "c1", UserID: "u2", IntegrationID: "twilio", OurNumber: ours, TheirNumber: "+14155550111"}},
+				userPlans:     []entities.UserPlan{{UserID: "u1", PlanID: "pro", Active: true}},
+			},
+			wantUser: "u2",
+		},
+		{
+			name: "first message goes to the plan's only user",
+			repo: inboundRepo{
+				integrations: []entities.Integration{integ},
+				userPlans: []entities.UserPlan{
+					{UserID: "u1", PlanID: "pro", Active: true},
+					{UserID: "u3", PlanID: "pro", Active: false},
+					{UserID: "u4", PlanID: "free", Active: true},
+				},
+			},
+			wantUser: "u1",
+		},
+		{
+			name: "plan with several users",
+			repo: inboundRepo{
+				integrations: []entities.Integration{integ},
+				userPlans: []entities.UserPlan{
+					{UserID: "u1", PlanID: "pro", Active: true},
+					{UserID: "u2", PlanID: "pro", Active: true},
+				},
+			},
+			wantErr: ErrNoConversation,
+		},
+		{
+			name:    "number no integration owns",
+			repo:    inboundRepo{userPlans: []entities.UserPlan{{UserID: "u1", PlanID: "pro", Active: true}}},
+			wantErr: ErrNoConversation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &tt.repo
+			uc := NewConversationUsecase(repo, NewMessageStatusUsecase(repo), slog.New(slog.NewTextHandler(io.Discard, nil)))
+			msg, err := uc.Receive(context.Background(), InboundMessage{ExternalID: "SM1", From: theirs, To: ours, Body: "hi"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(repo.messages) != 0 {
+					t.Errorf("stored %d messages, want none", len(repo.messages))
+				}
+				return
+			}
+			if msg.UserID != tt.wantUser || msg.IntegrationID != "twilio" || msg.Direction != entities.DirectionInbound {
+				t.Errorf("message = %+v, want an inbound message of %s on twilio", msg, tt.wantUser)
+			}
+			conv, _ := repo.FindConversation(context.Background(), ours, theirs)
+			if conv.ID == "" || msg.ConversationID != conv.ID || conv.UserID != tt.wantUser {
+				t.Errorf("conversation = %+v, message conversation = %q", conv, msg.ConversationID)
+			}
+		})
+	}
+}
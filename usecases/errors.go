@@ -0,0 +1,21 @@
+package usecases
+
+import "fmt"
+
+// Reference identifies a row that points at another row.
+type Reference struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ConflictError is returned when an operation is blocked by rows that still
+// reference the target.
+type ConflictError struct {
+	Resource   string
+	ID         string
+	References []Reference
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s is still referenced by %d record(s)", e.Resource, e.ID, len(e.References))
+}
@@ -3,14 +3,75 @@ package usecases
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 
 	"messenger-module/entities"
+	"messenger-module/handlers"
 )
 
-type IntegrationUsecase struct{ repo IntegrationRepo }
+// IntegrationUsecaseRepo combines all repositories needed by IntegrationUsecase
+type IntegrationUsecaseRepo interface {
+	IntegrationRepo
+	PlanRepo
+}
+
+type IntegrationUsecase struct{ repo IntegrationUsecaseRepo }
+
+var e164 = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)
+
+// redactIntegration hides the write-only token and header values from API
+// responses, leaving the header names.
+func redactIntegration(in entities.Integration, err error) (entities.Integration, error) {
+	in.Token = ""
+	if len(in.Headers) > 0 {
+		in.HeaderNames = make([]string, 0, len(in.Headers))
+		for name := range in.Headers {
+			in.HeaderNames = append(in.HeaderNames, name)
+		}
+		sort.Strings(in.HeaderNames)
+	}
+	in.Headers = nil
+	return in, err
+}
 
-func NewIntegrationUsecase(repo IntegrationRepo) *IntegrationUsecase {
+func checkBaseURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base_url %q: must be an absolute http(s) URL", raw)
+	}
+	return nil
+}
+
+// checkWebhookURL requires webhook integrations to pin a public endpoint,
+// which is the only destination their messages may have.
+func checkWebhookURL(in entities.Integration) error {
+	if !strings.EqualFold(in.Name, "webhook") {
+		return nil
+	}
+	if in.BaseURL == "" {
+		return errors.New("base_url (the endpoint URL) is required for webhook integrations")
+	}
+	if err := handlers.CheckPublicURL(in.BaseURL); err != nil {
+		return fmt.Errorf("invalid base_url: %w", err)
+	}
+	return nil
+}
+
+func checkPhoneNumber(number string) error {
+	if number != "" && !e164.MatchString(number) {
+		return fmt.Errorf("invalid phone_number %q: must be E.164", number)
+	}
+	return nil
+}
+
+func NewIntegrationUsecase(repo IntegrationUsecaseRepo) *IntegrationUsecase {
 	return &IntegrationUsecase{repo: repo}
 }
 
@@ -23,18 +84,106 @@ func (u *IntegrationUsecase) Create(ctx context.Context, in entities.Integration
 	if in.Type == "" {
 		return entities.Integration{}, errors.New("type is required")
 	}
+	if err := u.checkPlan(ctx, in.PlanID); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := checkPhoneNumber(in.PhoneNumber); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := checkBaseURL(in.BaseURL); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := checkWebhookURL(in); err != nil {
+		return entities.Integration{}, err
+	}
+	if _, err := handlers.ParseWebhookTemplate(in.PayloadTemplate); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := handlers.ValidateWebhookHeaders(in.Headers); err != nil {
+		return entities.Integration{}, err
+	}
 
-	return u.repo.CreateIntegration(ctx, in)
+	return redactIntegration(u.repo.CreateIntegration(ctx, in))
 }
 func (u *IntegrationUsecase) Get(ctx context.Context, id string) (entities.Integration, error) {
-	return u.repo.GetIntegration(ctx, id)
+	return redactIntegration(u.repo.GetIntegration(ctx, id))
 }
-func (u *IntegrationUsecase) List(ctx context.Context) ([]entities.Integration, error) {
-	return u.repo.ListIntegrations(ctx)
+func (u *IntegrationUsecase) List(ctx context.Context, opts entities.ListOptions) ([]entities.Integration, error) {
+	out, err := u.repo.ListIntegrations(ctx, opts)
+	for i := range out {
+		out[i], _ = redactIntegration(out[i], nil)
+	}
+	return out, err
 }
 func (u *IntegrationUsecase) Update(ctx context.Context, id string, in entities.Integration) (entities.Integration, error) {
-	return u.repo.UpdateIntegration(ctx, id, in)
+	if err := u.checkPlan(ctx, in.PlanID); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := checkPhoneNumber(in.PhoneNumber); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := checkBaseURL(in.BaseURL); err != nil {
+		return entities.Integration{}, err
+	}
+	// Updates are partial, so check the webhook endpoint as it will be stored.
+	current, err := u.repo.GetIntegration(ctx, id)
+	if err != nil {
+		return entities.Integration{}, err
+	}
+	if in.Name != "" {
+		current.Name = in.Name
+	}
+	if in.BaseURL != "" {
+		current.BaseURL = in.BaseURL
+	}
+	if err := checkWebhookURL(current); err != nil {
+		return entities.Integration{}, err
+	}
+	if _, err := handlers.ParseWebhookTemplate(in.PayloadTemplate); err != nil {
+		return entities.Integration{}, err
+	}
+	if err := handlers.ValidateWebhookHeaders(in.Headers); err != nil {
+		return entities.Integration{}, err
+	}
+	return redactIntegration(u.repo.UpdateIntegration(ctx, id, in))
 }
+// ReadyProviders returns the names of the integration providers that have
+// at least one live integration with what they need to send.
+func (u *IntegrationUsecase) ReadyProviders(ctx context.Context) (map[string]bool, error) {
+	all, err := u.repo.ListIntegrationsByName(ctx, handlers.IntegrationProviders)
+	if err != nil {
+		return nil, err
+	}
+	ready := make(map[string]bool)
+	for _, in := range all {
+		if handlers.IntegrationReady(in) {
+			ready[strings.ToLower(in.Name)] = true
+		}
+	}
+	return ready, nil
+}
+
 func (u *IntegrationUsecase) Delete(ctx context.Context, id string) error {
 	return u.repo.DeleteIntegration(ctx, id)
 }
+func (u *IntegrationUsecase) Restore(ctx context.Context, id string) error {
+	in, err := u.repo.GetDeletedIntegration(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := u.checkPlan(ctx, in.PlanID); err != nil {
+		return err
+	}
+	return u.repo.RestoreIntegration(ctx, id)
+}
+
+// checkPlan makes sure a referenced plan exists and is not deleted.
+func (u *IntegrationUsecase) checkPlan(ctx context.Context, planID string) error {
+	if planID == "" {
+		return nil
+	}
+	if _, err := u.repo.GetPlan(ctx, planID); err != nil {
+		return fmt.Errorf("plan not found: %w", err)
+	}
+	return nil
+}
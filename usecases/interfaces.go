@@ -2,53 +2,126 @@ package usecases
 
 import (
 	"context"
+	"time"
+
 	"messenger-module/entities"
 )
 
 type UserRepo interface {
 	CreateUser(ctx context.Context, in entities.User) (entities.User, error)
 	GetUser(ctx context.Context, id string) (entities.User, error)
-	ListUsers(ctx context.Context) ([]entities.User, error)
+	ListUsers(ctx context.Context, opts entities.ListOptions) ([]entities.User, error)
 	UpdateUser(ctx context.Context, id string, in entities.User) (entities.User, error)
 	DeleteUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) error
+	UpdateUserCallback(ctx context.Context, id, url, secret string) (entities.User, error)
+	// CreateUserReportingFirst creates the user and reports whether no user,
+	// soft-deleted ones included, existed before it. Concurrent calls are
+	// serialised, so at most one of them is first.
+	CreateUserReportingFirst(ctx context.Context, in entities.User) (entities.User, bool, error)
 }
 
 type PlanRepo interface {
 	CreatePlan(ctx context.Context, in entities.Plan) (entities.Plan, error)
 	GetPlan(ctx context.Context, id string) (entities.Plan, error)
-	ListPlans(ctx context.Context) ([]entities.Plan, error)
+	ListPlans(ctx context.Context, opts entities.ListOptions) ([]entities.Plan, error)
 	UpdatePlan(ctx context.Context, id string, in entities.Plan) (entities.Plan, error)
 	DeletePlan(ctx context.Context, id string) error
+	RestorePlan(ctx context.Context, id string) error
 }
 
 type UserPlanRepo interface {
 	CreateUserPlan(ctx context.Context, in entities.UserPlan) (entities.UserPlan, error)
 	GetUserPlan(ctx context.Context, id string) (entities.UserPlan, error)
-	ListUserPlans(ctx context.Context) ([]entities.UserPlan, error)
+	ListUserPlans(ctx context.Context, opts entities.ListOptions) ([]entities.UserPlan, error)
+	ListUserPlansByPlan(ctx context.Context, planID string) ([]entities.UserPlan, error)
+	GetDeletedUserPlan(ctx context.Context, id string) (entities.UserPlan, error)
 	UpdateUserPlan(ctx context.Context, id string, in entities.UserPlan) (entities.UserPlan, error)
 	DeleteUserPlan(ctx context.Context, id string) error
+	RestoreUserPlan(ctx context.Context, id string) error
 }
 
 type IntegrationRepo interface {
 	CreateIntegration(ctx context.Context, in entities.Integration) (entities.Integration, error)
 	GetIntegration(ctx context.Context, id string) (entities.Integration, error)
-	ListIntegrations(ctx context.Context) ([]entities.Integration, error)
+	ListIntegrations(ctx context.Context, opts entities.ListOptions) ([]entities.Integration, error)
+	ListIntegrationsByName(ctx context.Context, names []string) ([]entities.Integration, error)
+	ListIntegrationsByPlan(ctx context.Context, planID string) ([]entities.Integration, error)
+	FindIntegrationByPhoneNumber(ctx context.Context, number string) (entities.Integration, error)
+	GetDeletedIntegration(ctx context.Context, id string) (entities.Integration, error)
 	UpdateIntegration(ctx context.Context, id string, in entities.Integration) (entities.Integration, error)
 	DeleteIntegration(ctx context.Context, id string) error
+	RestoreIntegration(ctx context.Context, id string) error
 }
 
 type MessageRepo interface {
 	CreateMessage(ctx context.Context, in entities.Message) (entities.Message, error)
 	GetMessage(ctx context.Context, id string) (entities.Message, error)
-	ListMessages(ctx context.Context) ([]entities.Message, error)
+	FindMessageByExternalID(ctx context.Context, externalID string) (entities.Message, error)
+	ListMessages(ctx context.Context, opts entities.ListOptions) ([]entities.Message, error)
+	ListMessagesByUser(ctx context.Context, userID string, opts entities.ListOptions) ([]entities.Message, error)
+	GetDeletedMessage(ctx context.Context, id string) (entities.Message, error)
 	UpdateMessage(ctx context.Context, id string, in entities.Message) (entities.Message, error)
 	DeleteMessage(ctx context.Context, id string) error
+	RestoreMessage(ctx context.Context, id string) error
 }
 
 type MessageStatusRepo interface {
 	CreateMessageStatus(ctx context.Context, in entities.MessageStatus) (entities.MessageStatus, error)
 	GetMessageStatus(ctx context.Context, id string) (entities.MessageStatus, error)
-	ListMessageStatuses(ctx context.Context) ([]entities.MessageStatus, error)
+	ListMessageStatuses(ctx context.Context, opts entities.ListOptions) ([]entities.MessageStatus, error)
+	ListMessageStatusesByMessage(ctx context.Context, messageID string) ([]entities.MessageStatus, error)
 	UpdateMessageStatus(ctx context.Context, id string, in entities.MessageStatus) (entities.MessageStatus, error)
 	DeleteMessageStatus(ctx context.Context, id string) error
+	RestoreMessageStatus(ctx context.Context, id string) error
+}
+
+type APIKeyRepo interface {
+	CreateAPIKey(ctx context.Context, in entities.APIKey) (entities.APIKey, error)
+	GetAPIKey(ctx context.Context, id string) (entities.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (entities.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]entities.APIKey, error)
+	UpdateAPIKey(ctx context.Context, id string, in entities.APIKey) (entities.APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+}
+
+type WebhookDeliveryRepo interface {
+	CreateWebhookDelivery(ctx context.Context, in entities.WebhookDelivery) (entities.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id string) (entities.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, userID, messageID string) ([]entities.WebhookDelivery, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]entities.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, in entities.WebhookDelivery) (entities.WebhookDelivery, error)
+	CountPendingWebhookDeliveries(ctx context.Context) (int64, error)
+}
+
+type ConversationRepo interface {
+	TouchConversation(ctx context.Context, in entities.Conversation, at time.Time) (entities.Conversation, error)
+	GetConversation(ctx context.Context, id string) (entities.Conversation, error)
+	FindConversation(ctx context.Context, ourNumber, theirNumber string) (entities.Conversation, error)
+	FindNumberUser(ctx context.Context, ourNumber string) (string, error)
+	ListConversations(ctx context.Context, userID string) ([]entities.Conversation, error)
+	ListConversationMessages(ctx context.Context, conversationID string) ([]entities.Message, error)
+}
+
+type MediaRepo interface {
+	CreateMedia(ctx context.Context, in entities.Media) (entities.Media, error)
+	GetMedia(ctx context.Context, id string) (entities.Media, error)
+}
+
+type PushSubscriptionRepo interface {
+	GetVAPIDKeys(ctx context.Context) (entities.VAPIDKeys, error)
+	CreateVAPIDKeys(ctx context.Context, keys entities.VAPIDKeys) (entities.VAPIDKeys, error)
+	SavePushSubscription(ctx context.Context, in entities.PushSubscription) (entities.PushSubscription, error)
+	GetPushSubscription(ctx context.Context, id string) (entities.PushSubscription, error)
+	ListPushSubscriptions(ctx context.Context, userID, subscriber string) ([]entities.PushSubscription, error)
+	ExpirePushSubscription(ctx context.Context, id string, at time.Time) error
+	DeletePushSubscription(ctx context.Context, id string) error
+}
+
+type StatusEventRepo interface {
+	PublishStatusEvent(ctx context.Context, payload string) error
+}
+
+type PurgeRepo interface {
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error)
 }
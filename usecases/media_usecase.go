@@ -0,0 +1,110 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+	"messenger-module/handlers"
+)
+
+var (
+	// ErrMediaDisabled is returned for uploads while no signing key or
+	// public base URL is configured.
+	ErrMediaDisabled = errors.New("media uploads need MEDIA_SIGNING_KEY and WEBHOOK_BASE_URL")
+	// ErrMediaLink is returned for media URLs with a bad signature or past
+	// their expiry.
+	ErrMediaLink = errors.New("invalid or expired media link")
+)
+
+// MediaUsecase stores files uploaded for MMS and hands out signed URLs that
+// carriers can fetch them from without credentials.
+type MediaUsecase struct {
+	repo    MediaRepo
+	key     []byte
+	baseURL string
+	ttl     time.Duration
+}
+
+func NewMediaUsecase(repo MediaRepo, cfg confs.MediaConfig, baseURL string) *MediaUsecase {
+	return &MediaUsecase{
+		repo:    repo,
+		key:     []byte(cfg.SigningKey),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		ttl:     time.Duration(cfg.URLTTL),
+	}
+}
+
+// Enabled reports whether uploads can be served.
+func (u *MediaUsecase) Enabled() bool { return len(u.key) > 0 && u.baseURL != "" }
+
+// Upload stores data for the user and returns it with a signed URL to pass
+// in a message's media_urls. A missing or generic content type is sniffed.
+func (u *MediaUsecase) Upload(ctx context.Context, userID, filename, contentType string, data []byte) (entities.Media, error) {
+	if !u.Enabled() {
+		return entities.Media{}, ErrMediaDisabled
+	}
+	if len(data) == 0 {
+		return entities.Media{}, errors.New("file is empty")
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+	if err := handlers.CheckMMSMedia(contentType, int64(len(data))); err != nil {
+		return entities.Media{}, err
+	}
+	media, err := u.repo.CreateMedia(ctx, entities.Media{
+		UserID:      userID,
+		Filename:    filename,
+		ContentType: contentType,
+		Data:        data,
+	})
+	if err != nil {
+		return entities.Media{}, err
+	}
+	media.Data = nil
+	expires := time.Now().Add(u.ttl).UTC()
+	media.URL = u.signedURL(media.ID, expires)
+	media.ExpiresAt = expires.Format(time.RFC3339)
+	return media, nil
+}
+
+// Open returns an uploaded file, including its content, if the URL's
+// expiry and signature check out.
+func (u *MediaUsecase) Open(ctx context.Context, id, expires, signature string) (entities.Media, error) {
+	if len(u.key) == 0 {
+		return entities.Media{}, ErrMediaLink
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return entities.Media{}, ErrMediaLink
+	}
+	want := u.sign(id, exp)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return entities.Media{}, ErrMediaLink
+	}
+	media, err := u.repo.GetMedia(ctx, id)
+	if err != nil {
+		return entities.Media{}, ErrMediaLink
+	}
+	return media, nil
+}
+
+func (u *MediaUsecase) signedURL(id string, expires time.Time) string {
+	exp := expires.Unix()
+	return u.baseURL + "/api/v1/media/" + id + "?expires=" + strconv.FormatInt(exp, 10) + "&signature=" + u.sign(id, exp)
+}
+
+func (u *MediaUsecase) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, u.key)
+	mac.Write([]byte(id + "." + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
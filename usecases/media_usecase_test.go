@@ -0,0 +1,139 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/entities"
+)
+
+// mediaRepo keeps uploads in memory.
+type mediaRepo struct {
+	media map[string]entities.Media
+}
+
+func (r *mediaRepo) CreateMedia(_ context.Context, in entities.Media) (entities.Media, error) {
+	in.ID = "media-" + strconv.Itoa(len(r.media)+1)
+	r.media[in.ID] = in
+	return in, nil
+}
+
+func (r *mediaRepo) GetMedia(_ context.Context, id string) (entities.Media, error) {
+	m, ok := r.media[id]
+	if !ok {
+		return entities.Media{}, errors.New("not found")
+	}
+	return m, nil
+}
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newMediaUsecase(key string) (*MediaUsecase, *mediaRepo) {
+	repo := &mediaRepo{media: map[string]entities.Media{}}
+	cfg := confs.MediaConfig{SigningKey: key, URLTTL: confs.Duration(time.Hour)}
+	return NewMediaUsecase(repo, cfg, "https://messenger.example.com/"), repo
+}
+
+func TestMediaUploadSignedURL(t *testing.T) {
+	uc, repo := newMediaUsecase("media-key")
+	media, err := uc.Upload(context.Background(), "u1", "cat.png", "application/octet-stream", pngHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.ContentType != "image/png" || repo.media[media.ID].ContentType != "image/png" {
+		t.Errorf("content type = %q, want sniffed image/png", media.ContentType)
+	}
+	if media.Data != nil {
+		t.Error("upload response carries the file content")
+	}
+
+	u, err := url.Parse(media.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "https" || u.Host != "messenger.example.com" || u.Path != "/api/v1/media/"+media.ID {
+		t.Errorf("URL = %s", media.URL)
+	}
+	exp, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if wait := time.Until(time.Unix(exp, 0)); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("link expires in %s, want the 1h TTL", wait)
+	}
+	if media.ExpiresAt != time.Unix(exp, 0).UTC().Format(time.RFC3339) {
+		t.Errorf("expires_at = %s does not match the URL", media.ExpiresAt)
+	}
+
+	got, err := uc.Open(context.Background(), media.ID, u.Query().Get("expires"), u.Query().Get("signature"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Data) != string(pngHeader) {
+		t.Errorf("opened %q", got.Data)
+	}
+}
+
+func TestMediaUploadRejected(t *testing.T) {
+	disabled, _ := newMediaUsecase("")
+	if _, err := disabled.Upload(context.Background(), "u1", "cat.png", "image/png", pngHeader); !errors.Is(err, ErrMediaDisabled) {
+		t.Errorf("upload without a signing key: err = %v, want ErrMediaDisabled", err)
+	}
+	uc, _ := newMediaUsecase("media-key")
+	if _, err := uc.Upload(context.Background(), "u1", "empty.png", "image/png", nil); err == nil {
+		t.Error("uploaded an empty file")
+	}
+	if _, err := uc.Upload(context.Background(), "u1", "run.exe", "", []byte("MZ\x90\x00 not media")); err == nil {
+		t.Error("uploaded a file that is not MMS media")
+	}
+}
+
+func TestMediaOpen(t *testing.T) {
+	uc, repo := newMediaUsecase("media-key")
+	repo.media["m1"] = entities.Media{ID: "m1", ContentType: "image/png", Data: pngHeader}
+
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Second).Unix()
+	valid := uc.sign("m1", future)
+	other, _ := newMediaUsecase("other-key")
+
+	tests := []struct {
+		name      string
+		id        string
+		expires   string
+		signature string
+		ok        bool
+	}{
+		{"valid", "m1", strconv.FormatInt(future, 10), valid, true},
+		{"expired", "m1", strconv.FormatInt(past, 10), uc.sign("m1", past), false},
+		{"expiry extended", "m1", strconv.FormatInt(future+3600, 10), valid, false},
+		{"signature for another file", "m2", strconv.FormatInt(future, 10), valid, false},
+		{"tampered signature", "m1", strconv.FormatInt(future, 10), strings.Repeat("0", len(valid)), false},
+		{"signed with another key", "m1", strconv.FormatInt(future, 10), other.sign("m1", future), false},
+		{"uppercase signature", "m1", strconv.FormatInt(future, 10), strings.ToUpper(valid), false},
+		{"missing signature", "m1", strconv.FormatInt(future, 10), "", false},
+		{"non-numeric expiry", "m1", "tomorrow", valid, false},
+		{"unknown file", "m9", strconv.FormatInt(future, 10), uc.sign("m9", future), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := uc.Open(context.Background(), tt.id, tt.expires, tt.signature)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrMediaLink) {
+				t.Fatalf("err = %v, want ErrMediaLink", err)
+			}
+		})
+	}
+
+	disabled, disabledRepo := newMediaUsecase("")
+	disabledRepo.media["m1"] = repo.media["m1"]
+	if _, err := disabled.Open(context.Background(), "m1", strconv.FormatInt(future, 10), disabled.sign("m1", future)); !errors.Is(err, ErrMediaLink) {
+		t.Errorf("opened a link without a signing key: err = %v", err)
+	}
+}
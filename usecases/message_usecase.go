@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"messenger-module/entities"
 	"messenger-module/handlers"
+	"messenger-module/metrics"
+	"messenger-module/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MessageUsecaseRepo combines all repositories needed by MessageUsecase
@@ -23,21 +29,52 @@ type MessageUsecaseRepo interface {
 type MessageUsecase struct {
 	repo           MessageUsecaseRepo
 	handlerFactory *handlers.MessageHandlerFactory
+	statuses       *MessageStatusUsecase
+	conversations  *ConversationUsecase
+	logger         *slog.Logger
 }
 
-func NewMessageUsecase(repo MessageUsecaseRepo) *MessageUsecase {
+func NewMessageUsecase(repo MessageUsecaseRepo, handlerFactory *handlers.MessageHandlerFactory, statuses *MessageStatusUsecase, conversations *ConversationUsecase, logger *slog.Logger) *MessageUsecase {
 	return &MessageUsecase{
 		repo:           repo,
-		handlerFactory: handlers.NewMessageHandlerFactory(),
+		handlerFactory: handlerFactory,
+		statuses:       statuses,
+		conversations:  conversations,
+		logger:         logger,
 	}
 }
 
+// Create validates the message, sends it through the integration's provider
+// and stores it, recording the current trace ID on the message.
 func (u *MessageUsecase) Create(ctx context.Context, in entities.Message) (entities.Message, error) {
-	// Basic validation - type will be set automatically by the handler
-	if in.Content == "" || in.Destination == "" {
+	ctx, span := tracing.Start(ctx, "MessageUsecase.Create",
+		trace.WithAttributes(attribute.String("integration.id", in.IntegrationID)))
+	out, err := u.create(ctx, in)
+	if err == nil {
+		span.SetAttributes(attribute.String("message.id", out.ID), attribute.String("message.type", out.Type))
+	}
+	tracing.End(span, err)
+	return out, err
+}
+
+func (u *MessageUsecase) create(ctx context.Context, in entities.Message) (entities.Message, error) {
+	in.TraceID = tracing.TraceID(ctx)
+	in.Direction = entities.DirectionOutbound
+	in.ConversationID = ""
+	in.From = "" // set from the integration's number when sending
+
+	// Basic validation - type will be set automatically by the handler.
+	// A WhatsApp content template or MMS media stands in for content.
+	if (in.Content == "" && (in.WhatsApp == nil || in.WhatsApp.ContentSID == "") && len(in.MediaURLs) == 0) || in.Destination == "" {
 		return entities.Message{}, errors.New("content and destination are required")
 	}
 
+	if in.CallbackURL != "" {
+		if err := ValidateCallbackURL(in.CallbackURL); err != nil {
+			return entities.Message{}, err
+		}
+	}
+
 	// Validate user_id is provided and user exists
 	if in.UserID == "" {
 		return entities.Message{}, errors.New("user_id is required")
@@ -66,37 +103,63 @@ func (u *MessageUsecase) Create(ctx context.Context, in entities.Message) (entit
 	}
 
 	// Validate user has access to this plan
-	err = u.validateUserPlanAccess(ctx, in.UserID, plan)
+	accessCtx, accessSpan := tracing.Start(ctx, "MessageUsecase.validateUserPlanAccess")
+	err = u.validateUserPlanAccess(accessCtx, in.UserID, plan)
+	tracing.End(accessSpan, err)
 	if err != nil {
 		return entities.Message{}, err
 	}
 
 	// Send via factory (includes validation and handler selection)
-	updatedMessage, externalID, err := u.handlerFactory.SendMessage(integration, plan, in)
+	updatedMessage, externalID, err := u.handlerFactory.SendMessage(ctx, integration, plan, in)
 	if err != nil {
+		u.logger.WarnContext(ctx, "message send failed",
+			"provider", integration.Name, "user_id", in.UserID, "destination", in.Destination, "error", err)
 		return entities.Message{}, fmt.Errorf("failed to send message: %w", err)
 	}
 
 	// Use the updated message with correct type and external ID
 	updatedMessage.ExternalID = externalID
 
+	// SMS is threaded so replies land in the same conversation
+	if updatedMessage.Type == "sms" {
+		updatedMessage, err = u.conversations.Thread(ctx, updatedMessage)
+		if err != nil {
+			u.logger.WarnContext(ctx, "failed to thread message", "external_id", externalID, "error", err)
+		}
+	}
+
 	// Store message in DB
 	createdMessage, err := u.repo.CreateMessage(ctx, updatedMessage)
 	if err != nil {
 		return entities.Message{}, fmt.Errorf("failed to store message: %w", err)
 	}
-
-	// For Ntfy messages, automatically create a "sent" status since Ntfy doesn't provide webhooks
-	if updatedMessage.Type == "ntfy" {
+	metrics.MessagesCreated.WithLabelValues(strings.ToLower(integration.Name), createdMessage.Type).Inc()
+	u.logger.InfoContext(ctx, "message sent",
+		"message_id", createdMessage.ID, "provider", integration.Name, "type", createdMessage.Type,
+		"external_id", createdMessage.ExternalID, "destination", createdMessage.Destination)
+
+	// Providers without status callbacks get a status from the send itself:
+	// ntfy and push services only confirm accepting the message, while a
+	// webhook's 2xx or a successful chat post means it reached its endpoint
+	// or chat.
+	var immediate string
+	switch updatedMessage.Type {
+	case "ntfy", "webpush":
+		immediate = "sent"
+	case "webhook", "slack", "teams", "telegram":
+		immediate = "delivered"
+	}
+	if immediate != "" {
 		messageStatus := entities.MessageStatus{
-			MessageID: createdMessage.ID,
-			Status:    "sent",
+			MessageID:  createdMessage.ID,
+			ExternalID: createdMessage.ExternalID,
+			Status:     immediate,
 		}
-		_, err := u.repo.CreateMessageStatus(ctx, messageStatus)
+		_, err := u.statuses.Create(ctx, messageStatus)
 		if err != nil {
 			// Log the error but don't fail the message creation
-			// In a real app, you might want to use a proper logger here
-			fmt.Printf("Warning: failed to create message status for Ntfy message %s: %v\n", createdMessage.ID, err)
+			u.logger.WarnContext(ctx, "failed to create status for message", "message_id", createdMessage.ID, "status", immediate, "error", err)
 		}
 	}
 
@@ -108,7 +171,7 @@ func (u *MessageUsecase) Create(ctx context.Context, in entities.Message) (entit
 // Free users only have access to Free features
 func (u *MessageUsecase) validateUserPlanAccess(ctx context.Context, userID string, requiredPlan entities.Plan) error {
 	// Get all user plans (active ones)
-	userPlans, err := u.repo.ListUserPlans(ctx)
+	userPlans, err := u.repo.ListUserPlans(ctx, entities.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to get user plans: %w", err)
 	}
@@ -132,7 +195,7 @@ func (u *MessageUsecase) validateUserPlanAccess(ctx context.Context, userID stri
 	for _, userPlan := range userActivePlans {
 		plan, err := u.repo.GetPlan(ctx, userPlan.PlanID)
 		if err != nil {
-			continue // Skip invalid plans
+			return fmt.Errorf("user plan %s references missing plan %s: %w", userPlan.ID, userPlan.PlanID, err)
 		}
 
 		if strings.EqualFold(plan.Name, "pro") {
@@ -160,11 +223,20 @@ func (u *MessageUsecase) validateUserPlanAccess(ctx context.Context, userID stri
 	return fmt.Errorf("user does not have access to %s plan features", requiredPlanName)
 }
 
+// Estimate reports the encoding, segment count and estimated cost of sending
+// content as SMS. Nothing is sent.
+func (u *MessageUsecase) Estimate(content string, smartEncoding bool) entities.SMSEstimate {
+	return u.handlerFactory.EstimateSMS(content, smartEncoding)
+}
+
 func (u *MessageUsecase) Get(ctx context.Context, id string) (entities.Message, error) {
 	return u.repo.GetMessage(ctx, id)
 }
-func (u *MessageUsecase) List(ctx context.Context) ([]entities.Message, error) {
-	return u.repo.ListMessages(ctx)
+func (u *MessageUsecase) List(ctx context.Context, opts entities.ListOptions) ([]entities.Message, error) {
+	return u.repo.ListMessages(ctx, opts)
+}
+func (u *MessageUsecase) ListByUser(ctx context.Context, userID string, opts entities.ListOptions) ([]entities.Message, error) {
+	return u.repo.ListMessagesByUser(ctx, userID, opts)
 }
 func (u *MessageUsecase) Update(ctx context.Context, id string, in entities.Message) (entities.Message, error) {
 	return u.repo.UpdateMessage(ctx, id, in)
@@ -172,3 +244,10 @@ func (u *MessageUsecase) Update(ctx context.Context, id string, in entities.Mess
 func (u *MessageUsecase) Delete(ctx context.Context, id string) error {
 	return u.repo.DeleteMessage(ctx, id)
 }
+// GetDeleted returns a soft-deleted message, which Get does not see.
+func (u *MessageUsecase) GetDeleted(ctx context.Context, id string) (entities.Message, error) {
+	return u.repo.GetDeletedMessage(ctx, id)
+}
+func (u *MessageUsecase) Restore(ctx context.Context, id string) error {
+	return u.repo.RestoreMessage(ctx, id)
+}
@@ -7,25 +7,44 @@ import (
 	"messenger-module/entities"
 )
 
-type MessageStatusUsecase struct { repo MessageStatusRepo }
+type MessageStatusUsecase struct {
+	repo      MessageStatusRepo
+	observers []StatusObserver
+}
 
-func NewMessageStatusUsecase(repo MessageStatusRepo) *MessageStatusUsecase {
-	return &MessageStatusUsecase{repo: repo}
+func NewMessageStatusUsecase(repo MessageStatusRepo, observers ...StatusObserver) *MessageStatusUsecase {
+	return &MessageStatusUsecase{repo: repo, observers: observers}
 }
 
+// Create stores a status transition and notifies every observer.
 func (u *MessageStatusUsecase) Create(ctx context.Context, in entities.MessageStatus) (entities.MessageStatus, error) {
 	if in.MessageID == "" || in.Status == "" {
 		return entities.MessageStatus{}, errors.New("message_id and status are required")
 	}
-	return u.repo.CreateMessageStatus(ctx, in)
+	out, err := u.repo.CreateMessageStatus(ctx, in)
+	if err != nil {
+		return entities.MessageStatus{}, err
+	}
+	for _, o := range u.observers {
+		o.StatusRecorded(ctx, out)
+	}
+	return out, nil
 }
 func (u *MessageStatusUsecase) Get(ctx context.Context, id string) (entities.MessageStatus, error) {
 	return u.repo.GetMessageStatus(ctx, id)
 }
-func (u *MessageStatusUsecase) List(ctx context.Context) ([]entities.MessageStatus, error) {
-	return u.repo.ListMessageStatuses(ctx)
+func (u *MessageStatusUsecase) List(ctx context.Context, opts entities.ListOptions) ([]entities.MessageStatus, error) {
+	return u.repo.ListMessageStatuses(ctx, opts)
+}
+// ListByMessage returns a message's statuses, oldest first.
+func (u *MessageStatusUsecase) ListByMessage(ctx context.Context, messageID string) ([]entities.MessageStatus, error) {
+	if messageID == "" {
+		return nil, errors.New("message_id is required")
+	}
+	return u.repo.ListMessageStatusesByMessage(ctx, messageID)
 }
 func (u *MessageStatusUsecase) Update(ctx context.Context, id string, in entities.MessageStatus) (entities.MessageStatus, error) {
 	return u.repo.UpdateMessageStatus(ctx, id, in)
 }
 func (u *MessageStatusUsecase) Delete(ctx context.Context, id string) error { return u.repo.DeleteMessageStatus(ctx, id) }
+func (u *MessageStatusUsecase) Restore(ctx context.Context, id string) error { return u.repo.RestoreMessageStatus(ctx, id) }
@@ -0,0 +1,68 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+
+	"messenger-module/entities"
+)
+
+// statusRepo answers ListMessageStatusesByMessage from a fixed list. Methods
+// the tests do not need fall through to the nil embedded interface and panic.
+type statusRepo struct {
+	MessageStatusRepo
+	statuses []entities.MessageStatus
+	queried  []string
+}
+
+func (r *statusRepo) ListMessageStatusesByMessage(_ context.Context, messageID string) ([]entities.MessageStatus, error) {
+	r.queried = append(r.queried, messageID)
+	var out []entities.MessageStatus
+	for _, s := range r.statuses {
+		if s.MessageID == messageID {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func TestListByMessage(t *testing.T) {
+	repo := &statusRepo{statuses: []entities.MessageStatus{
+		{MessageID: "m1", Status: "queued"},
+		{MessageID: "m2", Status: "queued"},
+		{MessageID: "m1", Status: "delivered"},
+	}}
+	uc := NewMessageStatusUsecase(repo)
+
+	tests := []struct {
+		messageID string
+		want      []string
+		wantErr   bool
+	}{
+		{"m1", []string{"queued", "delivered"}, false},
+		{"m3", nil, false},
+		// An empty ID must not turn into an unfiltered listing.
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := uc.ListByMessage(context.Background(), tt.messageID)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ListByMessage(%q) err = %v, want error %v", tt.messageID, err, tt.wantErr)
+		}
+		var statuses []string
+		for _, s := range got {
+			statuses = append(statuses, s.Status)
+		}
+		if len(statuses) != len(tt.want) {
+			t.Fatalf("ListByMessage(%q) = %v, want %v", tt.messageID, statuses, tt.want)
+		}
+		for i := range statuses {
+			if statuses[i] != tt.want[i] {
+				t.Errorf("ListByMessage(%q) = %v, want %v", tt.messageID, statuses, tt.want)
+			}
+		}
+	}
+	if len(repo.queried) != 2 {
+		t.Errorf("repository queried for %v, want m1 and m3 only", repo.queried)
+	}
+}
@@ -8,9 +8,16 @@ import (
 	"messenger-module/entities"
 )
 
-type PlanUsecase struct{ repo PlanRepo }
+// PlanUsecaseRepo combines all repositories needed by PlanUsecase
+type PlanUsecaseRepo interface {
+	PlanRepo
+	UserPlanRepo
+	IntegrationRepo
+}
+
+type PlanUsecase struct{ repo PlanUsecaseRepo }
 
-func NewPlanUsecase(repo PlanRepo) *PlanUsecase { return &PlanUsecase{repo: repo} }
+func NewPlanUsecase(repo PlanUsecaseRepo) *PlanUsecase { return &PlanUsecase{repo: repo} }
 
 func (u *PlanUsecase) Create(ctx context.Context, in entities.Plan) (entities.Plan, error) {
 	in.Name = strings.TrimSpace(in.Name)
@@ -22,7 +29,7 @@ func (u *PlanUsecase) Create(ctx context.Context, in entities.Plan) (entities.Pl
 		return entities.Plan{}, errors.New("price_cents must be >= 0")
 	}
 	// Idempotent by name: if a plan with the same name exists, return it instead of erroring
-	if existing, err := u.repo.ListPlans(ctx); err == nil {
+	if existing, err := u.repo.ListPlans(ctx, entities.ListOptions{}); err == nil {
 		for _, p := range existing {
 			if strings.EqualFold(p.Name, in.Name) {
 				return p, nil
@@ -35,10 +42,45 @@ func (u *PlanUsecase) Create(ctx context.Context, in entities.Plan) (entities.Pl
 func (u *PlanUsecase) Get(ctx context.Context, id string) (entities.Plan, error) {
 	return u.repo.GetPlan(ctx, id)
 }
-func (u *PlanUsecase) List(ctx context.Context) ([]entities.Plan, error) {
-	return u.repo.ListPlans(ctx)
+func (u *PlanUsecase) List(ctx context.Context, opts entities.ListOptions) ([]entities.Plan, error) {
+	return u.repo.ListPlans(ctx, opts)
 }
 func (u *PlanUsecase) Update(ctx context.Context, id string, in entities.Plan) (entities.Plan, error) {
 	return u.repo.UpdatePlan(ctx, id, in)
 }
-func (u *PlanUsecase) Delete(ctx context.Context, id string) error { return u.repo.DeletePlan(ctx, id) }
+
+// Delete refuses to remove a plan that user plans or integrations still use.
+func (u *PlanUsecase) Delete(ctx context.Context, id string) error {
+	if _, err := u.repo.GetPlan(ctx, id); err != nil {
+		return err
+	}
+	refs, err := u.references(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 {
+		return &ConflictError{Resource: "plan", ID: id, References: refs}
+	}
+	return u.repo.DeletePlan(ctx, id)
+}
+func (u *PlanUsecase) Restore(ctx context.Context, id string) error { return u.repo.RestorePlan(ctx, id) }
+
+// references lists the live user plans and integrations pointing at planID.
+func (u *PlanUsecase) references(ctx context.Context, planID string) ([]Reference, error) {
+	var refs []Reference
+	userPlans, err := u.repo.ListUserPlansByPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	for _, up := range userPlans {
+		refs = append(refs, Reference{Type: "user_plan", ID: up.ID})
+	}
+	integrations, err := u.repo.ListIntegrationsByPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range integrations {
+		refs = append(refs, Reference{Type: "integration", ID: i.ID})
+	}
+	return refs, nil
+}
@@ -0,0 +1,42 @@
+package usecases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PurgeJob periodically hard-deletes rows that have been soft-deleted for
+// longer than the retention window.
+type PurgeJob struct {
+	repo      PurgeRepo
+	retention time.Duration
+	interval  time.Duration
+	logger    *slog.Logger
+}
+
+func NewPurgeJob(repo PurgeRepo, retention, interval time.Duration, logger *slog.Logger) *PurgeJob {
+	return &PurgeJob{repo: repo, retention: retention, interval: interval, logger: logger}
+}
+
+// Run purges once immediately and then on every tick until ctx is cancelled.
+func (j *PurgeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if n, err := j.RunOnce(ctx); err != nil {
+			j.logger.ErrorContext(ctx, "purge failed", "error", err)
+		} else if n > 0 {
+			j.logger.InfoContext(ctx, "purged soft-deleted rows", "rows", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *PurgeJob) RunOnce(ctx context.Context) (int64, error) {
+	return j.repo.PurgeDeleted(ctx, time.Now().Add(-j.retention))
+}
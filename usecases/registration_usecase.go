@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"messenger-module/entities"
+)
+
+// RegistrationUsecase onboards a new user: the user row, a Free plan
+// subscription and an initial API key. Admin keys manage every tenant, so
+// only the first user, or one created by an operator, gets one.
+type RegistrationUsecase struct {
+	users     *UserUsecase
+	plans     *PlanUsecase
+	userPlans *UserPlanUsecase
+	apiKeys   *APIKeyUsecase
+}
+
+func NewRegistrationUsecase(users *UserUsecase, plans *PlanUsecase, userPlans *UserPlanUsecase, apiKeys *APIKeyUsecase) *RegistrationUsecase {
+	return &RegistrationUsecase{users: users, plans: plans, userPlans: userPlans, apiKeys: apiKeys}
+}
+
+// Register creates the user and returns it with APIKey set to the initial
+// key's secret, which is not retrievable afterwards. The key is an admin key
+// for the very first user, who bootstraps the installation, and a send and
+// read key for everyone after. Whether the user is first is decided when it
+// is inserted, so two concurrent registrations cannot both get admin.
+func (u *RegistrationUsecase) Register(ctx context.Context, in entities.User) (entities.User, error) {
+	user, first, err := u.users.CreateReportingFirst(ctx, in)
+	if err != nil {
+		return entities.User{}, err
+	}
+	return u.onboard(ctx, user, first)
+}
+
+// RegisterAdmin creates the user like Register, with an admin key. It is for
+// operators, not for the open API.
+func (u *RegistrationUsecase) RegisterAdmin(ctx context.Context, in entities.User) (entities.User, error) {
+	user, err := u.users.Create(ctx, in)
+	if err != nil {
+		return entities.User{}, err
+	}
+	return u.onboard(ctx, user, true)
+}
+
+// onboard gives a newly created user the Free plan and an initial key.
+func (u *RegistrationUsecase) onboard(ctx context.Context, user entities.User, admin bool) (entities.User, error) {
+	// 1) Ensure we have a Free plan; create if missing (price 0)
+	freePlan, err := u.ensureFreePlan(ctx)
+	if err != nil {
+		return entities.User{}, fmt.Errorf("failed to ensure Free plan: %w", err)
+	}
+	// 2) Create UserPlan linking to Free plan
+	if _, err := u.userPlans.Create(ctx, entities.UserPlan{UserID: user.ID, PlanID: freePlan.ID, Active: true}); err != nil {
+		return entities.User{}, fmt.Errorf("failed to create user plan: %w", err)
+	}
+	// 3) Issue an initial key; no scopes means send and read
+	var scopes []string
+	if admin {
+		scopes = []string{entities.APIKeyScopeAdmin}
+	}
+	key, err := u.apiKeys.Create(ctx, entities.APIKey{UserID: user.ID, Name: "default", Scopes: scopes})
+	if err != nil {
+		return entities.User{}, fmt.Errorf("failed to create api key: %w", err)
+	}
+	user.APIKey = key.Secret
+	return user, nil
+}
+
+func (u *RegistrationUsecase) ensureFreePlan(ctx context.Context) (entities.Plan, error) {
+	if p, ok, err := u.findFreePlan(ctx); err != nil || ok {
+		return p, err
+	}
+	p, err := u.plans.Create(ctx, entities.Plan{Name: "Free", PriceCents: 0})
+	if err == nil {
+		return p, nil
+	}
+	// Possible race: plan might have been created just now by another request. Re-list once.
+	if p, ok, err2 := u.findFreePlan(ctx); err2 == nil && ok {
+		return p, nil
+	}
+	return entities.Plan{}, err
+}
+
+func (u *RegistrationUsecase) findFreePlan(ctx context.Context) (entities.Plan, bool, error) {
+	plans, err := u.plans.List(ctx, entities.ListOptions{})
+	if err != nil {
+		return entities.Plan{}, false, err
+	}
+	for _, p := range plans {
+		if strings.EqualFold(p.Name, "free") {
+			return p, true, nil
+		}
+	}
+	return entities.Plan{}, false, nil
+}
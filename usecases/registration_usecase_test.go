@@ -0,0 +1,169 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"messenger-module/entities"
+)
+
+// registrationRepo keeps users, plans, user plans and keys in memory. Methods
+// the tests do not need fall through to the nil embedded interfaces and
+// panic.
+type registrationRepo struct {
+	PlanUsecaseRepo
+	APIKeyUsecaseRepo
+	mu        sync.Mutex
+	users     map[string]entities.User
+	plans     []entities.Plan
+	userPlans []entities.UserPlan
+	keys      []entities.APIKey
+}
+
+func newRegistrationRepo() *registrationRepo {
+	return &registrationRepo{users: map[string]entities.User{}}
+}
+
+func (r *registrationRepo) CreateUser(_ context.Context, in entities.User) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in.ID = fmt.Sprintf("user-%d", len(r.users)+1)
+	r.users[in.ID] = in
+	return in, nil
+}
+
+// CreateUserReportingFirst checks and inserts under one lock, as the
+// database does under its advisory lock.
+func (r *registrationRepo) CreateUserReportingFirst(_ context.Context, in entities.User) (entities.User, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	first := len(r.users) == 0
+	in.ID = fmt.Sprintf("user-%d", len(r.users)+1)
+	r.users[in.ID] = in
+	return in, first, nil
+}
+
+func (r *registrationRepo) GetUser(_ context.Context, id string) (entities.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return entities.User{}, errors.New("not found")
+	}
+	return u, nil
+}
+
+func (r *registrationRepo) CreatePlan(_ context.Context, in entities.Plan) (entities.Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in.ID = fmt.Sprintf("plan-%d", len(r.plans)+1)
+	r.plans = append(r.plans, in)
+	return in, nil
+}
+
+func (r *registrationRepo) ListPlans(context.Context, entities.ListOptions) ([]entities.Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]entities.Plan(nil), r.plans...), nil
+}
+
+func (r *registrationRepo) GetPlan(_ context.Context, id string) (entities.Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.plans {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return entities.Plan{}, errors.New("not found")
+}
+
+func (r *registrationRepo) CreateUserPlan(_ context.Context, in entities.UserPlan) (entities.UserPlan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.userPlans = append(r.userPlans, in)
+	return in, nil
+}
+
+func (r *registrationRepo) CreateAPIKey(_ context.Context, in entities.APIKey) (entities.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in.ID = fmt.Sprintf("key-%d", len(r.keys)+1)
+	r.keys = append(r.keys, in)
+	return in, nil
+}
+
+func newRegistrationUsecase(repo *registrationRepo) *RegistrationUsecase {
+	return NewRegistrationUsecase(NewUserUsecase(repo), NewPlanUsecase(repo), NewUserPlanUsecase(repo), NewAPIKeyUsecase(repo))
+}
+
+// adminKeys counts the admin keys issued so far.
+func (r *registrationRepo) adminKeys() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, k := range r.keys {
+		if k.HasScope(entities.APIKeyScopeAdmin) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRegisterOnlyFirstUserIsAdmin(t *testing.T) {
+	repo := newRegistrationRepo()
+	uc := newRegistrationUsecase(repo)
+
+	tests := []struct {
+		name     string
+		register func(context.Context, entities.User) (entities.User, error)
+		scopes   []string
+	}{
+		{"first", uc.Register, []string{"admin"}},
+		{"second", uc.Register, []string{"send", "read"}},
+		{"operator", uc.RegisterAdmin, []string{"admin"}},
+		{"after operator", uc.Register, []string{"send", "read"}},
+	}
+	for i, tt := range tests {
+		user, err := tt.register(context.Background(), entities.User{Name: tt.name})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if user.APIKey == "" {
+			t.Errorf("%s: no initial key returned", tt.name)
+		}
+		key := repo.keys[i]
+		if key.UserID != user.ID || fmt.Sprint(key.Scopes) != fmt.Sprint(tt.scopes) {
+			t.Errorf("%s: key = %+v, want scopes %v", tt.name, key, tt.scopes)
+		}
+		if up := repo.userPlans[i]; up.UserID != user.ID || up.PlanID != repo.plans[0].ID || !up.Active {
+			t.Errorf("%s: user plan = %+v, want the Free plan", tt.name, up)
+		}
+	}
+	if len(repo.plans) != 1 || repo.plans[0].Name != "Free" {
+		t.Errorf("plans = %+v, want one Free plan", repo.plans)
+	}
+}
+
+func TestRegisterConcurrentFirstUsers(t *testing.T) {
+	repo := newRegistrationRepo()
+	uc := newRegistrationUsecase(repo)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := uc.Register(context.Background(), entities.User{Name: fmt.Sprintf("user %d", i)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := repo.adminKeys(); n != 1 {
+		t.Errorf("admin keys = %d, want exactly 1", n)
+	}
+}
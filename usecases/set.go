@@ -0,0 +1,60 @@
+package usecases
+
+import (
+	"log/slog"
+	"time"
+
+	"messenger-module/confs"
+	"messenger-module/handlers"
+)
+
+// Repository is everything the usecases need from storage.
+type Repository interface {
+	MessageUsecaseRepo
+	APIKeyRepo
+	PurgeRepo
+	WebhookDeliveryRepo
+	StatusEventRepo
+	ConversationRepo
+	PushSubscriptionRepo
+	MediaRepo
+}
+
+// Set bundles the usecases built on one repository so the HTTP server and the
+// CLI wire them the same way.
+type Set struct {
+	Users           *UserUsecase
+	Plans           *PlanUsecase
+	UserPlans       *UserPlanUsecase
+	Integrations    *IntegrationUsecase
+	Messages        *MessageUsecase
+	MessageStatuses *MessageStatusUsecase
+	APIKeys         *APIKeyUsecase
+	Registration    *RegistrationUsecase
+	StatusCallbacks *StatusCallbackUsecase
+	StatusFeed      *StatusFeed
+	Conversations   *ConversationUsecase
+	WebPush         *WebPushUsecase
+	Media           *MediaUsecase
+}
+
+func NewSet(repo Repository, handlerFactory *handlers.MessageHandlerFactory, cfg *confs.Config, logger *slog.Logger) *Set {
+	callbacks := handlers.NewCallbackSender(time.Duration(cfg.Callbacks.Timeout))
+	s := &Set{
+		Users:           NewUserUsecase(repo),
+		Plans:           NewPlanUsecase(repo),
+		UserPlans:       NewUserPlanUsecase(repo),
+		Integrations:    NewIntegrationUsecase(repo),
+		APIKeys:         NewAPIKeyUsecase(repo),
+		StatusCallbacks: NewStatusCallbackUsecase(repo, callbacks, cfg.Callbacks.MaxAttempts, logger),
+		StatusFeed:      NewStatusFeed(repo, logger),
+		WebPush:         NewWebPushUsecase(repo, logger),
+		Media:           NewMediaUsecase(repo, cfg.Media, cfg.WebhookBaseURL),
+	}
+	handlerFactory.SetPushSubscriptions(s.WebPush)
+	s.MessageStatuses = NewMessageStatusUsecase(repo, s.StatusCallbacks, s.StatusFeed)
+	s.Conversations = NewConversationUsecase(repo, s.MessageStatuses, logger)
+	s.Messages = NewMessageUsecase(repo, handlerFactory, s.MessageStatuses, s.Conversations, logger)
+	s.Registration = NewRegistrationUsecase(s.Users, s.Plans, s.UserPlans, s.APIKeys)
+	return s
+}
@@ -0,0 +1,407 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"messenger-module/entities"
+	"messenger-module/handlers"
+	"messenger-module/metrics"
+)
+
+const (
+	// StatusCallbackEvent is the event name of status callbacks.
+	StatusCallbackEvent = "message.status.updated"
+
+	// Callback request headers.
+	CallbackSignatureHeader = handlers.SignatureHeader
+	CallbackEventHeader     = handlers.EventHeader
+	CallbackDeliveryHeader  = handlers.DeliveryHeader
+
+	callbackSecretPrefix = "whsec_"
+	callbackBatchSize    = 20
+	callbackPollInterval = 5 * time.Second
+	callbackBaseBackoff  = 30 * time.Second
+	callbackMaxBackoff   = time.Hour
+)
+
+// StatusObserver is told about every message status after it is stored.
+type StatusObserver interface {
+	StatusRecorded(ctx context.Context, status entities.MessageStatus)
+}
+
+// CallbackSender POSTs a callback body and reports the response.
+type CallbackSender interface {
+	Post(ctx context.Context, url string, headers map[string]string, body []byte) (int, string, error)
+}
+
+// StatusCallbackUsecaseRepo combines the repositories needed for callbacks.
+type StatusCallbackUsecaseRepo interface {
+	MessageRepo
+	UserRepo
+	WebhookDeliveryRepo
+}
+
+// StatusCallbackUsecase manages customer callback endpoints and delivers a
+// signed POST for each status change, retrying failures with backoff.
+type StatusCallbackUsecase struct {
+	repo        StatusCallbackUsecaseRepo
+	sender      CallbackSender
+	maxAttempts int
+	logger      *slog.Logger
+	wake        chan struct{}
+}
+
+func NewStatusCallbackUsecase(repo StatusCallbackUsecaseRepo, sender CallbackSender, maxAttempts int, logger *slog.Logger) *StatusCallbackUsecase {
+	return &StatusCallbackUsecase{
+		repo:        repo,
+		sender:      sender,
+		maxAttempts: maxAttempts,
+		logger:      logger,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Settings returns the user's callback URL and signing secret, creating the
+// secret on first use.
+func (u *StatusCallbackUsecase) Settings(ctx context.Context, userID string) (entities.CallbackSettings, error) {
+	user, err := u.ensureSecret(ctx, userID)
+	if err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	return entities.CallbackSettings{URL: user.CallbackURL, Secret: user.CallbackSecret}, nil
+}
+
+// SetURL registers the user's callback URL.
+func (u *StatusCallbackUsecase) SetURL(ctx context.Context, userID, callbackURL string) (entities.CallbackSettings, error) {
+	if err := ValidateCallbackURL(callbackURL); err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	user, err := u.ensureSecret(ctx, userID)
+	if err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	user, err = u.repo.UpdateUserCallback(ctx, userID, callbackURL, user.CallbackSecret)
+	if err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	return entities.CallbackSettings{URL: user.CallbackURL, Secret: user.CallbackSecret}, nil
+}
+
+// ClearURL stops user-level callbacks. Per-message callback URLs still apply.
+func (u *StatusCallbackUsecase) ClearURL(ctx context.Context, userID string) error {
+	user, err := u.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	_, err = u.repo.UpdateUserCallback(ctx, userID, "", user.CallbackSecret)
+	return err
+}
+
+// RotateSecret replaces the signing secret. Pending retries are signed with
+// the new secret.
+func (u *StatusCallbackUsecase) RotateSecret(ctx context.Context, userID string) (entities.CallbackSettings, error) {
+	user, err := u.repo.GetUser(ctx, userID)
+	if err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	secret, err := generateCallbackSecret()
+	if err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	user, err = u.repo.UpdateUserCallback(ctx, userID, user.CallbackURL, secret)
+	if err != nil {
+		return entities.CallbackSettings{}, err
+	}
+	return entities.CallbackSettings{URL: user.CallbackURL, Secret: user.CallbackSecret}, nil
+}
+
+func (u *StatusCallbackUsecase) ensureSecret(ctx context.Context, userID string) (entities.User, error) {
+	user, err := u.repo.GetUser(ctx, userID)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if user.CallbackSecret != "" {
+		return user, nil
+	}
+	secret, err := generateCallbackSecret()
+	if err != nil {
+		return entities.User{}, err
+	}
+	return u.repo.UpdateUserCallback(ctx, userID, user.CallbackURL, secret)
+}
+
+// StatusRecorded implements StatusObserver. Failures are logged rather than
+// returned so they never fail the status write itself.
+func (u *StatusCallbackUsecase) StatusRecorded(ctx context.Context, status entities.MessageStatus) {
+	if _, err := u.Enqueue(ctx, status); err != nil {
+		u.logger.ErrorContext(ctx, "failed to enqueue status callback", "message_id", status.MessageID, "status_id", status.ID, "error", err)
+	}
+}
+
+type statusCallbackPayload struct {
+	Event     string             `json:"event"`
+	CreatedAt string             `json:"created_at"`
+	Data      statusCallbackData `json:"data"`
+}
+
+type statusCallbackData struct {
+	MessageID       string  `json:"message_id"`
+	MessageStatusID string  `json:"message_status_id"`
+	Status          string  `json:"status"`
+	ExternalID      string  `json:"external_id,omitempty"`
+	MessageType     string  `json:"message_type"`
+	Destination     string  `json:"destination"`
+	DateSent        *string `json:"date_sent,omitempty"`
+	DateOpened      *string `json:"date_opened,omitempty"`
+	DateError       *string `json:"date_error,omitempty"`
+	DateCanceled    *string `json:"date_canceled,omitempty"`
+	DateDeferred    *string `json:"date_deferred,omitempty"`
+}
+
+// Enqueue records a pending delivery for status if the message or its owner
+// has a callback URL. It returns nil when there is nowhere to deliver.
+func (u *StatusCallbackUsecase) Enqueue(ctx context.Context, status entities.MessageStatus) (*entities.WebhookDelivery, error) {
+	msg, err := u.repo.GetMessage(ctx, status.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("message not found: %w", err)
+	}
+	user, err := u.repo.GetUser(ctx, msg.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	target := msg.CallbackURL
+	if target == "" {
+		target = user.CallbackURL
+	}
+	if target == "" {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(statusCallbackPayload{
+		Event:     StatusCallbackEvent,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Data: statusCallbackData{
+			MessageID:       msg.ID,
+			MessageStatusID: status.ID,
+			Status:          status.Status,
+			ExternalID:      status.ExternalID,
+			MessageType:     msg.Type,
+			Destination:     msg.Destination,
+			DateSent:        status.DateSent,
+			DateOpened:      status.DateOpened,
+			DateError:       status.DateError,
+			DateCanceled:    status.DateCanceled,
+			DateDeferred:    status.DateDeferred,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	d, err := u.repo.CreateWebhookDelivery(ctx, entities.WebhookDelivery{
+		UserID:          user.ID,
+		MessageID:       msg.ID,
+		MessageStatusID: status.ID,
+		URL:             target,
+		Event:           StatusCallbackEvent,
+		Payload:         string(body),
+		State:           entities.DeliveryPending,
+		NextAttemptAt:   &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.notify()
+	return &d, nil
+}
+
+func (u *StatusCallbackUsecase) ListDeliveries(ctx context.Context, userID, messageID string) ([]entities.WebhookDelivery, error) {
+	return u.repo.ListWebhookDeliveries(ctx, userID, messageID)
+}
+
+func (u *StatusCallbackUsecase) GetDelivery(ctx context.Context, userID, id string) (entities.WebhookDelivery, error) {
+	d, err := u.repo.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	if d.UserID != userID {
+		return entities.WebhookDelivery{}, errors.New("not found")
+	}
+	return d, nil
+}
+
+// Redeliver queues a fresh delivery of the same payload to the same URL,
+// keeping the original in the log.
+func (u *StatusCallbackUsecase) Redeliver(ctx context.Context, userID, id string) (entities.WebhookDelivery, error) {
+	orig, err := u.GetDelivery(ctx, userID, id)
+	if err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	d, err := u.repo.CreateWebhookDelivery(ctx, entities.WebhookDelivery{
+		UserID:          orig.UserID,
+		MessageID:       orig.MessageID,
+		MessageStatusID: orig.MessageStatusID,
+		RedeliveryOf:    orig.ID,
+		URL:             orig.URL,
+		Event:           orig.Event,
+		Payload:         orig.Payload,
+		State:           entities.DeliveryPending,
+		NextAttemptAt:   &now,
+	})
+	if err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	u.notify()
+	return d, nil
+}
+
+func (u *StatusCallbackUsecase) notify() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due callbacks until ctx is cancelled, waking early whenever
+// a new delivery is queued by this process.
+func (u *StatusCallbackUsecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(callbackPollInterval)
+	defer ticker.Stop()
+	for {
+		u.DeliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-u.wake:
+		}
+	}
+}
+
+// DeliverDue attempts every due delivery, one batch at a time.
+func (u *StatusCallbackUsecase) DeliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		// Lease long enough to cover a batch of attempts
+		batch, err := u.repo.ClaimDueWebhookDeliveries(ctx, time.Now(), 10*time.Minute, callbackBatchSize)
+		if err != nil {
+			u.logger.ErrorContext(ctx, "failed to claim status callbacks", "error", err)
+			return
+		}
+		for _, d := range batch {
+			u.attempt(ctx, d)
+		}
+		if len(batch) < callbackBatchSize {
+			break
+		}
+	}
+	if n, err := u.repo.CountPendingWebhookDeliveries(ctx); err == nil {
+		metrics.QueueDepth.WithLabelValues("status_callbacks").Set(float64(n))
+	}
+}
+
+func (u *StatusCallbackUsecase) attempt(ctx context.Context, d entities.WebhookDelivery) {
+	user, err := u.ensureSecret(ctx, d.UserID)
+	if err != nil {
+		u.logger.ErrorContext(ctx, "status callback owner not found", "delivery_id", d.ID, "error", err)
+		return
+	}
+
+	ts := time.Now().Unix()
+	headers := map[string]string{
+		CallbackSignatureHeader: SignCallback(user.CallbackSecret, ts, []byte(d.Payload)),
+		CallbackEventHeader:     d.Event,
+		CallbackDeliveryHeader:  d.ID,
+	}
+	code, body, err := u.sender.Post(ctx, d.URL, headers, []byte(d.Payload))
+	if ctx.Err() != nil {
+		// Shutting down; the lease expires and another attempt picks it up.
+		return
+	}
+
+	now := time.Now().UTC()
+	attemptedAt := now.Format(time.RFC3339)
+	d.Attempts++
+	d.LastAttemptAt = &attemptedAt
+	d.ResponseBody = body
+	d.ResponseCode = nil
+	if code != 0 {
+		d.ResponseCode = &code
+	}
+	switch {
+	case err == nil && code >= 200 && code < 300:
+		d.State = entities.DeliverySucceeded
+		d.NextAttemptAt = nil
+		d.LastError = ""
+	default:
+		if err != nil {
+			d.LastError = err.Error()
+		} else {
+			d.LastError = "unexpected status " + strconv.Itoa(code)
+		}
+		if d.Attempts >= u.maxAttempts {
+			d.State = entities.DeliveryFailed
+			d.NextAttemptAt = nil
+		} else {
+			next := now.Add(callbackBackoff(d.Attempts)).Format(time.RFC3339)
+			d.NextAttemptAt = &next
+		}
+	}
+	if _, err := u.repo.UpdateWebhookDelivery(ctx, d); err != nil {
+		u.logger.ErrorContext(ctx, "failed to record status callback attempt", "delivery_id", d.ID, "error", err)
+		return
+	}
+	u.logger.InfoContext(ctx, "status callback attempted",
+		"delivery_id", d.ID, "message_id", d.MessageID, "state", d.State, "attempts", d.Attempts, "response_code", code)
+}
+
+// callbackBackoff doubles the wait after each failed attempt, capped at an hour.
+func callbackBackoff(attempts int) time.Duration {
+	wait := callbackBaseBackoff
+	for i := 1; i < attempts && wait < callbackMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > callbackMaxBackoff {
+		wait = callbackMaxBackoff
+	}
+	return wait
+}
+
+// SignCallback returns the signature header value for body sent at ts. It
+// uses the same scheme as the webhook provider, see handlers.SignPayload.
+func SignCallback(secret string, ts int64, body []byte) string {
+	return handlers.SignPayload(secret, ts, body)
+}
+
+// ValidateCallbackURL requires an absolute http(s) URL that does not name an
+// internal address. Delivery attempts store the response body, which the
+// caller can read back, so internal services must never be reachable; the
+// sender re-checks every resolved address when it connects.
+func ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("callback_url must be an absolute http or https URL, got %q", raw)
+	}
+	if err := handlers.CheckPublicURL(raw); err != nil {
+		return fmt.Errorf("callback_url: %w", err)
+	}
+	return nil
+}
+
+func generateCallbackSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return callbackSecretPrefix + hex.EncodeToString(buf), nil
+}
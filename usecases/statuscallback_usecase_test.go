@@ -0,0 +1,193 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"messenger-module/entities"
+)
+
+// callbackRepo serves one user and records delivery updates. Methods the
+// tests do not need fall through to the nil embedded interface and panic.
+type callbackRepo struct {
+	StatusCallbackUsecaseRepo
+	user    entities.User
+	updated []entities.WebhookDelivery
+}
+
+func (r *callbackRepo) GetUser(_ context.Context, id string) (entities.User, error) {
+	if id != r.user.ID {
+		return entities.User{}, errors.New("not found")
+	}
+	return r.user, nil
+}
+
+func (r *callbackRepo) UpdateUserCallback(_ context.Context, _ string, url, secret string) (entities.User, error) {
+	r.user.CallbackURL, r.user.CallbackSecret = url, secret
+	return r.user, nil
+}
+
+func (r *callbackRepo) UpdateWebhookDelivery(_ context.Context, in entities.WebhookDelivery) (entities.WebhookDelivery, error) {
+	r.updated = append(r.updated, in)
+	return in, nil
+}
+
+// callbackSender answers every Post with code and err and keeps the last
+// request's headers and body.
+type callbackSender struct {
+	code    int
+	err     error
+	headers map[string]string
+	body    []byte
+}
+
+func (s *callbackSender) Post(_ context.Context, _ string, headers map[string]string, body []byte) (int, string, error) {
+	s.headers, s.body = headers, body
+	return s.code, "", s.err
+}
+
+func TestSignCallback(t *testing.T) {
+	body := []byte(`{"message_id":"m-1","status":"delivered"}`)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignCallback("whsec_test", 1700000000, body); got != want {
+		t.Fatalf("SignCallback = %s, want %s", got, want)
+	}
+	tests := []struct {
+		name   string
+		secret string
+		ts     int64
+		body   []byte
+	}{
+		{"other secret", "whsec_other", 1700000000, body},
+		{"other timestamp", "whsec_test", 1700000001, body},
+		{"tampered body", "whsec_test", 1700000000, []byte(`{"message_id":"m-1","status":"failed"}`)},
+	}
+	for _, tt := range tests {
+		if got := SignCallback(tt.secret, tt.ts, tt.body); got == want {
+			t.Errorf("%s: signature unchanged", tt.name)
+		}
+	}
+}
+
+func TestCallbackBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := callbackBackoff(tt.attempts); got != tt.want {
+			t.Errorf("callbackBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestCallbackAttempt(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      int
+		err       error
+		attempts  int
+		wantState string
+		wantRetry time.Duration
+		wantError string
+	}{
+		{"delivered", 200, nil, 0, entities.DeliverySucceeded, 0, ""},
+		{"accepted", 204, nil, 2, entities.DeliverySucceeded, 0, ""},
+		{"server error retried", 500, nil, 0, entities.DeliveryPending, 30 * time.Second, "unexpected status 500"},
+		{"redirect is a failure", 302, nil, 1, entities.DeliveryPending, time.Minute, "unexpected status 302"},
+		{"network error retried", 0, errors.New("connection refused"), 2, entities.DeliveryPending, 2 * time.Minute, "connection refused"},
+		{"gives up at max attempts", 500, nil, 4, entities.DeliveryFailed, 0, "unexpected status 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &callbackRepo{user: entities.User{ID: "u1", CallbackSecret: "whsec_test"}}
+			sender := &callbackSender{code: tt.code, err: tt.err}
+			uc := NewStatusCallbackUsecase(repo, sender, 5, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			before := time.Now().UTC().Truncate(time.Second)
+			uc.attempt(context.Background(), entities.WebhookDelivery{
+				ID: "d1", UserID: "u1", Event: StatusCallbackEvent, URL: "https://example.com/cb",
+				Payload: `{"status":"sent"}`, State: entities.DeliveryPending, Attempts: tt.attempts,
+			})
+			if len(repo.updated) != 1 {
+				t.Fatalf("updates = %d, want 1", len(repo.updated))
+			}
+			d := repo.updated[0]
+			if d.State != tt.wantState || d.Attempts != tt.attempts+1 || d.LastError != tt.wantError {
+				t.Errorf("delivery = state %s, attempts %d, error %q", d.State, d.Attempts, d.LastError)
+			}
+			if tt.wantRetry == 0 {
+				if d.NextAttemptAt != nil {
+					t.Errorf("next attempt = %s, want none", *d.NextAttemptAt)
+				}
+			} else {
+				next, err := time.Parse(time.RFC3339, derefString(d.NextAttemptAt))
+				if err != nil {
+					t.Fatalf("next attempt: %v", err)
+				}
+				if wait := next.Sub(before); wait < tt.wantRetry || wait > tt.wantRetry+2*time.Second {
+					t.Errorf("next attempt in %s, want %s", wait, tt.wantRetry)
+				}
+			}
+
+			sig := sender.headers[CallbackSignatureHeader]
+			ts := strings.TrimPrefix(strings.SplitN(sig, ",", 2)[0], "t=")
+			mac := hmac.New(sha256.New, []byte("whsec_test"))
+			mac.Write([]byte(ts + "." + string(sender.body)))
+			if !strings.HasSuffix(sig, ",v1="+hex.EncodeToString(mac.Sum(nil))) {
+				t.Errorf("signature %q does not verify", sig)
+			}
+			if sender.headers[CallbackDeliveryHeader] != "d1" || sender.headers[CallbackEventHeader] != StatusCallbackEvent {
+				t.Errorf("headers = %v", sender.headers)
+			}
+		})
+	}
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://example.com/callbacks", true},
+		{"http://example.com:8080/callbacks", true},
+		{"example.com/callbacks", false},
+		{"ftp://example.com/callbacks", false},
+		{"https://localhost/callbacks", false},
+		{"http://127.0.0.1:9000/callbacks", false},
+		{"http://192.168.1.10/callbacks", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fd00::1]/callbacks", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateCallbackURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("ValidateCallbackURL(%q) = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+	}
+}
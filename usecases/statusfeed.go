@@ -0,0 +1,139 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"messenger-module/entities"
+)
+
+// subscriberBuffer is how many events a slow stream may fall behind before
+// further events are dropped for it.
+const subscriberBuffer = 32
+
+// StatusEvent is a status change pushed to the owner's live streams.
+type StatusEvent struct {
+	UserID    string                 `json:"-"`
+	MessageID string                 `json:"message_id"`
+	Status    entities.MessageStatus `json:"status"`
+}
+
+// StatusFeedRepo combines the repositories needed by StatusFeed.
+type StatusFeedRepo interface {
+	MessageRepo
+	StatusEventRepo
+}
+
+// StatusFeed fans status changes out to live subscribers. Events are
+// broadcast through the repository so every instance, including this one,
+// receives them via Receive; if broadcasting fails they are delivered
+// locally only.
+type StatusFeed struct {
+	repo   StatusFeedRepo
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	subs   map[string]map[chan StatusEvent]struct{}
+	closed bool
+}
+
+func NewStatusFeed(repo StatusFeedRepo, logger *slog.Logger) *StatusFeed {
+	return &StatusFeed{repo: repo, logger: logger, subs: map[string]map[chan StatusEvent]struct{}{}}
+}
+
+type statusEventWire struct {
+	UserID    string                 `json:"user_id"`
+	MessageID string                 `json:"message_id"`
+	Status    entities.MessageStatus `json:"status"`
+}
+
+// StatusRecorded implements StatusObserver.
+func (f *StatusFeed) StatusRecorded(ctx context.Context, status entities.MessageStatus) {
+	msg, err := f.repo.GetMessage(ctx, status.MessageID)
+	if err != nil {
+		f.logger.ErrorContext(ctx, "status feed: message not found", "message_id", status.MessageID, "error", err)
+		return
+	}
+	// Raw gateway responses can be large and hold recipient data; streams
+	// only need the normalised status.
+	status.GatewayResponse = ""
+	ev := StatusEvent{UserID: msg.UserID, MessageID: msg.ID, Status: status}
+
+	payload, err := json.Marshal(statusEventWire(ev))
+	if err == nil {
+		err = f.repo.PublishStatusEvent(ctx, string(payload))
+	}
+	if err != nil {
+		f.logger.WarnContext(ctx, "status feed: broadcast failed, delivering locally", "error", err)
+		f.Dispatch(ev)
+	}
+}
+
+// Receive handles a broadcast payload from any instance.
+func (f *StatusFeed) Receive(payload string) {
+	var w statusEventWire
+	if err := json.Unmarshal([]byte(payload), &w); err != nil {
+		f.logger.Warn("status feed: invalid broadcast payload", "error", err)
+		return
+	}
+	f.Dispatch(StatusEvent(w))
+}
+
+// Dispatch delivers ev to this instance's subscribers for its user.
+func (f *StatusFeed) Dispatch(ev StatusEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs[ev.UserID] {
+		select {
+		case ch <- ev:
+		default:
+			f.logger.Warn("status feed: subscriber too slow, dropping event", "user_id", ev.UserID, "message_id", ev.MessageID)
+		}
+	}
+}
+
+// Subscribe returns a channel of the user's status events and a function to
+// unsubscribe. The channel is closed on unsubscribe or Close.
+func (f *StatusFeed) Subscribe(userID string) (<-chan StatusEvent, func()) {
+	ch := make(chan StatusEvent, subscriberBuffer)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if f.subs[userID] == nil {
+		f.subs[userID] = map[chan StatusEvent]struct{}{}
+	}
+	f.subs[userID][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if _, ok := f.subs[userID][ch]; ok {
+				delete(f.subs[userID], ch)
+				if len(f.subs[userID]) == 0 {
+					delete(f.subs, userID)
+				}
+				close(ch)
+			}
+		})
+	}
+}
+
+// Close ends every subscription so long-lived streams finish during shutdown.
+func (f *StatusFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for userID, chans := range f.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(f.subs, userID)
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"strings"
 
 	"messenger-module/entities"
-
-	"github.com/google/uuid"
 )
 
 type UserUsecase struct {
@@ -21,18 +19,25 @@ func (u *UserUsecase) Create(ctx context.Context, in entities.User) (entities.Us
 	if in.Name == "" {
 		return entities.User{}, errors.New("name is required")
 	}
-	if strings.TrimSpace(in.APIKey) == "" {
-		in.APIKey = uuid.New().String()
-	}
 	return u.repo.CreateUser(ctx, in)
 }
 
+// CreateReportingFirst creates the user like Create and reports whether it
+// is the first user ever created.
+func (u *UserUsecase) CreateReportingFirst(ctx context.Context, in entities.User) (entities.User, bool, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return entities.User{}, false, errors.New("name is required")
+	}
+	return u.repo.CreateUserReportingFirst(ctx, in)
+}
+
 func (u *UserUsecase) Get(ctx context.Context, id string) (entities.User, error) {
 	return u.repo.GetUser(ctx, id)
 }
 
-func (u *UserUsecase) List(ctx context.Context) ([]entities.User, error) {
-	return u.repo.ListUsers(ctx)
+func (u *UserUsecase) List(ctx context.Context, opts entities.ListOptions) ([]entities.User, error) {
+	return u.repo.ListUsers(ctx, opts)
 }
 
 func (u *UserUsecase) Update(ctx context.Context, id string, in entities.User) (entities.User, error) {
@@ -40,3 +45,4 @@ func (u *UserUsecase) Update(ctx context.Context, id string, in entities.User) (
 }
 
 func (u *UserUsecase) Delete(ctx context.Context, id string) error { return u.repo.DeleteUser(ctx, id) }
+func (u *UserUsecase) Restore(ctx context.Context, id string) error { return u.repo.RestoreUser(ctx, id) }
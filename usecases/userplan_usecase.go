@@ -3,23 +3,62 @@ package usecases
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"messenger-module/entities"
 )
 
-type UserPlanUsecase struct { repo UserPlanRepo }
+// UserPlanUsecaseRepo combines all repositories needed by UserPlanUsecase
+type UserPlanUsecaseRepo interface {
+	UserPlanRepo
+	UserRepo
+	PlanRepo
+}
+
+type UserPlanUsecase struct { repo UserPlanUsecaseRepo }
 
-func NewUserPlanUsecase(repo UserPlanRepo) *UserPlanUsecase { return &UserPlanUsecase{repo: repo} }
+func NewUserPlanUsecase(repo UserPlanUsecaseRepo) *UserPlanUsecase { return &UserPlanUsecase{repo: repo} }
 
 func (u *UserPlanUsecase) Create(ctx context.Context, in entities.UserPlan) (entities.UserPlan, error) {
 	if in.UserID == "" || in.PlanID == "" {
 		return entities.UserPlan{}, errors.New("user_id and plan_id are required")
 	}
+	if err := u.checkRefs(ctx, in); err != nil {
+		return entities.UserPlan{}, err
+	}
 	return u.repo.CreateUserPlan(ctx, in)
 }
 func (u *UserPlanUsecase) Get(ctx context.Context, id string) (entities.UserPlan, error) { return u.repo.GetUserPlan(ctx, id) }
-func (u *UserPlanUsecase) List(ctx context.Context) ([]entities.UserPlan, error) { return u.repo.ListUserPlans(ctx) }
+func (u *UserPlanUsecase) List(ctx context.Context, opts entities.ListOptions) ([]entities.UserPlan, error) { return u.repo.ListUserPlans(ctx, opts) }
 func (u *UserPlanUsecase) Update(ctx context.Context, id string, in entities.UserPlan) (entities.UserPlan, error) {
+	if err := u.checkRefs(ctx, in); err != nil {
+		return entities.UserPlan{}, err
+	}
 	return u.repo.UpdateUserPlan(ctx, id, in)
 }
 func (u *UserPlanUsecase) Delete(ctx context.Context, id string) error { return u.repo.DeleteUserPlan(ctx, id) }
+func (u *UserPlanUsecase) Restore(ctx context.Context, id string) error {
+	up, err := u.repo.GetDeletedUserPlan(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := u.checkRefs(ctx, up); err != nil {
+		return err
+	}
+	return u.repo.RestoreUserPlan(ctx, id)
+}
+
+// checkRefs makes sure the referenced user and plan exist and are not deleted.
+func (u *UserPlanUsecase) checkRefs(ctx context.Context, in entities.UserPlan) error {
+	if in.UserID != "" {
+		if _, err := u.repo.GetUser(ctx, in.UserID); err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+	}
+	if in.PlanID != "" {
+		if _, err := u.repo.GetPlan(ctx, in.PlanID); err != nil {
+			return fmt.Errorf("plan not found: %w", err)
+		}
+	}
+	return nil
+}
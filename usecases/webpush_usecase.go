@@ -0,0 +1,132 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"messenger-module/entities"
+	"messenger-module/handlers"
+)
+
+// maxSubscriberLength bounds the end-user identifiers push subscriptions are
+// registered under.
+const maxSubscriberLength = 255
+
+// WebPushUsecase manages the service's VAPID keys and the browser push
+// subscriptions of users' end users. It is also the webpush provider's
+// subscription store.
+type WebPushUsecase struct {
+	repo   PushSubscriptionRepo
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	keys entities.VAPIDKeys
+}
+
+func NewWebPushUsecase(repo PushSubscriptionRepo, logger *slog.Logger) *WebPushUsecase {
+	return &WebPushUsecase{repo: repo, logger: logger}
+}
+
+// VAPIDKeys returns the service's key pair, generating and storing one the
+// first time it is needed. Every instance ends up with the same stored pair.
+func (u *WebPushUsecase) VAPIDKeys(ctx context.Context) (entities.VAPIDKeys, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.keys.PublicKey != "" {
+		return u.keys, nil
+	}
+	keys, err := u.repo.GetVAPIDKeys(ctx)
+	if err != nil {
+		return entities.VAPIDKeys{}, err
+	}
+	if keys.PublicKey == "" {
+		generated, err := handlers.GenerateVAPIDKeys()
+		if err != nil {
+			return entities.VAPIDKeys{}, err
+		}
+		if keys, err = u.repo.CreateVAPIDKeys(ctx, generated); err != nil {
+			return entities.VAPIDKeys{}, err
+		}
+		u.logger.InfoContext(ctx, "generated VAPID keys", "public_key", keys.PublicKey)
+	}
+	u.keys = keys
+	return keys, nil
+}
+
+// PublicKey returns the applicationServerKey browsers subscribe with.
+func (u *WebPushUsecase) PublicKey(ctx context.Context) (string, error) {
+	keys, err := u.VAPIDKeys(ctx)
+	return keys.PublicKey, err
+}
+
+// Subscribe registers a browser subscription for one of the user's end
+// users. Registering the same endpoint again updates it.
+func (u *WebPushUsecase) Subscribe(ctx context.Context, userID string, in entities.PushSubscription) (entities.PushSubscription, error) {
+	if in.Subscriber == "" {
+		return entities.PushSubscription{}, errors.New("subscriber is required")
+	}
+	if len(in.Subscriber) > maxSubscriberLength {
+		return entities.PushSubscription{}, errors.New("subscriber must be at most 255 bytes")
+	}
+	if err := handlers.ValidatePushSubscription(in); err != nil {
+		return entities.PushSubscription{}, err
+	}
+	in.ID = ""
+	in.UserID = userID
+	in.ExpiredAt = ""
+	return redactSubscription(u.repo.SavePushSubscription(ctx, in))
+}
+
+// List returns the user's subscriptions, for one subscriber if given,
+// including expired ones.
+func (u *WebPushUsecase) List(ctx context.Context, userID, subscriber string) ([]entities.PushSubscription, error) {
+	out, err := u.repo.ListPushSubscriptions(ctx, userID, subscriber)
+	for i := range out {
+		out[i].Keys = nil
+	}
+	return out, err
+}
+
+// Unsubscribe deletes one of the user's subscriptions. Other users'
+// subscriptions are reported as not found.
+func (u *WebPushUsecase) Unsubscribe(ctx context.Context, userID, id string) error {
+	sub, err := u.repo.GetPushSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	if sub.UserID != userID {
+		return errors.New("not found")
+	}
+	return u.repo.DeletePushSubscription(ctx, id)
+}
+
+// ActiveSubscriptions returns the subscriber's subscriptions that have not
+// expired.
+func (u *WebPushUsecase) ActiveSubscriptions(ctx context.Context, userID, subscriber string) ([]entities.PushSubscription, error) {
+	all, err := u.repo.ListPushSubscriptions(ctx, userID, subscriber)
+	if err != nil {
+		return nil, err
+	}
+	active := all[:0]
+	for _, sub := range all {
+		if sub.ExpiredAt == "" {
+			active = append(active, sub)
+		}
+	}
+	return active, nil
+}
+
+// ExpireSubscription marks a subscription the push service no longer knows.
+func (u *WebPushUsecase) ExpireSubscription(ctx context.Context, id string) error {
+	u.logger.InfoContext(ctx, "push subscription expired", "subscription_id", id)
+	return u.repo.ExpirePushSubscription(ctx, id, time.Now().UTC())
+}
+
+// redactSubscription drops the write-only keys from a repository result.
+func redactSubscription(sub entities.PushSubscription, err error) (entities.PushSubscription, error) {
+	sub.Keys = nil
+	return sub, err
+}